@@ -0,0 +1,159 @@
+package xbeeapi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Address64 is the 64-bit IEEE (MAC) address of a radio, encoded big-endian
+// as 8 bytes on the wire.
+type Address64 uint64
+
+// Address16 is the 16-bit network address of a radio, encoded big-endian
+// as 2 bytes on the wire.
+type Address16 uint16
+
+const (
+	// Address64Coordinator addresses the network coordinator.
+	Address64Coordinator Address64 = 0x0000000000000000
+	// Address64Broadcast addresses every radio on the network.
+	Address64Broadcast Address64 = 0x000000000000ffff
+	// Address64Unknown is used when the 64-bit address is not known.
+	Address64Unknown Address64 = 0xffffffffffffffff
+)
+
+const (
+	// Address16Coordinator is the network address of the coordinator.
+	Address16Coordinator Address16 = 0x0000
+	// Address16BroadcastRouters addresses the coordinator and all routers.
+	Address16BroadcastRouters Address16 = 0xfffc
+	// Address16BroadcastRxOnWhenIdle addresses every radio that is not
+	// sleepy (RxOnWhenIdle).
+	Address16BroadcastRxOnWhenIdle Address16 = 0xfffd
+	// Address16Unknown is used when the 16-bit address is not known, or
+	// when addressing by 64-bit address only.
+	Address16Unknown Address16 = 0xfffe
+	// Address16Broadcast addresses every radio on the network, sleeping
+	// end devices included.
+	Address16Broadcast Address16 = 0xffff
+)
+
+// IsBroadcast reports whether a is one of the broadcast network
+// addresses.
+func (a Address16) IsBroadcast() bool {
+	return a >= Address16BroadcastRouters && a != Address16Unknown
+}
+
+const (
+	address64Size = 8
+	address16Size = 2
+)
+
+// ParseAddress64 parses a 64-bit address written as up to 16 hex digits,
+// optionally prefixed with 0x and grouped with ':', '-', '.' or spaces,
+// e.g. "0013A20040A1B2C3" or "00:13:a2:00:40:a1:b2:c3".
+func ParseAddress64(s string) (Address64, error) {
+	v, err := parseHexAddress(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return Address64(v), nil
+}
+
+// ParseAddress16 parses a 16-bit address written as up to 4 hex digits,
+// e.g. "FFFE" or "0x1a2b".
+func ParseAddress16(s string) (Address16, error) {
+	v, err := parseHexAddress(s, 16)
+	if err != nil {
+		return 0, err
+	}
+	return Address16(v), nil
+}
+
+func parseHexAddress(s string, bits int) (uint64, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '-', '.', ' ':
+			return -1
+		}
+		return r
+	}, s)
+	digits = strings.TrimPrefix(strings.TrimPrefix(digits, "0x"), "0X")
+
+	if len(digits) == 0 || len(digits) > bits/4 {
+		return 0, fmt.Errorf("Expected up to %d hex digits for %d-bit address: %q", bits/4, bits, s)
+	}
+	v, err := strconv.ParseUint(digits, 16, bits)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %d-bit address %q: %v", bits, s, err)
+	}
+	return v, nil
+}
+
+// Address64FromBytes decodes an 8 byte big-endian 64-bit address.
+func Address64FromBytes(b []byte) (Address64, error) {
+	if len(b) != address64Size {
+		return 0, fmt.Errorf("Expected %d bytes for 64-bit address, got %d", address64Size, len(b))
+	}
+	return Address64(binary.BigEndian.Uint64(b)), nil
+}
+
+// Address16FromBytes decodes a 2 byte big-endian 16-bit address.
+func Address16FromBytes(b []byte) (Address16, error) {
+	if len(b) != address16Size {
+		return 0, fmt.Errorf("Expected %d bytes for 16-bit address, got %d", address16Size, len(b))
+	}
+	return Address16(binary.BigEndian.Uint16(b)), nil
+}
+
+// Bytes returns the 8 byte wire encoding of the address.
+func (a Address64) Bytes() []byte {
+	b := make([]byte, address64Size)
+	binary.BigEndian.PutUint64(b, uint64(a))
+	return b
+}
+
+// String formats the address as 16 upper case hex digits.
+func (a Address64) String() string {
+	return fmt.Sprintf("%016X", uint64(a))
+}
+
+func (a Address64) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Address64) UnmarshalText(text []byte) error {
+	v, err := ParseAddress64(string(text))
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Bytes returns the 2 byte wire encoding of the address.
+func (a Address16) Bytes() []byte {
+	b := make([]byte, address16Size)
+	binary.BigEndian.PutUint16(b, uint16(a))
+	return b
+}
+
+// String formats the address as 4 upper case hex digits.
+func (a Address16) String() string {
+	return fmt.Sprintf("%04X", uint16(a))
+}
+
+func (a Address16) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Address16) UnmarshalText(text []byte) error {
+	v, err := ParseAddress16(string(text))
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
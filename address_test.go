@@ -0,0 +1,70 @@
+package xbeeapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestParseAddress64(t *testing.T) {
+	for _, s := range []string{"0013A20040A1B2C3", "00:13:a2:00:40:a1:b2:c3", "0013A200 40A1B2C3", "0x0013a20040a1b2c3"} {
+		a, err := ParseAddress64(s)
+		if err != nil || a != 0x0013a20040a1b2c3 {
+			t.Error("Could not parse", s, a, err)
+		}
+	}
+	if a, err := ParseAddress64("FFFF"); err != nil || a != Address64Broadcast {
+		t.Error("Expected broadcast address, got", a, err)
+	}
+	for _, s := range []string{"", "0013A20040A1B2C3FF", "0013A2004XA1B2C3"} {
+		if _, err := ParseAddress64(s); err == nil {
+			t.Error("Expected error parsing", s)
+		}
+	}
+}
+
+func TestAddressBytes(t *testing.T) {
+	a64 := Address64(0x0013a20040a1b2c3)
+	if !bytes.Equal(a64.Bytes(), []byte{0x00, 0x13, 0xa2, 0x00, 0x40, 0xa1, 0xb2, 0xc3}) {
+		t.Error("Unexpected 64-bit address bytes", a64.Bytes())
+	}
+	if a, _ := Address64FromBytes(a64.Bytes()); a != a64 {
+		t.Error("Address64 round trip mismatch", a)
+	}
+	if !bytes.Equal(Address16Unknown.Bytes(), []byte{0xff, 0xfe}) {
+		t.Error("Unexpected 16-bit address bytes", Address16Unknown.Bytes())
+	}
+	if a64.String() != "0013A20040A1B2C3" || Address16Unknown.String() != "FFFE" {
+		t.Error("Unexpected address formatting", a64, Address16Unknown)
+	}
+}
+
+func TestAddressJSON(t *testing.T) {
+	type config struct {
+		Dest    Address64
+		Network Address16
+	}
+	in := config{Dest: 0x0013a20040a1b2c3, Network: Address16Unknown}
+	b, err := json.Marshal(in)
+	if err != nil || string(b) != `{"Dest":"0013A20040A1B2C3","Network":"FFFE"}` {
+		t.Error("Unexpected JSON", string(b), err)
+	}
+
+	var out config
+	if err := json.Unmarshal(b, &out); err != nil || out != in {
+		t.Error("JSON round trip mismatch", out, err)
+	}
+}
+
+func TestAddress16IsBroadcast(t *testing.T) {
+	for _, a := range []Address16{Address16BroadcastRouters, Address16BroadcastRxOnWhenIdle, Address16Broadcast} {
+		if !a.IsBroadcast() {
+			t.Error("Expected broadcast address", a)
+		}
+	}
+	for _, a := range []Address16{Address16Coordinator, 0x1234, Address16Unknown} {
+		if a.IsBroadcast() {
+			t.Error("Expected unicast address", a)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package xbeeapi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AssociationStatus is the radio's association indication, as read with
+// the AI command.
+type AssociationStatus byte
+
+const (
+	AssociationSuccess AssociationStatus = 0x00
+
+	// 802.15.4 radios.
+	AssociationActiveScanTimeout       AssociationStatus = 0x01
+	AssociationNoPANFound              AssociationStatus = 0x02
+	AssociationNotAllowed              AssociationStatus = 0x03
+	AssociationBeaconsNotSupported     AssociationStatus = 0x04
+	AssociationPANIDMismatch           AssociationStatus = 0x05
+	AssociationChannelMismatch         AssociationStatus = 0x06
+	AssociationEnergyScanTimeout       AssociationStatus = 0x07
+	AssociationCoordinatorStartFailed  AssociationStatus = 0x08
+	AssociationInvalidParameter        AssociationStatus = 0x09
+	AssociationCoordinatorRealignment  AssociationStatus = 0x0a
+	AssociationRequestNotSent          AssociationStatus = 0x0b
+	AssociationRequestTimeout          AssociationStatus = 0x0c
+	AssociationRequestInvalidParameter AssociationStatus = 0x0d
+	AssociationChannelAccessFailure    AssociationStatus = 0x0e
+	AssociationNoCoordinatorAck        AssociationStatus = 0x0f
+	AssociationNoCoordinatorReply      AssociationStatus = 0x10
+	AssociationSyncLoss                AssociationStatus = 0x12
+	AssociationDisassociated           AssociationStatus = 0x13
+
+	// Zigbee radios.
+	AssociationScanNoPAN             AssociationStatus = 0x21
+	AssociationScanNoValidPAN        AssociationStatus = 0x22
+	AssociationJoinNotAllowed        AssociationStatus = 0x23
+	AssociationNoJoinableBeacons     AssociationStatus = 0x24
+	AssociationUnexpectedState       AssociationStatus = 0x25
+	AssociationJoinFailed            AssociationStatus = 0x27
+	AssociationCoordinatorStartError AssociationStatus = 0x2a
+	AssociationCheckingCoordinator   AssociationStatus = 0x2b
+	AssociationLeaveFailed           AssociationStatus = 0x2c
+	AssociationNoResponse            AssociationStatus = 0xab
+	AssociationKeyUnsecured          AssociationStatus = 0xac
+	AssociationKeyNotReceived        AssociationStatus = 0xad
+	AssociationBadLinkKey            AssociationStatus = 0xaf
+	AssociationScanning              AssociationStatus = 0xff
+)
+
+func (s AssociationStatus) Description() string {
+	switch s {
+	case AssociationSuccess:
+		return "Associated"
+	case AssociationActiveScanTimeout:
+		return "Active Scan Timeout"
+	case AssociationNoPANFound:
+		return "Active Scan Found No PANs"
+	case AssociationNotAllowed:
+		return "Coordinator Not Allowing Association"
+	case AssociationBeaconsNotSupported:
+		return "Beacons Not Supported"
+	case AssociationPANIDMismatch:
+		return "PAN ID Mismatch"
+	case AssociationChannelMismatch:
+		return "Channel Mismatch"
+	case AssociationEnergyScanTimeout:
+		return "Energy Scan Timeout"
+	case AssociationCoordinatorStartFailed:
+		return "Coordinator Start Request Failed"
+	case AssociationInvalidParameter:
+		return "Coordinator Invalid Parameter"
+	case AssociationCoordinatorRealignment:
+		return "Coordinator Realignment In Progress"
+	case AssociationRequestNotSent:
+		return "Association Request Not Sent"
+	case AssociationRequestTimeout:
+		return "Association Request Timed Out"
+	case AssociationRequestInvalidParameter:
+		return "Association Request Invalid Parameter"
+	case AssociationChannelAccessFailure:
+		return "Association Request Channel Access Failure"
+	case AssociationNoCoordinatorAck:
+		return "Coordinator Did Not Acknowledge"
+	case AssociationNoCoordinatorReply:
+		return "Coordinator Did Not Reply"
+	case AssociationSyncLoss:
+		return "Sync Lost"
+	case AssociationDisassociated:
+		return "Disassociated"
+	case AssociationScanNoPAN:
+		return "Scan Found No PANs"
+	case AssociationScanNoValidPAN:
+		return "Scan Found No Valid PANs For SC And ID"
+	case AssociationJoinNotAllowed:
+		return "Network Not Allowing Joining"
+	case AssociationNoJoinableBeacons:
+		return "No Joinable Beacons Found"
+	case AssociationUnexpectedState:
+		return "Unexpected State"
+	case AssociationJoinFailed:
+		return "Join Attempt Failed"
+	case AssociationCoordinatorStartError:
+		return "Coordinator Start Attempt Failed"
+	case AssociationCheckingCoordinator:
+		return "Checking For Existing Coordinator"
+	case AssociationLeaveFailed:
+		return "Leave Attempt Failed"
+	case AssociationNoResponse:
+		return "Joined Device Did Not Respond"
+	case AssociationKeyUnsecured:
+		return "Network Key Received Unsecured"
+	case AssociationKeyNotReceived:
+		return "Network Key Not Received"
+	case AssociationBadLinkKey:
+		return "Wrong Preconfigured Link Key"
+	case AssociationScanning:
+		return "Scanning For Network"
+	}
+
+	return fmt.Sprintf("Unknown Association Status: %x", byte(s))
+}
+
+// inProgress reports whether the radio is still working on joining.
+func (s AssociationStatus) inProgress() bool {
+	switch s {
+	case AssociationScanning, AssociationCheckingCoordinator, AssociationCoordinatorRealignment:
+		return true
+	}
+	return false
+}
+
+// NetworkState is whether the radio is part of a network.
+type NetworkState byte
+
+const (
+	// NetworkUnknown: nothing has been heard from the radio yet.
+	NetworkUnknown NetworkState = iota
+	// NetworkJoining: the radio is looking for a network to join.
+	NetworkJoining
+	// NetworkAssociated: the radio joined or started a network.
+	NetworkAssociated
+	// NetworkDisassociated: the radio left, or failed to join, a network.
+	NetworkDisassociated
+	// NetworkSleeping: the network went to sleep.
+	NetworkSleeping
+)
+
+func (s NetworkState) String() string {
+	switch s {
+	case NetworkUnknown:
+		return "unknown"
+	case NetworkJoining:
+		return "joining"
+	case NetworkAssociated:
+		return "associated"
+	case NetworkDisassociated:
+		return "disassociated"
+	case NetworkSleeping:
+		return "sleeping"
+	}
+	return "unknown"
+}
+
+// NetworkEvent is a change of the radio's network state or association
+// indication.
+type NetworkEvent struct {
+	From, To    NetworkState
+	Association AssociationStatus
+	// ModemStatus is the modem status that caused the change, or nil if
+	// it came from an AI query.
+	ModemStatus *ModemStatus
+}
+
+// WithAssociationPolling makes the XBeeAPI query the radio's AI setting
+// every interval while the reader runs, in addition to following its
+// modem status frames. AI is always queried once the radio is initialized
+// and after it resets.
+func WithAssociationPolling(interval time.Duration) Option {
+	return func(o *options) {
+		o.aiInterval = interval
+	}
+}
+
+// networkTracker follows the network state from modem status frames and
+// AI queries.
+type networkTracker struct {
+	mu    *sync.Mutex
+	state NetworkState
+	// awake is the state to go back to when the network wakes up.
+	awake   NetworkState
+	assoc   AssociationStatus
+	changed chan struct{}
+	poll    chan struct{}
+}
+
+func newNetworkTracker() *networkTracker {
+	return &networkTracker{
+		mu:      &sync.Mutex{},
+		assoc:   AssociationScanning,
+		changed: make(chan struct{}),
+		poll:    make(chan struct{}, 1),
+	}
+}
+
+// requestPoll asks for an AI query without waiting for it.
+func (n *networkTracker) requestPoll() {
+	select {
+	case n.poll <- struct{}{}:
+	default:
+	}
+}
+
+// setLocked moves to state with association indication assoc and returns
+// the resulting event, if anything changed.
+func (n *networkTracker) setLocked(state NetworkState, assoc AssociationStatus, ms *ModemStatus) (NetworkEvent, bool) {
+	if state == n.state && assoc == n.assoc {
+		return NetworkEvent{}, false
+	}
+	e := NetworkEvent{From: n.state, To: state, Association: assoc, ModemStatus: ms}
+	n.state, n.assoc = state, assoc
+	close(n.changed)
+	n.changed = make(chan struct{})
+	return e, true
+}
+
+// observeModemStatus updates the state from a modem status frame.
+func (n *networkTracker) observeModemStatus(ms *ModemStatus) (NetworkEvent, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	switch ms.Status {
+	case ModemHardwareReset, ModemWatchdogTimerReset:
+		n.requestPoll()
+		return n.setLocked(NetworkUnknown, AssociationScanning, ms)
+	case ModemJoined, ModemCoordinatorStarted:
+		return n.setLocked(NetworkAssociated, AssociationSuccess, ms)
+	case ModemDisassociated:
+		n.requestPoll()
+		return n.setLocked(NetworkDisassociated, AssociationDisassociated, ms)
+	case ModemNetworkSleeping:
+		if n.state == NetworkSleeping {
+			return NetworkEvent{}, false
+		}
+		n.awake = n.state
+		return n.setLocked(NetworkSleeping, n.assoc, ms)
+	case ModemNetworkWokeUp:
+		if n.state != NetworkSleeping {
+			return NetworkEvent{}, false
+		}
+		return n.setLocked(n.awake, n.assoc, ms)
+	}
+	return NetworkEvent{}, false
+}
+
+// observeAssociation updates the state from an AI query.
+func (n *networkTracker) observeAssociation(assoc AssociationStatus) (NetworkEvent, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	state := NetworkDisassociated
+	switch {
+	case assoc == AssociationSuccess:
+		state = NetworkAssociated
+	case assoc.inProgress():
+		state = NetworkJoining
+	}
+	if n.state == NetworkSleeping {
+		n.awake = state
+		state = NetworkSleeping
+	}
+	return n.setLocked(state, assoc, nil)
+}
+
+// forget makes the state unknown, such as when the port to the radio is
+// lost.
+func (n *networkTracker) forget() (NetworkEvent, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.setLocked(NetworkUnknown, AssociationScanning, nil)
+}
+
+// associatedLocked reports whether the radio is part of a network, asleep
+// or not.
+func (n *networkTracker) associatedLocked() bool {
+	return n.state == NetworkAssociated || n.state == NetworkSleeping && n.awake == NetworkAssociated
+}
+
+// NetworkState returns the radio's network state and its last known
+// association indication.
+func (api *XBeeAPI) NetworkState() (NetworkState, AssociationStatus) {
+	api.network.mu.Lock()
+	defer api.network.mu.Unlock()
+	return api.network.state, api.network.assoc
+}
+
+// SubscribeNetwork calls handler for every NetworkEvent, on a goroutine of
+// its own. Only the queue options of opts are used.
+func (api *XBeeAPI) SubscribeNetwork(handler func(NetworkEvent), opts ...SubscribeOption) *Subscription {
+	return subscribeEvents(api, api.networkSubs, handler, opts)
+}
+
+// PollAssociation reads the radio's AI setting and updates the network
+// state from it.
+func (api *XBeeAPI) PollAssociation(ctx context.Context) (AssociationStatus, error) {
+	resp, err := api.SendATCommand(ctx, &ATCommand{Command: "AI"})
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Params) != 1 {
+		return 0, &LengthError{Expected: 1, Actual: len(resp.Params), Data: resp.Params}
+	}
+
+	assoc := AssociationStatus(resp.Params[0])
+	if e, ok := api.network.observeAssociation(assoc); ok {
+		api.networkSubs.publish(e)
+	}
+	return assoc, nil
+}
+
+// WaitForAssociation returns once the radio is part of a network. If ctx
+// is done first it returns an *AssociationError with the last known
+// association indication, which also matches ctx.Err().
+func (api *XBeeAPI) WaitForAssociation(ctx context.Context) error {
+	n := api.network
+	for {
+		n.mu.Lock()
+		if n.associatedLocked() {
+			n.mu.Unlock()
+			return nil
+		}
+		if n.state == NetworkUnknown {
+			n.requestPoll()
+		}
+		changed, assoc := n.changed, n.assoc
+		n.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return &AssociationError{Status: assoc, Err: ctx.Err()}
+		}
+	}
+}
+
+func (api *XBeeAPI) observeNetwork(f *Frame) {
+	if f.FrameData.FrameType() != FrameTypeModemStatus {
+		return
+	}
+	ms, err := ParseModemStatus(f.FrameData)
+	if err != nil {
+		return
+	}
+	if e, ok := api.network.observeModemStatus(ms); ok {
+		api.networkSubs.publish(e)
+	}
+}
+
+// trackNetwork queries AI when asked to, and every interval if set, until
+// ctx is done.
+func (api *XBeeAPI) trackNetwork(ctx context.Context, interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-api.network.poll:
+		case <-tick:
+		case <-ctx.Done():
+			return
+		}
+		if api.ConnState() != ConnConnected {
+			continue
+		}
+		pollCtx, cancel := context.WithTimeout(ctx, initTimeout)
+		api.PollAssociation(pollCtx)
+		cancel()
+	}
+}
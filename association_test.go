@@ -0,0 +1,94 @@
+package xbeeapi
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// aiPort is an atResponderPort answering AI queries with ai.
+type aiPort struct {
+	*atResponderPort
+	ai atomic.Uint32
+}
+
+func (p *aiPort) Write(b []byte) (int, error) {
+	if f, err := Deserialize(b); err == nil {
+		if at, err := ParseATCommand(f.FrameData); err == nil && at.Command == "AI" {
+			p.respond(&ATCommandResponse{FrameID: at.FrameID, Command: "AI", Status: ATCommandOK, Params: []byte{byte(p.ai.Load())}})
+			return len(b), nil
+		}
+	}
+	return p.atResponderPort.Write(b)
+}
+
+func waitNetworkEvent(t *testing.T, events <-chan NetworkEvent, to NetworkState, assoc AssociationStatus) NetworkEvent {
+	t.Helper()
+	for {
+		select {
+		case e := <-events:
+			if e.To == to && e.Association == assoc {
+				return e
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for network state", to, assoc.Description())
+		}
+	}
+}
+
+func TestNetworkTracking(t *testing.T) {
+	port := &aiPort{atResponderPort: newATResponderPort()}
+	port.ai.Store(uint32(AssociationScanning))
+	api := NewXBeeAPI(port, nil)
+	events := make(chan NetworkEvent, 16)
+	sub := api.SubscribeNetwork(func(e NetworkEvent) { events <- e })
+	defer sub.Unsubscribe()
+
+	if err := api.Start(context.Background()); err != nil {
+		t.Fatal("Could not start", err)
+	}
+	defer api.Close()
+	if e := waitNetworkEvent(t, events, NetworkJoining, AssociationScanning); e.From != NetworkUnknown || e.ModemStatus != nil {
+		t.Error("Unexpected event", e)
+	}
+
+	joined := make(chan error, 1)
+	go func() {
+		joined <- api.WaitForAssociation(context.Background())
+	}()
+	port.respond(&ModemStatus{Status: ModemJoined})
+	waitNetworkEvent(t, events, NetworkAssociated, AssociationSuccess)
+	select {
+	case err := <-joined:
+		if err != nil {
+			t.Error("WaitForAssociation error", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("WaitForAssociation did not return")
+	}
+
+	port.respond(&ModemStatus{Status: ModemNetworkSleeping})
+	waitNetworkEvent(t, events, NetworkSleeping, AssociationSuccess)
+	if err := api.WaitForAssociation(context.Background()); err != nil {
+		t.Error("Expected sleeping radio to count as associated, got", err)
+	}
+	port.respond(&ModemStatus{Status: ModemNetworkWokeUp})
+	waitNetworkEvent(t, events, NetworkAssociated, AssociationSuccess)
+
+	port.ai.Store(uint32(AssociationScanNoValidPAN))
+	port.respond(&ModemStatus{Status: ModemDisassociated})
+	waitNetworkEvent(t, events, NetworkDisassociated, AssociationScanNoValidPAN)
+	if state, assoc := api.NetworkState(); state != NetworkDisassociated || assoc != AssociationScanNoValidPAN {
+		t.Error("Unexpected network state", state, assoc)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := api.WaitForAssociation(ctx)
+	var ae *AssociationError
+	if !errors.As(err, &ae) || ae.Status != AssociationScanNoValidPAN || !errors.Is(err, ErrNotAssociated) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Unexpected WaitForAssociation error", err)
+	}
+}
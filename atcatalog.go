@@ -0,0 +1,466 @@
+package xbeeapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// ErrUnknownATCommand is returned for AT commands not in the catalog.
+var ErrUnknownATCommand = errors.New("Unknown AT command")
+
+// ATParamKind is the type of value an AT command parameter holds.
+type ATParamKind byte
+
+const (
+	// ATParamNone marks commands that are executed rather than read or
+	// written, such as WR or AC.
+	ATParamNone ATParamKind = iota
+	ATParamUint
+	ATParamString
+	// ATParamDuration is an unsigned integer counting ATCommandSpec.Unit.
+	ATParamDuration
+	// ATParamEnum is an unsigned integer restricted to
+	// ATCommandSpec.Values.
+	ATParamEnum
+)
+
+// ATCommandSpec describes an AT command and its parameter.
+type ATCommandSpec struct {
+	Command     string
+	Description string
+	Kind        ATParamKind
+	// Width is the parameter size in bytes, or the maximum length for
+	// strings.
+	Width    int
+	Min      uint64
+	Max      uint64
+	Unit     time.Duration
+	ReadOnly bool
+	Values   map[uint64]string
+	// enum converts a raw value to the Go type returned for enum
+	// parameters.
+	enum func(uint64) interface{}
+}
+
+// Protocol is the firmware family of the radio, which the width and
+// writability of some AT parameters depend on.
+type Protocol byte
+
+const (
+	ProtocolZigbee Protocol = iota
+	Protocol802154
+)
+
+// BaudRate is the BD setting of the radio's serial interface.
+type BaudRate byte
+
+const (
+	Baud1200   BaudRate = 0
+	Baud2400   BaudRate = 1
+	Baud4800   BaudRate = 2
+	Baud9600   BaudRate = 3
+	Baud19200  BaudRate = 4
+	Baud38400  BaudRate = 5
+	Baud57600  BaudRate = 6
+	Baud115200 BaudRate = 7
+	Baud230400 BaudRate = 8
+)
+
+var baudRateBitsPerSecond = [...]int{1200, 2400, 4800, 9600, 19200, 38400, 57600, 115200, 230400}
+
+// BitsPerSecond returns the serial speed selected by b, or 0 if b is not
+// a standard rate.
+func (b BaudRate) BitsPerSecond() int {
+	if int(b) >= len(baudRateBitsPerSecond) {
+		return 0
+	}
+	return baudRateBitsPerSecond[b]
+}
+
+// SleepMode is the SM setting of the radio.
+type SleepMode byte
+
+const (
+	SleepModeNone          SleepMode = 0
+	SleepModePinHibernate  SleepMode = 1
+	SleepModeCyclic        SleepMode = 4
+	SleepModeCyclicPinWake SleepMode = 5
+)
+
+// IOConfig is the D0-D12 setting of an IO line.
+type IOConfig byte
+
+const (
+	IODisabled            IOConfig = 0
+	IOCommissioningButton IOConfig = 1
+	IOAnalogInput         IOConfig = 2
+	IODigitalInput        IOConfig = 3
+	IODigitalOutputLow    IOConfig = 4
+	IODigitalOutputHigh   IOConfig = 5
+)
+
+var baudRates = map[uint64]string{0: "1200", 1: "2400", 2: "4800", 3: "9600", 4: "19200", 5: "38400", 6: "57600", 7: "115200", 8: "230400"}
+
+var apiModes = map[uint64]string{0: "Transparent", 1: "API", 2: "API with escaping"}
+
+var sleepModes = map[uint64]string{0: "No sleep", 1: "Pin hibernate", 4: "Cyclic sleep", 5: "Cyclic sleep with pin wake"}
+
+var ioConfigs = map[uint64]string{0: "Disabled", 1: "Commissioning button", 2: "Analog input", 3: "Digital input", 4: "Digital output low", 5: "Digital output high"}
+
+func ioConfigSpec(command string) *ATCommandSpec {
+	return &ATCommandSpec{Command: command, Description: "IO line " + command[1:] + " configuration", Kind: ATParamEnum, Width: 1, Values: ioConfigs,
+		enum: func(v uint64) interface{} { return IOConfig(v) }}
+}
+
+// atCommands is the catalog of known AT commands on Zigbee radios, keyed
+// by command.
+var atCommands = map[string]*ATCommandSpec{
+	"ID": {Command: "ID", Description: "Extended PAN ID", Kind: ATParamUint, Width: 8, Max: 0xffffffffffffffff},
+	"OP": {Command: "OP", Description: "Operating extended PAN ID", Kind: ATParamUint, Width: 8, Max: 0xffffffffffffffff, ReadOnly: true},
+	"OI": {Command: "OI", Description: "Operating 16-bit PAN ID", Kind: ATParamUint, Width: 2, Max: 0xffff, ReadOnly: true},
+	"SC": {Command: "SC", Description: "Scan channels", Kind: ATParamUint, Width: 2, Min: 0x0001, Max: 0xffff},
+	"CH": {Command: "CH", Description: "Operating channel", Kind: ATParamUint, Width: 1, Min: 0x0b, Max: 0x1a, ReadOnly: true},
+	"NI": {Command: "NI", Description: "Node identifier", Kind: ATParamString, Width: 20},
+	"BD": {Command: "BD", Description: "Interface data rate", Kind: ATParamEnum, Width: 4, Values: baudRates,
+		enum: func(v uint64) interface{} { return BaudRate(v) }},
+	"AP": {Command: "AP", Description: "API enable", Kind: ATParamEnum, Width: 1, Values: apiModes,
+		enum: func(v uint64) interface{} { return APIMode(v) }},
+	"SH": {Command: "SH", Description: "Serial number high", Kind: ATParamUint, Width: 4, Max: 0xffffffff, ReadOnly: true},
+	"SL": {Command: "SL", Description: "Serial number low", Kind: ATParamUint, Width: 4, Max: 0xffffffff, ReadOnly: true},
+	"MY": {Command: "MY", Description: "16-bit network address", Kind: ATParamUint, Width: 2, Max: 0xffff, ReadOnly: true},
+	"MP": {Command: "MP", Description: "16-bit parent network address", Kind: ATParamUint, Width: 2, Max: 0xffff, ReadOnly: true},
+	"PL": {Command: "PL", Description: "Power level", Kind: ATParamUint, Width: 1, Max: 4},
+	"SM": {Command: "SM", Description: "Sleep mode", Kind: ATParamEnum, Width: 1, Values: sleepModes,
+		enum: func(v uint64) interface{} { return SleepMode(v) }},
+	"SP": {Command: "SP", Description: "Cyclic sleep period", Kind: ATParamDuration, Width: 2, Min: 0x20, Max: 0xaf0, Unit: 10 * time.Millisecond},
+	"ST": {Command: "ST", Description: "Time before sleep", Kind: ATParamDuration, Width: 2, Min: 0x01, Max: 0xfffe, Unit: time.Millisecond},
+	"NT": {Command: "NT", Description: "Node discovery timeout", Kind: ATParamDuration, Width: 1, Min: 0x20, Max: 0xff, Unit: nodeDiscoveryTimeoutUnit},
+	"NO": {Command: "NO", Description: "Node discovery options", Kind: ATParamUint, Width: 1, Max: 0x07},
+	"NJ": {Command: "NJ", Description: "Node join time", Kind: ATParamUint, Width: 1, Max: 0xff},
+	"IR": {Command: "IR", Description: "IO sample rate", Kind: ATParamDuration, Width: 2, Max: 0xffff, Unit: time.Millisecond},
+	"AI": {Command: "AI", Description: "Association indication", Kind: ATParamUint, Width: 1, Max: 0xff, ReadOnly: true},
+	"DD": {Command: "DD", Description: "Device type identifier", Kind: ATParamUint, Width: 4, Max: 0xffffffff},
+	"VR": {Command: "VR", Description: "Firmware version", Kind: ATParamUint, Width: 2, Max: 0xffff, ReadOnly: true},
+	"HV": {Command: "HV", Description: "Hardware version", Kind: ATParamUint, Width: 2, Max: 0xffff, ReadOnly: true},
+	"D0": ioConfigSpec("D0"),
+	"D1": ioConfigSpec("D1"),
+	"D2": ioConfigSpec("D2"),
+	"D3": ioConfigSpec("D3"),
+	"D4": ioConfigSpec("D4"),
+	"D5": ioConfigSpec("D5"),
+	"D6": ioConfigSpec("D6"),
+	"D7": ioConfigSpec("D7"),
+	"D8": ioConfigSpec("D8"),
+	"D9": ioConfigSpec("D9"),
+	"WR": {Command: "WR", Description: "Write settings to non-volatile memory", Kind: ATParamNone},
+	"AC": {Command: "AC", Description: "Apply changes", Kind: ATParamNone},
+	"FR": {Command: "FR", Description: "Software reset", Kind: ATParamNone},
+	"RE": {Command: "RE", Description: "Restore defaults", Kind: ATParamNone},
+}
+
+// atCommands802154 holds the catalog entries that differ on 802.15.4
+// radios, where ID is a 16-bit PAN ID and CH is set rather than picked by
+// the coordinator.
+var atCommands802154 = map[string]*ATCommandSpec{
+	"ID": {Command: "ID", Description: "PAN ID", Kind: ATParamUint, Width: 2, Max: 0xffff},
+	"CH": {Command: "CH", Description: "Operating channel", Kind: ATParamUint, Width: 1, Min: 0x0b, Max: 0x1a},
+}
+
+// LookupATCommand returns a copy of the catalog entry for command on
+// Zigbee radios.
+func LookupATCommand(command string) (*ATCommandSpec, error) {
+	return LookupProtocolATCommand(ProtocolZigbee, command)
+}
+
+// LookupProtocolATCommand returns a copy of the catalog entry for command
+// on radios running protocol p.
+func LookupProtocolATCommand(p Protocol, command string) (*ATCommandSpec, error) {
+	spec, ok := atCommands[command]
+	if override, found := atCommands802154[command]; found && p == Protocol802154 {
+		spec, ok = override, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownATCommand, command)
+	}
+	c := *spec
+	c.Values = maps.Clone(spec.Values)
+	return &c, nil
+}
+
+// KnownATCommands returns the commands in the catalog, sorted.
+func KnownATCommands() []string {
+	commands := make([]string, 0, len(atCommands))
+	for command := range atCommands {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	return commands
+}
+
+// ATParamError is returned when a value cannot be encoded for, or
+// decoded from, an AT command parameter.
+type ATParamError struct {
+	Command string
+	Reason  string
+}
+
+func (e *ATParamError) Error() string {
+	return fmt.Sprintf("AT command %s: %s", e.Command, e.Reason)
+}
+
+func (s *ATCommandSpec) paramError(format string, args ...interface{}) error {
+	return &ATParamError{Command: s.Command, Reason: fmt.Sprintf(format, args...)}
+}
+
+// Encode validates value and encodes it as the command's parameter.
+// Strings take a string, durations a time.Duration and every other kind
+// any integer type, including the enum types of this package.
+func (s *ATCommandSpec) Encode(value interface{}) ([]byte, error) {
+	switch s.Kind {
+	case ATParamNone:
+		return nil, s.paramError("takes no parameter")
+	case ATParamString:
+		str, ok := value.(string)
+		if !ok {
+			return nil, s.paramError("expected string, got %T", value)
+		}
+		if len(str) > s.Width {
+			return nil, s.paramError("%q longer than %d characters", str, s.Width)
+		}
+		for _, c := range []byte(str) {
+			if c < 0x20 || c > 0x7e {
+				return nil, s.paramError("%q contains non printable characters", str)
+			}
+		}
+		return []byte(str), nil
+	}
+
+	var v uint64
+	if s.Kind == ATParamDuration {
+		d, ok := value.(time.Duration)
+		if !ok {
+			return nil, s.paramError("expected time.Duration, got %T", value)
+		}
+		if s.Unit <= 0 {
+			return nil, s.paramError("has no duration unit")
+		}
+		if d < 0 || d%s.Unit != 0 {
+			return nil, s.paramError("%v is not a multiple of %v", d, s.Unit)
+		}
+		v = uint64(d / s.Unit)
+	} else {
+		var err error
+		if v, err = s.toUint(value); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Kind == ATParamEnum {
+		if _, ok := s.Values[v]; !ok {
+			return nil, s.paramError("invalid value %d", v)
+		}
+	} else if v < s.Min || v > s.Max {
+		return nil, s.paramError("%d out of range 0x%x-0x%x", v, s.Min, s.Max)
+	}
+
+	b := make([]byte, s.Width)
+	for i := s.Width - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return b, nil
+}
+
+func (s *ATCommandSpec) toUint(value interface{}) (uint64, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Int() < 0 {
+			return 0, s.paramError("negative value %d", rv.Int())
+		}
+		return uint64(rv.Int()), nil
+	}
+
+	return 0, s.paramError("expected integer, got %T", value)
+}
+
+// Decode converts the parameter of an AT command response to a Go value:
+// a string, a time.Duration, the enum type of the command or, for plain
+// numbers and enums without a Go type, a uint8, uint16, uint32 or uint64
+// matching Width.
+func (s *ATCommandSpec) Decode(params []byte) (interface{}, error) {
+	switch s.Kind {
+	case ATParamNone:
+		return nil, nil
+	case ATParamString:
+		return string(params), nil
+	}
+
+	if len(params) == 0 || len(params) > 8 {
+		return nil, s.paramError("unexpected parameter size %d", len(params))
+	}
+	var v uint64
+	for _, b := range params {
+		v = v<<8 | uint64(b)
+	}
+
+	switch {
+	case s.Kind == ATParamDuration && s.Unit <= 0:
+		return nil, s.paramError("has no duration unit")
+	case s.Kind == ATParamDuration:
+		return time.Duration(v) * s.Unit, nil
+	case s.Kind == ATParamEnum && s.enum != nil:
+		return s.enum(v), nil
+	}
+	switch {
+	case s.Width <= 1:
+		return uint8(v), nil
+	case s.Width <= 2:
+		return uint16(v), nil
+	case s.Width <= 4:
+		return uint32(v), nil
+	}
+	return v, nil
+}
+
+// getATParam queries command on the local radio and returns its decoded
+// value, see ATCommandSpec.Decode.
+func (api *XBeeAPI) getATParam(ctx context.Context, command string) (interface{}, error) {
+	spec, err := LookupProtocolATCommand(api.protocol, command)
+	if err != nil {
+		return nil, err
+	}
+	if spec.Kind == ATParamNone {
+		return nil, spec.paramError("cannot be queried")
+	}
+	resp, err := api.SendATCommand(ctx, &ATCommand{Command: command})
+	if err != nil {
+		return nil, err
+	}
+
+	return spec.Decode(resp.Params)
+}
+
+// SetATParam validates value and writes it to command on the local radio.
+// Invalid values are rejected before anything is sent. Changes are not
+// applied or saved until AC or WR is executed.
+func (api *XBeeAPI) SetATParam(ctx context.Context, command string, value interface{}) error {
+	spec, err := LookupProtocolATCommand(api.protocol, command)
+	if err != nil {
+		return err
+	}
+	if spec.ReadOnly {
+		return spec.paramError("is read-only")
+	}
+	params, err := spec.Encode(value)
+	if err != nil {
+		return err
+	}
+	_, err = api.SendATCommand(ctx, &ATCommand{Command: command, Params: params})
+
+	return err
+}
+
+// ExecATCommand runs a command that takes no parameter, such as WR, AC or
+// FR, on the local radio.
+func (api *XBeeAPI) ExecATCommand(ctx context.Context, command string) error {
+	spec, err := LookupProtocolATCommand(api.protocol, command)
+	if err != nil {
+		return err
+	}
+	if spec.Kind != ATParamNone {
+		return spec.paramError("is not an executable command")
+	}
+	_, err = api.SendATCommand(ctx, &ATCommand{Command: command})
+
+	return err
+}
+
+// GetUint queries a numeric or enum AT parameter as a uint64.
+func (api *XBeeAPI) GetUint(ctx context.Context, command string) (uint64, error) {
+	v, err := api.getATParam(ctx, command)
+	if err != nil {
+		return 0, err
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint(), nil
+	}
+
+	return 0, &ATParamError{Command: command, Reason: fmt.Sprintf("%T is not an unsigned integer", v)}
+}
+
+// GetString queries a string AT parameter such as NI.
+func (api *XBeeAPI) GetString(ctx context.Context, command string) (string, error) {
+	v, err := api.getATParam(ctx, command)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", &ATParamError{Command: command, Reason: fmt.Sprintf("%T is not a string", v)}
+	}
+
+	return s, nil
+}
+
+// GetDuration queries a time AT parameter such as NT or SP.
+func (api *XBeeAPI) GetDuration(ctx context.Context, command string) (time.Duration, error) {
+	v, err := api.getATParam(ctx, command)
+	if err != nil {
+		return 0, err
+	}
+	d, ok := v.(time.Duration)
+	if !ok {
+		return 0, &ATParamError{Command: command, Reason: fmt.Sprintf("%T is not a duration", v)}
+	}
+
+	return d, nil
+}
+
+// GetBaudRate queries the BD setting of the local radio.
+func (api *XBeeAPI) GetBaudRate(ctx context.Context) (BaudRate, error) {
+	return getEnum[BaudRate](ctx, api, "BD")
+}
+
+// GetSleepMode queries the SM setting of the local radio.
+func (api *XBeeAPI) GetSleepMode(ctx context.Context) (SleepMode, error) {
+	return getEnum[SleepMode](ctx, api, "SM")
+}
+
+// GetIOConfig queries the configuration of IO line 0-9 of the local radio
+// (D0-D9).
+func (api *XBeeAPI) GetIOConfig(ctx context.Context, line int) (IOConfig, error) {
+	if line < 0 || line > 9 {
+		return 0, fmt.Errorf("%w: D%d", ErrUnknownATCommand, line)
+	}
+	return getEnum[IOConfig](ctx, api, fmt.Sprintf("D%d", line))
+}
+
+// GetAPIModeSetting queries the AP setting of the local radio. Unlike
+// APIMode it always asks the radio.
+func (api *XBeeAPI) GetAPIModeSetting(ctx context.Context) (APIMode, error) {
+	return getEnum[APIMode](ctx, api, "AP")
+}
+
+func getEnum[E any](ctx context.Context, api *XBeeAPI, command string) (E, error) {
+	var e E
+	v, err := api.getATParam(ctx, command)
+	if err != nil {
+		return e, err
+	}
+	e, ok := v.(E)
+	if !ok {
+		return e, &ATParamError{Command: command, Reason: fmt.Sprintf("%T is not a %T", v, e)}
+	}
+
+	return e, nil
+}
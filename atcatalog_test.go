@@ -0,0 +1,195 @@
+package xbeeapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestATCommandSpecEncode(t *testing.T) {
+	cases := []struct {
+		command  string
+		value    interface{}
+		expected []byte
+	}{
+		{"ID", uint64(0x1234), []byte{0, 0, 0, 0, 0, 0, 0x12, 0x34}},
+		{"SC", 0x7fff, []byte{0x7f, 0xff}},
+		{"NI", "gateway", []byte("gateway")},
+		{"BD", Baud115200, []byte{0, 0, 0, 7}},
+		{"AP", APIModeEscaped, []byte{2}},
+		{"NT", 6 * time.Second, []byte{0x3c}},
+		{"D1", IOAnalogInput, []byte{2}},
+	}
+	for _, c := range cases {
+		spec, err := LookupATCommand(c.command)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		b, err := spec.Encode(c.value)
+		if err != nil || !bytes.Equal(b, c.expected) {
+			t.Error(c.command, "expected:", c.expected, "got:", b, err)
+		}
+	}
+}
+
+func TestATCommandSpecRejects(t *testing.T) {
+	cases := []struct {
+		command string
+		value   interface{}
+	}{
+		{"SC", 0},
+		{"PL", 5},
+		{"NI", "a node identifier that is too long"},
+		{"BD", 42},
+		{"SM", SleepMode(2)},
+		{"NT", time.Second},
+		{"NT", 150 * time.Millisecond},
+		{"ID", "1234"},
+		{"WR", 1},
+	}
+	for _, c := range cases {
+		spec, _ := LookupATCommand(c.command)
+		if _, err := spec.Encode(c.value); err == nil {
+			t.Error("Expected", c.command, "to reject", c.value)
+		}
+	}
+	if _, err := LookupATCommand("ZZ"); err == nil {
+		t.Error("Expected unknown command error")
+	}
+}
+
+func TestATCommandSpecDecode(t *testing.T) {
+	cases := []struct {
+		command  string
+		params   []byte
+		expected interface{}
+	}{
+		{"MY", []byte{0x7d, 0x84}, uint16(0x7d84)},
+		{"SL", []byte{0x40, 0x52, 0x2b, 0xaa}, uint32(0x40522baa)},
+		{"NI", []byte("router"), "router"},
+		{"SP", []byte{0x00, 0x64}, time.Second},
+		{"BD", []byte{0x03}, Baud9600},
+		{"AP", []byte{0x01}, APIModeUnescaped},
+	}
+	for _, c := range cases {
+		spec, _ := LookupATCommand(c.command)
+		v, err := spec.Decode(c.params)
+		if err != nil || v != c.expected {
+			t.Error(c.command, "expected:", c.expected, "got:", v, err)
+		}
+	}
+}
+
+func TestBaudRateBitsPerSecond(t *testing.T) {
+	rates := map[BaudRate]int{
+		Baud1200: 1200, Baud2400: 2400, Baud4800: 4800, Baud9600: 9600, Baud19200: 19200,
+		Baud38400: 38400, Baud57600: 57600, Baud115200: 115200, Baud230400: 230400,
+	}
+	for b, bps := range rates {
+		if got := b.BitsPerSecond(); got != bps {
+			t.Error(b, "expected:", bps, "got:", got)
+		}
+	}
+	if got := BaudRate(9).BitsPerSecond(); got != 0 {
+		t.Error("Expected 0 for a non-standard rate, got", got)
+	}
+}
+
+func TestATCommandSpecGuards(t *testing.T) {
+	spec, _ := LookupATCommand("BD")
+	spec.Values[42] = "42"
+	if again, _ := LookupATCommand("BD"); again.Values[42] != "" {
+		t.Error("Expected catalog to be unaffected by changes to a looked up spec")
+	}
+
+	enum := &ATCommandSpec{Command: "XE", Kind: ATParamEnum, Width: 1}
+	if v, err := enum.Decode([]byte{3}); err != nil || v != uint8(3) {
+		t.Error("Expected enum without Go type to decode as integer", v, err)
+	}
+	if _, err := enum.Encode(3); err == nil {
+		t.Error("Expected enum without values to reject everything")
+	}
+	duration := &ATCommandSpec{Command: "XD", Kind: ATParamDuration, Width: 2, Max: 0xffff}
+	if _, err := duration.Encode(time.Second); err == nil {
+		t.Error("Expected duration without unit to be rejected")
+	}
+	if _, err := duration.Decode([]byte{0, 1}); err == nil {
+		t.Error("Expected duration without unit to fail decoding")
+	}
+}
+
+// writeCountPort counts the frames written to an atResponderPort.
+type writeCountPort struct {
+	*atResponderPort
+	writes atomic.Int32
+}
+
+func (p *writeCountPort) Write(data []byte) (int, error) {
+	p.writes.Add(1)
+	return p.atResponderPort.Write(data)
+}
+
+func TestSetATParamRejectsBeforeWrite(t *testing.T) {
+	port := &writeCountPort{atResponderPort: newATResponderPort()}
+	api := NewXBeeAPI(port, nil)
+	api.Start(context.Background())
+	defer api.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for api.ConnState() != ConnConnected && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	if err := api.SetATParam(ctx, "PL", 4); err != nil {
+		t.Fatal("Could not set PL", err)
+	}
+
+	written := port.writes.Load()
+	var paramErr *ATParamError
+	if err := api.SetATParam(ctx, "PL", 5); !errors.As(err, &paramErr) {
+		t.Error("Expected out of range PL to be rejected", err)
+	}
+	if err := api.SetATParam(ctx, "MY", 0x1234); !errors.As(err, &paramErr) {
+		t.Error("Expected read-only MY to be rejected", err)
+	}
+	if err := api.SetATParam(ctx, "ZZ", 1); !errors.Is(err, ErrUnknownATCommand) {
+		t.Error("Expected unknown command to be rejected", err)
+	}
+	if n := port.writes.Load(); n != written {
+		t.Error("Expected no frames written for rejected values, got", n-written)
+	}
+
+	if _, err := api.GetBaudRate(ctx); err != nil {
+		t.Error("Could not get BD", err)
+	}
+}
+
+func TestATCommandProtocol(t *testing.T) {
+	id, _ := LookupProtocolATCommand(Protocol802154, "ID")
+	if b, err := id.Encode(0x3332); err != nil || !bytes.Equal(b, []byte{0x33, 0x32}) {
+		t.Error("Expected a 16-bit PAN ID on 802.15.4, got", b, err)
+	}
+	if v, err := id.Decode([]byte{0x33, 0x32}); err != nil || v != uint16(0x3332) {
+		t.Error("Unexpected 802.15.4 PAN ID", v, err)
+	}
+	if _, err := id.Encode(0x10000); err == nil {
+		t.Error("Expected an 802.15.4 PAN ID above 0xffff to be rejected")
+	}
+
+	port := &writeCountPort{atResponderPort: newATResponderPort()}
+	api := NewXBeeAPI(port, nil, WithProtocol(Protocol802154))
+	api.Start(context.Background())
+	defer api.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := api.SetATParam(ctx, "CH", 0x0c); err != nil {
+		t.Error("Expected CH to be writable on 802.15.4", err)
+	}
+	if err := NewXBeeAPI(port, nil).SetATParam(ctx, "CH", 0x0c); err == nil {
+		t.Error("Expected CH to be read-only on Zigbee")
+	}
+}
@@ -1,7 +1,5 @@
 package xbeeapi
 
-import "bytes"
-
 const MinATCommandSize = 3
 
 type ATCommand struct {
@@ -11,17 +9,17 @@ type ATCommand struct {
 }
 
 func ParseATCommand(rfd *RawFrameData) (*ATCommand, error) {
-	if !rfd.IsValid() || rfd.FrameType() != FrameTypeATCommand {
-		return nil, &FrameParseError{msg: "Expecting frame type ATCommand"}
-	}
-	if rfd.Len() < MinATCommandSize {
-		return nil, &FrameParseError{msg: "Frame data too small for ATCommand"}
+	c, err := newFrameCursor(rfd, FrameTypeATCommand, "ATCommand")
+	if err != nil {
+		return nil, err
 	}
-	buf := bytes.NewBuffer(rfd.Data())
 	at := &ATCommand{
-		FrameID: buf.Next(1)[0],
-		Command: string(buf.Next(2)),
-		Params:  copySlice(buf.Bytes()),
+		FrameID: c.uint8("FrameID"),
+		Command: c.string("Command", 2),
+		Params:  c.rest(),
+	}
+	if c.err != nil {
+		return nil, c.err
 	}
 	if !at.IsValid() {
 		return nil, &FrameParseError{msg: "Invalid frame data for ATCommand"}
@@ -45,3 +43,7 @@ func (at *ATCommand) IsValid() bool {
 func (at *ATCommand) FrameType() byte {
 	return FrameTypeATCommand
 }
+
+func (at *ATCommand) SetFrameID(id byte) {
+	at.FrameID = id
+}
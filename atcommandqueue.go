@@ -1,7 +1,5 @@
 package xbeeapi
 
-import "bytes"
-
 type ATCommandQueue struct {
 	FrameID byte
 	Command string
@@ -9,17 +7,17 @@ type ATCommandQueue struct {
 }
 
 func ParseATCommandQueue(rfd *RawFrameData) (*ATCommandQueue, error) {
-	if !rfd.IsValid() || rfd.FrameType() != FrameTypeATCommandQueueRegisterValue {
-		return nil, &FrameParseError{msg: "Expecting frame type ATCommandQueue"}
-	}
-	if rfd.Len() < MinATCommandSize {
-		return nil, &FrameParseError{msg: "Frame data too small for ATCommandQueue"}
+	c, err := newFrameCursor(rfd, FrameTypeATCommandQueueRegisterValue, "ATCommandQueue")
+	if err != nil {
+		return nil, err
 	}
-	buf := bytes.NewBuffer(rfd.Data())
 	at := &ATCommandQueue{
-		FrameID: buf.Next(1)[0],
-		Command: string(buf.Next(2)),
-		Params:  copySlice(buf.Bytes()),
+		FrameID: c.uint8("FrameID"),
+		Command: c.string("Command", 2),
+		Params:  c.rest(),
+	}
+	if c.err != nil {
+		return nil, c.err
 	}
 	if !at.IsValid() {
 		return nil, &FrameParseError{msg: "Invalid frame data for ATCommandQueue"}
@@ -43,3 +41,7 @@ func (at *ATCommandQueue) IsValid() bool {
 func (at *ATCommandQueue) FrameType() byte {
 	return FrameTypeATCommandQueueRegisterValue
 }
+
+func (at *ATCommandQueue) SetFrameID(id byte) {
+	at.FrameID = id
+}
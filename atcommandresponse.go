@@ -1,9 +1,6 @@
 package xbeeapi
 
-import (
-	"bytes"
-	"fmt"
-)
+import "fmt"
 
 const MinATCommandResponseSize = 4
 
@@ -24,18 +21,18 @@ type ATCommandResponse struct {
 }
 
 func ParseATCommandResponse(rfd *RawFrameData) (*ATCommandResponse, error) {
-	if !rfd.IsValid() || rfd.FrameType() != FrameTypeATCommandResponse {
-		return nil, &FrameParseError{msg: "Expecting frame type ATCommandResponse"}
-	}
-	if len(rfd.Data()) < MinATCommandResponseSize {
-		return nil, &FrameParseError{msg: "Frame data too small for ATCommandResponse"}
+	c, err := newFrameCursor(rfd, FrameTypeATCommandResponse, "ATCommandResponse")
+	if err != nil {
+		return nil, err
 	}
-	buf := bytes.NewBuffer(rfd.Data())
 	at := &ATCommandResponse{
-		FrameID: buf.Next(1)[0],
-		Command: string(buf.Next(2)),
-		Status:  buf.Next(1)[0],
-		Params:  copySlice(buf.Bytes()),
+		FrameID: c.uint8("FrameID"),
+		Command: c.string("Command", 2),
+		Status:  c.uint8("Status"),
+		Params:  c.rest(),
+	}
+	if c.err != nil {
+		return nil, c.err
 	}
 	if !at.IsValid() {
 		return nil, &FrameParseError{msg: "Invalid frame data for ATCommandResponse"}
@@ -73,7 +70,31 @@ func ATCommandStatusDescription(status byte) string {
 		return fmt.Sprintf("Invalid Command %d", status)
 	case ATCommandInvalidParam:
 		return fmt.Sprintf("Invalid Params%d", status)
+	case ATCommandRemoteTransFailed:
+		return fmt.Sprintf("Remote Transmission Failed %d", status)
 	}
 
 	return fmt.Sprintf("AT Command Status Unknown %d", status)
 }
+
+// ATCommandStatusError is returned when the radio answers an AT command
+// with a status other than ATCommandOK.
+type ATCommandStatusError struct {
+	Command string
+	Status  byte
+	// Remote is set for remote AT commands, with Address64 the radio
+	// that was addressed.
+	Remote    bool
+	Address64 Address64
+}
+
+func (e *ATCommandStatusError) Error() string {
+	if e.Remote {
+		return fmt.Sprintf("AT command %s on %s failed: %s", e.Command, e.Address64, ATCommandStatusDescription(e.Status))
+	}
+	return fmt.Sprintf("AT command %s failed: %s", e.Command, ATCommandStatusDescription(e.Status))
+}
+
+func (e *ATCommandStatusError) Is(target error) bool {
+	return target == ErrATCommandStatus
+}
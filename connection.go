@@ -0,0 +1,283 @@
+package xbeeapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// PortFactory opens the port to the radio, such as a serial device. It is
+// called by Start when the XBeeAPI has no port, and again each time the
+// connection is lost.
+type PortFactory func(ctx context.Context) (io.ReadWriter, error)
+
+// ErrReconnecting is returned for requests that were waiting for a
+// response, or frames waiting to be written, when the connection to the
+// radio was lost, and for frames sent until it is back.
+var ErrReconnecting = errors.New("XBeeAPI reconnecting to the radio")
+
+// ErrNoPort is returned by Start when the XBeeAPI has neither a port nor a
+// PortFactory.
+var ErrNoPort = errors.New("XBeeAPI has no port")
+
+// APIModeError is reported when the radio uses another API mode than the
+// one configured with WithAPIMode.
+type APIModeError struct {
+	Expected APIMode
+	Actual   APIMode
+}
+
+func (e *APIModeError) Error() string {
+	return fmt.Sprintf("radio uses API mode %d, expected %d", e.Actual, e.Expected)
+}
+
+const (
+	// DefaultReconnectMinDelay is the delay before the first attempt to
+	// reopen a lost port, doubled after each failure.
+	DefaultReconnectMinDelay = 250 * time.Millisecond
+	// DefaultReconnectMaxDelay caps the delay between attempts.
+	DefaultReconnectMaxDelay = 30 * time.Second
+)
+
+// reconnectAfterErrors is how many read or write errors in a row make the
+// port count as lost.
+const reconnectAfterErrors = 3
+
+// initTimeout bounds each command sent to initialize the radio.
+const initTimeout = 3 * time.Second
+
+// ConnState is the state of the connection to the radio.
+type ConnState byte
+
+const (
+	// ConnDisconnected: the reader is stopped, or the port was lost.
+	ConnDisconnected ConnState = iota
+	// ConnReconnecting: an attempt to reopen the port failed, another
+	// one follows.
+	ConnReconnecting
+	// ConnConnected: the port is open and the radio initialized.
+	ConnConnected
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case ConnDisconnected:
+		return "disconnected"
+	case ConnReconnecting:
+		return "reconnecting"
+	case ConnConnected:
+		return "connected"
+	}
+	return "unknown"
+}
+
+// ConnEvent is a change of the connection to the radio.
+type ConnEvent struct {
+	State ConnState
+	// Attempt counts the attempts to reopen the port since it was lost.
+	Attempt int
+	// Err is why the port was lost or could not be reopened, or why the
+	// radio could not be initialized.
+	Err error
+}
+
+// ConnState returns the current state of the connection to the radio.
+func (api *XBeeAPI) ConnState() (s ConnState) {
+	api.mu.Lock()
+	s = api.connState
+	api.mu.Unlock()
+	return
+}
+
+// SubscribeConnState calls handler for every change of the connection
+// state, on a goroutine of its own. Only the queue options of opts are
+// used.
+func (api *XBeeAPI) SubscribeConnState(handler func(ConnEvent), opts ...SubscribeOption) *Subscription {
+	return subscribeEvents(api, api.connSubs, handler, opts)
+}
+
+func (api *XBeeAPI) publish(e ConnEvent) {
+	api.mu.Lock()
+	api.connState = e.State
+	api.mu.Unlock()
+	api.connSubs.publish(e)
+}
+
+func (api *XBeeAPI) currentPort() (port io.ReadWriter) {
+	api.mu.Lock()
+	port = api.port
+	api.mu.Unlock()
+	return
+}
+
+func (api *XBeeAPI) closePort() {
+	closePort(api.currentPort())
+}
+
+func closePort(port io.ReadWriter) {
+	if c, ok := port.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// connectionLost makes the reader reopen the port, if there is a
+// PortFactory to do so.
+func (api *XBeeAPI) connectionLost(err error) {
+	api.mu.Lock()
+	conn := api.conn
+	api.mu.Unlock()
+	api.portLost(conn, err)
+}
+
+// portLost is connectionLost for the port installed as conn. It is
+// ignored if that port has been replaced since.
+func (api *XBeeAPI) portLost(conn int, err error) {
+	if api.factory == nil {
+		return
+	}
+	api.mu.Lock()
+	if !api.running || api.lost != nil || conn != api.conn {
+		api.mu.Unlock()
+		return
+	}
+	api.lost = err
+	api.mu.Unlock()
+
+	api.interruptRead()
+}
+
+// wrote counts write errors in a row. Like read errors, a closed port or
+// reconnectAfterErrors of them in a row count as a lost connection.
+func (api *XBeeAPI) wrote(err error) {
+	api.mu.Lock()
+	if err == nil {
+		api.writeErrors = 0
+		api.mu.Unlock()
+		return
+	}
+	api.writeErrors++
+	lost := isClosed(err) || api.writeErrors >= reconnectAfterErrors
+	conn := api.conn
+	api.mu.Unlock()
+
+	if lost {
+		api.portLost(conn, err)
+	}
+}
+
+// currentConn reports whether conn is the port installed last.
+func (api *XBeeAPI) currentConn(conn int) (ok bool) {
+	api.mu.Lock()
+	ok = conn == api.conn
+	api.mu.Unlock()
+	return
+}
+
+// takeLost returns the error passed to connectionLost since the last
+// call, if any.
+func (api *XBeeAPI) takeLost() (err error) {
+	api.mu.Lock()
+	err, api.lost = api.lost, nil
+	api.mu.Unlock()
+	return
+}
+
+// reconnect fails everything waiting on the lost port, then reopens it
+// with the PortFactory until it succeeds or ctx is done. It runs on the
+// reader goroutine.
+func (api *XBeeAPI) reconnect(ctx context.Context, cause error) error {
+	api.tx.setDown(ErrReconnecting)
+	api.pending.failAll(ErrReconnecting)
+	api.closePort()
+	api.publish(ConnEvent{State: ConnDisconnected, Err: cause})
+	if e, ok := api.network.forget(); ok {
+		api.networkSubs.publish(e)
+	}
+
+	for attempt := 1; ; attempt++ {
+		api.mu.Lock()
+		delay := api.reconnectDelay
+		api.reconnectDelay = min(2*delay, api.reconnectMax)
+		api.mu.Unlock()
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		port, err := api.factory(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			api.publish(ConnEvent{State: ConnReconnecting, Attempt: attempt, Err: err})
+			continue
+		}
+
+		api.mu.Lock()
+		api.port = port
+		api.portClosed = false
+		api.lost = nil
+		api.conn++
+		api.writeErrors = 0
+		conn := api.conn
+		api.mu.Unlock()
+		api.fwr.reset(port)
+		api.tx.setDown(nil)
+		go api.initialize(ctx, attempt, conn)
+		return nil
+	}
+}
+
+// initialize checks the radio's API mode, which also lets APIModeAuto
+// detect it, and runs the commands set with WithInitCommands. With a
+// PortFactory, a radio that cannot be initialized counts as a lost
+// connection. Its result is dropped if the port, installed as conn, has
+// been replaced meanwhile.
+func (api *XBeeAPI) initialize(ctx context.Context, attempt, conn int) {
+	err := api.initRadio(ctx)
+	if ctx.Err() != nil || !api.currentConn(conn) {
+		return
+	}
+	if err != nil && api.factory != nil {
+		api.portLost(conn, err)
+		return
+	}
+
+	if err == nil {
+		api.mu.Lock()
+		api.reconnectDelay = api.reconnectMin
+		api.mu.Unlock()
+	}
+	api.publish(ConnEvent{State: ConnConnected, Attempt: attempt, Err: err})
+	api.network.requestPoll()
+}
+
+func (api *XBeeAPI) initRadio(ctx context.Context) error {
+	apCtx, cancel := context.WithTimeout(ctx, initTimeout)
+	resp, err := api.sendATCommand(apCtx, &ATCommand{Command: "AP"}, probeFrameID)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if mode := api.fwr.mode; mode != APIModeAuto && len(resp.Params) == 1 && APIMode(resp.Params[0]) != mode {
+		return &APIModeError{Expected: mode, Actual: APIMode(resp.Params[0])}
+	}
+
+	for _, c := range api.initCmds {
+		cmd := *c
+		if _, err := api.initCommand(ctx, &cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (api *XBeeAPI) initCommand(ctx context.Context, cmd *ATCommand) (*ATCommandResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, initTimeout)
+	defer cancel()
+	return api.SendATCommand(ctx, cmd)
+}
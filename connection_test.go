@@ -0,0 +1,359 @@
+package xbeeapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyPort is an atResponderPort that can be unplugged with Close, after
+// which reads and writes fail, or wedged, after which commands are no
+// longer answered.
+type flakyPort struct {
+	*atResponderPort
+	wedged    atomic.Bool
+	closeOnce sync.Once
+	closed    chan struct{}
+	commands  chan string
+}
+
+func newFlakyPort() *flakyPort {
+	return &flakyPort{atResponderPort: newATResponderPort(), closed: make(chan struct{}), commands: make(chan string, 64)}
+}
+
+func (p *flakyPort) Read(b []byte) (int, error) {
+	for {
+		select {
+		case <-p.closed:
+			return 0, io.ErrClosedPipe
+		default:
+		}
+		p.mu.Lock()
+		if p.data.Len() > 0 {
+			n, err := p.data.Read(b)
+			p.mu.Unlock()
+			return n, err
+		}
+		p.mu.Unlock()
+		select {
+		case <-p.more:
+		case <-p.closed:
+		}
+	}
+}
+
+func (p *flakyPort) Write(b []byte) (int, error) {
+	select {
+	case <-p.closed:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+	if f, err := Deserialize(b); err == nil {
+		if at, err := ParseATCommand(f.FrameData); err == nil {
+			p.commands <- at.Command
+		}
+	}
+	if p.wedged.Load() {
+		return len(b), nil
+	}
+	return p.atResponderPort.Write(b)
+}
+
+func (p *flakyPort) Close() error {
+	p.closeOnce.Do(func() { close(p.closed) })
+	return nil
+}
+
+func waitConnEvent(t *testing.T, events <-chan ConnEvent, state ConnState) ConnEvent {
+	t.Helper()
+	for {
+		select {
+		case e := <-events:
+			if e.State == state {
+				return e
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for connection state", state)
+		}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	ports := make(chan *flakyPort, 4)
+	factory := func(ctx context.Context) (io.ReadWriter, error) {
+		p := newFlakyPort()
+		ports <- p
+		return p, nil
+	}
+	api := NewXBeeAPI(nil, nil, WithPortFactory(factory), WithReconnectBackoff(time.Millisecond, 10*time.Millisecond),
+		WithInitCommands(&ATCommand{Command: "NJ", Params: []byte{0xff}}))
+	events := make(chan ConnEvent, 16)
+	sub := api.SubscribeConnState(func(e ConnEvent) { events <- e })
+	defer sub.Unsubscribe()
+
+	if err := api.Start(context.Background()); err != nil {
+		t.Fatal("Could not start", err)
+	}
+	defer api.Close()
+	if e := waitConnEvent(t, events, ConnConnected); e.Err != nil {
+		t.Fatal("Initialization failed", e.Err)
+	}
+	first := <-ports
+	for _, cmd := range []string{"AP", "NJ"} {
+		if c := <-first.commands; c != cmd {
+			t.Error("Expected init command", cmd, "got", c)
+		}
+	}
+
+	// A transmit status that never comes is failed by the reconnect.
+	result := make(chan error, 1)
+	go func() {
+		_, err := api.Transmit(context.Background(), &TxRequest{Address64: 0x0013a20040a1b2c3})
+		result <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	first.Close()
+
+	if e := waitConnEvent(t, events, ConnDisconnected); e.Err == nil {
+		t.Error("Expected the disconnect reason")
+	}
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrReconnecting) {
+			t.Error("Expected ErrReconnecting, got", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("In-flight request not failed")
+	}
+	if e := waitConnEvent(t, events, ConnConnected); e.Attempt != 1 || e.Err != nil {
+		t.Error("Unexpected reconnect event", e)
+	}
+
+	second := <-ports
+	if c := <-second.commands; c != "AP" {
+		t.Error("Expected AP query after reconnect, got", c)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := api.SendATCommand(ctx, &ATCommand{Command: "NI"}); err != nil {
+		t.Error("SendATCommand after reconnect", err)
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	factory := func(ctx context.Context) (io.ReadWriter, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 2 || calls == 3 {
+			return nil, errors.New("no such device")
+		}
+		return newFlakyPort(), nil
+	}
+	api := NewXBeeAPI(nil, nil, WithPortFactory(factory), WithReconnectBackoff(time.Millisecond, 10*time.Millisecond))
+	events := make(chan ConnEvent, 16)
+	sub := api.SubscribeConnState(func(e ConnEvent) { events <- e })
+	defer sub.Unsubscribe()
+
+	if err := api.Start(context.Background()); err != nil {
+		t.Fatal("Could not start", err)
+	}
+	defer api.Close()
+	waitConnEvent(t, events, ConnConnected)
+
+	api.connectionLost(errors.New("unplugged"))
+	waitConnEvent(t, events, ConnDisconnected)
+	for attempt := 1; attempt <= 2; attempt++ {
+		if e := waitConnEvent(t, events, ConnReconnecting); e.Attempt != attempt || e.Err == nil {
+			t.Error("Unexpected event", e)
+		}
+	}
+	if e := waitConnEvent(t, events, ConnConnected); e.Attempt != 3 {
+		t.Error("Expected connection on attempt 3, got", e.Attempt)
+	}
+	if s := api.ConnState(); s != ConnConnected {
+		t.Error("Expected connected state, got", s)
+	}
+}
+
+func TestStartNoPort(t *testing.T) {
+	if err := NewXBeeAPI(nil, nil).Start(context.Background()); err != ErrNoPort {
+		t.Error("Expected ErrNoPort, got", err)
+	}
+}
+
+// closedPort is a port whose reads fail as closed and that discards what
+// is written to it.
+type closedPort struct{}
+
+func (closedPort) Read(b []byte) (int, error) {
+	return 0, os.ErrClosed
+}
+
+func (closedPort) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func TestReconnectClosed(t *testing.T) {
+	var opened atomic.Int32
+	factory := func(ctx context.Context) (io.ReadWriter, error) {
+		if opened.Add(1) == 1 {
+			return closedPort{}, nil
+		}
+		return newFlakyPort(), nil
+	}
+	api := NewXBeeAPI(nil, nil, WithPortFactory(factory), WithReconnectBackoff(time.Millisecond, time.Millisecond))
+	events := make(chan ConnEvent, 16)
+	sub := api.SubscribeConnState(func(e ConnEvent) { events <- e })
+	defer sub.Unsubscribe()
+
+	start := time.Now()
+	if err := api.Start(context.Background()); err != nil {
+		t.Fatal("Could not start", err)
+	}
+	defer api.Close()
+	if e := waitConnEvent(t, events, ConnDisconnected); !errors.Is(e.Err, os.ErrClosed) {
+		t.Error("Expected os.ErrClosed as the disconnect reason, got", e.Err)
+	}
+	if e := waitConnEvent(t, events, ConnConnected); e.Attempt == 0 || e.Err != nil {
+		t.Error("Unexpected reconnect event", e)
+	}
+	if d := time.Since(start); d >= reconnectAfterErrors*readErrorDelay {
+		t.Error("Expected an immediate reconnect of a closed port, took", d)
+	}
+
+	// A failure of the closed port reported once it was replaced, such as
+	// that of its initialization, must not take the new port down.
+	api.portLost(1, errors.New("stale"))
+	select {
+	case e := <-events:
+		t.Error("Unexpected event for a replaced port", e)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if n := opened.Load(); n != 2 {
+		t.Error("Expected 2 ports opened, got", n)
+	}
+}
+
+// failWritePort is a flakyPort whose writes fail while fail is set.
+type failWritePort struct {
+	*flakyPort
+	fail   atomic.Bool
+	failed atomic.Int32
+}
+
+func (p *failWritePort) Write(b []byte) (int, error) {
+	if p.fail.Load() {
+		p.failed.Add(1)
+		return 0, errors.New("write glitch")
+	}
+	return p.flakyPort.Write(b)
+}
+
+func TestReconnectWriteErrors(t *testing.T) {
+	ports := make(chan *failWritePort, 4)
+	api := (*XBeeAPI)(nil)
+	factory := func(ctx context.Context) (io.ReadWriter, error) {
+		// The factory runs without the XBeeAPI locked.
+		api.Running()
+		p := &failWritePort{flakyPort: newFlakyPort()}
+		ports <- p
+		return p, nil
+	}
+	api = NewXBeeAPI(nil, nil, WithPortFactory(factory), WithReconnectBackoff(time.Millisecond, time.Millisecond))
+	events := make(chan ConnEvent, 16)
+	sub := api.SubscribeConnState(func(e ConnEvent) { events <- e })
+	defer sub.Unsubscribe()
+
+	if err := api.Start(context.Background()); err != nil {
+		t.Fatal("Could not start", err)
+	}
+	defer api.Close()
+	waitConnEvent(t, events, ConnConnected)
+	first := <-ports
+
+	first.fail.Store(true)
+	for i := 0; i < reconnectAfterErrors; i++ {
+		if _, err := api.SendFrames(&TxRequest{FrameID: 1}); err == nil && i == 0 {
+			t.Fatal("Expected write error")
+		}
+	}
+	if e := waitConnEvent(t, events, ConnDisconnected); e.Err == nil {
+		t.Error("Expected the write error as the disconnect reason")
+	}
+	if n := first.failed.Load(); n < reconnectAfterErrors {
+		t.Error("Connection lost after", n, "write errors")
+	}
+	waitConnEvent(t, events, ConnConnected)
+}
+
+// eofPort is an atResponderPort whose reads return io.EOF instead of
+// blocking while it has nothing to send, as a serial port does when its
+// read timeout expires.
+type eofPort struct {
+	*atResponderPort
+}
+
+func (p eofPort) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.data.Read(b)
+}
+
+func TestIdleEOFKeepsPort(t *testing.T) {
+	var opened atomic.Int32
+	factory := func(ctx context.Context) (io.ReadWriter, error) {
+		opened.Add(1)
+		return eofPort{newATResponderPort()}, nil
+	}
+	api := NewXBeeAPI(nil, nil, WithPortFactory(factory), WithReconnectBackoff(time.Millisecond, time.Millisecond))
+	events := make(chan ConnEvent, 16)
+	sub := api.SubscribeConnState(func(e ConnEvent) { events <- e })
+	defer sub.Unsubscribe()
+
+	if err := api.Start(context.Background()); err != nil {
+		t.Fatal("Could not start", err)
+	}
+	defer api.Close()
+	waitConnEvent(t, events, ConnConnected)
+
+	select {
+	case e := <-events:
+		t.Error("Unexpected event on an idle port", e)
+	case <-time.After(10 * idleReadDelay):
+	}
+	if n := opened.Load(); n != 1 {
+		t.Error("Expected the idle port to be kept, opened", n)
+	}
+}
+
+func TestRestartClosedPort(t *testing.T) {
+	port := newFlakyPort()
+	api := NewXBeeAPI(port, nil)
+	events := make(chan ConnEvent, 16)
+	sub := api.SubscribeConnState(func(e ConnEvent) { events <- e })
+	defer sub.Unsubscribe()
+
+	if err := api.Start(context.Background()); err != nil {
+		t.Fatal("Could not start", err)
+	}
+	waitConnEvent(t, events, ConnConnected)
+	// The port has no read deadline, so Close closes it to stop the reader.
+	if err := api.Close(); err != nil {
+		t.Error("Expected clean close, got", err)
+	}
+	if err := api.Start(context.Background()); err != ErrPortClosed {
+		t.Error("Expected ErrPortClosed, got", err)
+		api.Close()
+	}
+	if api.Running() {
+		t.Error("Expected the reader to stay stopped")
+	}
+}
@@ -0,0 +1,109 @@
+package xbeeapi
+
+import "encoding/binary"
+
+// cursor decodes big-endian fields from frame data without panicking. A
+// read past the end records a *TruncatedError naming the field and
+// returns zero values from then on, so parsers can decode every field and
+// check err once.
+type cursor struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func newCursor(data []byte) *cursor {
+	return &cursor{data: data}
+}
+
+// newFrameCursor checks the frame type of rfd and returns a cursor
+// positioned after it. Offsets in errors count from the frame type byte.
+func newFrameCursor(rfd *RawFrameData, frameType byte, name string) (*cursor, error) {
+	if err := checkFrameType(rfd, frameType, name); err != nil {
+		return nil, err
+	}
+	return &cursor{data: rfd.buf, off: 1}, nil
+}
+
+func (c *cursor) remaining() int {
+	return len(c.data) - c.off
+}
+
+func (c *cursor) next(field string, n int) []byte {
+	if c.err != nil {
+		return nil
+	}
+	if n < 0 || c.remaining() < n {
+		c.err = &TruncatedError{Field: field, Offset: c.off, Size: n, Data: c.data}
+		return nil
+	}
+	b := c.data[c.off : c.off+n]
+	c.off += n
+	return b
+}
+
+func (c *cursor) uint8(field string) byte {
+	if b := c.next(field, 1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (c *cursor) uint16(field string) uint16 {
+	if b := c.next(field, 2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (c *cursor) uint32(field string) uint32 {
+	if b := c.next(field, 4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (c *cursor) uint64(field string) uint64 {
+	if b := c.next(field, 8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (c *cursor) address64(field string) Address64 {
+	return Address64(c.uint64(field))
+}
+
+func (c *cursor) address16(field string) Address16 {
+	return Address16(c.uint16(field))
+}
+
+func (c *cursor) string(field string, n int) string {
+	return string(c.next(field, n))
+}
+
+// cstring reads a string terminated by a zero byte, which is consumed.
+func (c *cursor) cstring(field string) string {
+	if c.err != nil {
+		return ""
+	}
+	for i, b := range c.data[c.off:] {
+		if b == 0x00 {
+			s := string(c.data[c.off : c.off+i])
+			c.off += i + 1
+			return s
+		}
+	}
+	c.err = &TruncatedError{Field: field, Offset: c.off, Size: c.remaining() + 1, Data: c.data}
+	return ""
+}
+
+// rest returns a copy of the remaining bytes.
+func (c *cursor) rest() []byte {
+	if c.err != nil {
+		return nil
+	}
+	b := copySlice(c.data[c.off:])
+	c.off = len(c.data)
+	return b
+}
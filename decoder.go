@@ -0,0 +1,342 @@
+package xbeeapi
+
+import (
+	"io"
+	"iter"
+	"time"
+)
+
+// readerBufferSize is the initial size of the Decoder's ring buffer and
+// readChunkSize the most bytes unescaped per read in APIModeEscaped.
+const (
+	readerBufferSize = 4096
+	readChunkSize    = 1024
+)
+
+// maxConsecutiveEmptyReads is how many reads in a row may return neither
+// bytes nor an error before the Decoder reports io.ErrNoProgress, as
+// bufio does.
+const maxConsecutiveEmptyReads = 100
+
+// DefaultMaxFrameLength is the largest frame data length a Decoder
+// accepts unless set with SetMaxFrameLength or WithMaxFrameLength.
+const DefaultMaxFrameLength = 2048
+
+// DefaultInterByteTimeout is how long XBeeAPI waits for the rest of a
+// partial frame unless set with WithInterByteTimeout.
+const DefaultInterByteTimeout = time.Second
+
+// DiscardReason tells why a Decoder discarded bytes.
+type DiscardReason byte
+
+const (
+	// DiscardNoDelimiter is used for bytes before a start delimiter.
+	DiscardNoDelimiter DiscardReason = iota
+	// DiscardBadLength is used when a start delimiter is followed by a
+	// length of zero or above the maximum frame length, or by a length
+	// that runs past a complete frame buffered after it.
+	DiscardBadLength
+	// DiscardBadChecksum is used when the checksum of the bytes following
+	// a start delimiter does not match.
+	DiscardBadChecksum
+	// DiscardStalled is used when the rest of a partial frame did not
+	// arrive within the inter-byte timeout.
+	DiscardStalled
+)
+
+func (r DiscardReason) String() string {
+	switch r {
+	case DiscardNoDelimiter:
+		return "no start delimiter"
+	case DiscardBadLength:
+		return "bad length"
+	case DiscardBadChecksum:
+		return "bad checksum"
+	case DiscardStalled:
+		return "stalled partial frame"
+	}
+	return "unknown"
+}
+
+// Discard describes a range of bytes a Decoder skipped while looking for
+// frames. Offset counts the bytes the Decoder has read, after AP=2
+// unescaping.
+type Discard struct {
+	Offset int64
+	Data   []byte
+	Reason DiscardReason
+}
+
+// A Decoder reads frames from an input stream, such as a serial port or a
+// capture of one. Bytes that do not form a valid frame are skipped: a
+// frame is only accepted once its checksum is valid, and a start
+// delimiter that turns out not to begin a frame, because of a bad
+// checksum, a length of zero or above the maximum frame length, a
+// length running past a complete frame that follows it, or because the
+// rest of the frame stalled for longer than the inter-byte timeout, is
+// discarded alone and the bytes after it are scanned again,
+// so frames hidden behind it are not lost.
+type Decoder struct {
+	r    io.Reader
+	ring ringBuffer
+	pool framePool
+	// frames and chunk are reused by every read; queue holds the frames
+	// of the last read not yet returned by Decode.
+	frames []*Frame
+	chunk  []byte
+	queue  []*Frame
+	err    error
+
+	mode             APIMode
+	escaped          bool
+	maxFrameLength   int
+	interByteTimeout time.Duration
+	onDiscard        func(Discard)
+	lastByte         time.Time
+	offset           int64
+	empty            int
+}
+
+// NewDecoder returns a Decoder reading from r in APIModeUnescaped, with
+// no inter-byte timeout.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r:              r,
+		ring:           newRingBuffer(readerBufferSize),
+		mode:           APIModeUnescaped,
+		maxFrameLength: DefaultMaxFrameLength,
+	}
+}
+
+// SetAPIMode sets the framing of the bytes read from now on. Only
+// APIModeEscaped removes escaping; every other mode reads frames as is.
+func (d *Decoder) SetAPIMode(mode APIMode) {
+	d.mode = mode
+}
+
+// APIMode returns the framing the Decoder reads.
+func (d *Decoder) APIMode() APIMode {
+	return d.mode
+}
+
+// SetMaxFrameLength sets the largest frame data length accepted. Longer
+// lengths are taken as a false start delimiter.
+func (d *Decoder) SetMaxFrameLength(n int) {
+	d.maxFrameLength = n
+}
+
+// SetInterByteTimeout sets how long the Decoder waits for the rest of a
+// partial frame before it gives up on it and looks for the next start
+// delimiter. The check is made when bytes arrive again. Zero waits
+// forever.
+func (d *Decoder) SetInterByteTimeout(timeout time.Duration) {
+	d.interByteTimeout = timeout
+}
+
+// SetDiscardHook calls fn for every range of bytes the Decoder skips.
+func (d *Decoder) SetDiscardHook(fn func(Discard)) {
+	d.onDiscard = fn
+}
+
+// Reset discards any buffered input, frames not yet returned and pending
+// error, and makes the Decoder read from r. Settings are kept.
+func (d *Decoder) Reset(r io.Reader) {
+	d.r = r
+	d.ring.discard(d.ring.len())
+	clear(d.queue)
+	d.queue = nil
+	d.err = nil
+	d.escaped = false
+	d.lastByte = time.Time{}
+	d.offset = 0
+	d.empty = 0
+}
+
+// Decode returns the next frame, reading from the input as needed. Read
+// errors are returned once all frames read before them are decoded; the
+// Decoder can be used again after a temporary error such as a timeout.
+// At the end of the input Decode returns io.EOF, and after reads that
+// keep returning neither bytes nor an error, io.ErrNoProgress. The frame
+// returned belongs to the caller.
+func (d *Decoder) Decode() (*Frame, error) {
+	for len(d.queue) == 0 {
+		if d.err != nil {
+			err := d.err
+			d.err = nil
+			return nil, err
+		}
+		d.queue, d.err = d.readFrames()
+	}
+
+	f := d.queue[0]
+	d.queue[0] = nil
+	d.queue = d.queue[1:]
+	return f.clone(), nil
+}
+
+// Frames returns an iterator over the remaining frames. It ends at
+// io.EOF; any other error is yielded with a nil frame and ends it too.
+func (d *Decoder) Frames() iter.Seq2[*Frame, error] {
+	return func(yield func(*Frame, error) bool) {
+		for {
+			f, err := d.Decode()
+			if err == io.EOF {
+				return
+			}
+			if !yield(f, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// fill reads once into the ring buffer. Unescaped bytes are read straight
+// into the buffer; escaped ones go through ingest.
+func (d *Decoder) fill() (int, error) {
+	if d.mode != APIModeEscaped {
+		n, err := d.r.Read(d.ring.free())
+		d.ring.commit(n)
+		return n, err
+	}
+
+	if d.chunk == nil {
+		d.chunk = make([]byte, readChunkSize)
+	}
+	n, err := d.r.Read(d.chunk)
+	d.ingest(d.chunk[:n])
+	return n, err
+}
+
+// ingest appends raw bytes to the ring buffer, removing API mode 2
+// escaping. An escape byte at the end of b is remembered so escapes split
+// across reads are decoded correctly.
+func (d *Decoder) ingest(b []byte) {
+	if d.mode != APIModeEscaped {
+		d.ring.write(b)
+		return
+	}
+
+	for _, c := range b {
+		switch {
+		case c == frameStartDelimiter:
+			d.escaped = false
+			d.ring.writeByte(c)
+		case d.escaped:
+			d.escaped = false
+			d.ring.writeByte(c ^ frameEscapeXOR)
+		case c == frameEscape:
+			d.escaped = true
+		default:
+			d.ring.writeByte(c)
+		}
+	}
+}
+
+// readFrames reads once and returns the frames completed by the bytes
+// read, along with any read error. The returned slice and frames are
+// reused by the next call.
+func (d *Decoder) readFrames() ([]*Frame, error) {
+	d.pool.recycle()
+	pending := d.ring.len()
+	n, err := d.fill()
+	if n == 0 && err == nil {
+		d.empty++
+		if d.empty >= maxConsecutiveEmptyReads {
+			d.empty = 0
+			err = io.ErrNoProgress
+		}
+	} else {
+		d.empty = 0
+	}
+
+	now := time.Now()
+	stalled := pending > 0 && d.interByteTimeout > 0 && now.Sub(d.lastByte) > d.interByteTimeout
+	if n > 0 {
+		d.lastByte = now
+	}
+
+	clear(d.frames)
+	d.frames = d.frames[:0]
+	ring := &d.ring
+
+	if stalled {
+		d.skip(DiscardStalled)
+	}
+	for ring.len() >= minFrameSize {
+		if ring.at(0) != frameStartDelimiter {
+			d.skip(DiscardNoDelimiter)
+			continue
+		}
+
+		dataLen := int(ring.at(1))<<8 | int(ring.at(2))
+		if dataLen == 0 || dataLen > d.maxFrameLength {
+			d.skip(DiscardBadLength)
+			continue
+		}
+		frameLen := totalFrameLength(uint16(dataLen))
+		if ring.len() < frameLen {
+			if d.frameAhead() {
+				d.skip(DiscardBadLength)
+				continue
+			}
+			ring.reserve(frameLen)
+			break
+		}
+		if ring.sum(3, dataLen+1) != 0xff {
+			d.skip(DiscardBadChecksum)
+			continue
+		}
+
+		frame := d.pool.get(dataLen)
+		ring.copyOut(frame.FrameData.buf, 3)
+		frame.Checksum = ring.at(frameLen - 1)
+		d.frames = append(d.frames, frame)
+		d.consume(frameLen)
+	}
+
+	return d.frames, err
+}
+
+// frameAhead reports whether a start delimiter after the first buffered
+// byte begins a complete frame with a valid checksum, in which case the
+// partial frame at the start of the buffer is taken as a false start
+// rather than waited for.
+func (d *Decoder) frameAhead() bool {
+	ring := &d.ring
+	for i := ring.index(frameStartDelimiter, 1); i >= 0; i = ring.index(frameStartDelimiter, i+1) {
+		if ring.len()-i < minFrameSize {
+			return false
+		}
+		dataLen := int(ring.at(i+1))<<8 | int(ring.at(i+2))
+		if dataLen == 0 || dataLen > d.maxFrameLength || ring.len()-i < totalFrameLength(uint16(dataLen)) {
+			continue
+		}
+		if ring.sum(i+3, dataLen+1) == 0xff {
+			return true
+		}
+	}
+	return false
+}
+
+// skip discards the first buffered byte and everything up to the next
+// start delimiter, and reports them to the discard hook.
+func (d *Decoder) skip(reason DiscardReason) {
+	n := d.ring.index(frameStartDelimiter, 1)
+	if n < 0 {
+		n = d.ring.len()
+	}
+	if n == 0 {
+		return
+	}
+	if d.onDiscard != nil {
+		data := make([]byte, n)
+		d.ring.copyOut(data, 0)
+		d.onDiscard(Discard{Offset: d.offset, Data: data, Reason: reason})
+	}
+	d.consume(n)
+}
+
+func (d *Decoder) consume(n int) {
+	d.ring.discard(n)
+	d.offset += int64(n)
+}
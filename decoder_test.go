@@ -0,0 +1,276 @@
+package xbeeapi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// loopPort replays a byte stream forever, in reads of at most chunk bytes.
+type loopPort struct {
+	data  []byte
+	off   int
+	chunk int
+}
+
+func (p *loopPort) Read(b []byte) (int, error) {
+	if len(b) > p.chunk {
+		b = b[:p.chunk]
+	}
+	n := copy(b, p.data[p.off:])
+	p.off = (p.off + n) % len(p.data)
+	return n, nil
+}
+
+func (p *loopPort) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func rxPacketStream(t testing.TB, payloadLen int, count int, mode APIMode) []byte {
+	payload := make([]byte, payloadLen)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	var stream []byte
+	for i := 0; i < count; i++ {
+		b, err := NewFrame(&RxPacket{Address64: Address64(i), Payload: payload}).SerializeAPIMode(mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream = append(stream, b...)
+	}
+	return stream
+}
+
+func TestDecoderWrapsAndGrows(t *testing.T) {
+	// Odd sized reads make frames straddle the end of the ring buffer, and
+	// the large frame forces it to grow. Frames are only parsed after all
+	// reads, so they must not share buffers with later frames.
+	stream := rxPacketStream(t, 100, 50, APIModeUnescaped)
+	stream = append(stream, rxPacketStream(t, 3*readerBufferSize, 1, APIModeUnescaped)...)
+	dec := NewDecoder(&loopPort{data: stream, chunk: 333})
+	dec.SetMaxFrameLength(0xffff)
+
+	var got []*Frame
+	for len(got) < 51 {
+		f, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, f)
+	}
+	for i, f := range got {
+		rx, err := ParseRxPacket(f.FrameData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i < 50 && (rx.Address64 != Address64(i) || len(rx.Payload) != 100 || rx.Payload[99] != 99) {
+			t.Error("Frame", i, "corrupted:", rx.Address64, len(rx.Payload))
+		}
+	}
+	if rx, _ := ParseRxPacket(got[50].FrameData); len(rx.Payload) != 3*readerBufferSize {
+		t.Error("Expected large frame, got payload length", len(rx.Payload))
+	}
+}
+
+// chunkPort returns one chunk per read, each after delay.
+type chunkPort struct {
+	chunks [][]byte
+	delay  time.Duration
+}
+
+func (p *chunkPort) Read(b []byte) (int, error) {
+	time.Sleep(p.delay)
+	if len(p.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, p.chunks[0])
+	p.chunks = p.chunks[1:]
+	return n, nil
+}
+
+func (p *chunkPort) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func TestDecoderResync(t *testing.T) {
+	frame, _ := NewFrame(&RxPacket{Address64: 0x0013a200000000aa, Payload: []byte("hi")}).Serialize()
+
+	tests := []struct {
+		name    string
+		chunks  [][]byte
+		discard []Discard
+	}{
+		{
+			name:    "garbage",
+			chunks:  [][]byte{concat([]byte{0x01, 0x02}, frame)},
+			discard: []Discard{{0, []byte{0x01, 0x02}, DiscardNoDelimiter}},
+		},
+		{
+			name:    "bad checksum",
+			chunks:  [][]byte{concat([]byte{0x7e, 0x00, 0x05}, frame)},
+			discard: []Discard{{0, []byte{0x7e, 0x00, 0x05}, DiscardBadChecksum}},
+		},
+		{
+			name:    "bad length",
+			chunks:  [][]byte{concat([]byte{0x7e, 0xff, 0xff, 0x00}, frame)},
+			discard: []Discard{{0, []byte{0x7e, 0xff, 0xff, 0x00}, DiscardBadLength}},
+		},
+		{
+			name:    "stalled",
+			chunks:  [][]byte{{0x7e, 0x00, 0x40, 0x90}, frame},
+			discard: []Discard{{0, []byte{0x7e, 0x00, 0x40, 0x90}, DiscardStalled}},
+		},
+		{
+			name:    "length past a frame",
+			chunks:  [][]byte{concat([]byte{0x7e, 0x00, 0x40, 0x90}, frame)},
+			discard: []Discard{{0, []byte{0x7e, 0x00, 0x40, 0x90}, DiscardBadLength}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var discards []Discard
+			dec := NewDecoder(&chunkPort{chunks: tc.chunks, delay: 20 * time.Millisecond})
+			dec.SetInterByteTimeout(10 * time.Millisecond)
+			dec.SetDiscardHook(func(d Discard) { discards = append(discards, d) })
+
+			var got []*Frame
+			for f, err := range dec.Frames() {
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, f)
+			}
+
+			if len(got) != 1 {
+				t.Fatal("Expected the real frame, got", len(got))
+			}
+			if rx, err := ParseRxPacket(got[0].FrameData); err != nil || string(rx.Payload) != "hi" {
+				t.Error("Unexpected frame", rx, err)
+			}
+			if !reflect.DeepEqual(discards, tc.discard) {
+				t.Error("Expected discards", tc.discard, "got", discards)
+			}
+		})
+	}
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	for _, bc := range []struct {
+		payload int
+		mode    APIMode
+	}{
+		{16, APIModeUnescaped},
+		{255, APIModeUnescaped},
+		{255, APIModeEscaped},
+	} {
+		b.Run(fmt.Sprintf("payload=%d/AP=%d", bc.payload, bc.mode), func(b *testing.B) {
+			stream := rxPacketStream(b, bc.payload, 64, bc.mode)
+			dec := NewDecoder(&loopPort{data: stream, chunk: 4096})
+			dec.SetAPIMode(bc.mode)
+			b.SetBytes(int64(len(stream) / 64))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for n := 0; n < b.N; n++ {
+				if _, err := dec.Decode(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkReadFrames measures the reader's own path, whose frames are
+// reused by the next read instead of being handed to the caller.
+func BenchmarkReadFrames(b *testing.B) {
+	stream := rxPacketStream(b, 255, 64, APIModeUnescaped)
+	dec := NewDecoder(&loopPort{data: stream, chunk: len(stream) / 8})
+	b.SetBytes(int64(len(stream) / 8))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		if _, err := dec.readFrames(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestFramePool(t *testing.T) {
+	stream := rxPacketStream(t, 16, 4, APIModeUnescaped)
+	dec := NewDecoder(&loopPort{data: stream, chunk: len(stream)})
+
+	frames, err := dec.readFrames()
+	if err != nil || len(frames) != 4 {
+		t.Fatal("Expected 4 frames, got", len(frames), err)
+	}
+	first := frames[0].FrameData
+	if frames, _ = dec.readFrames(); frames[len(frames)-1].FrameData != first {
+		t.Error("Expected the frames of the last read to be reused")
+	}
+
+	f, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.FrameData.buf) != cap(f.FrameData.buf) {
+		t.Error("Expected a decoded frame to have a buffer of its own")
+	}
+	for _, pf := range dec.pool.used {
+		if f.FrameData == &pf.data {
+			t.Error("Decoded frame shared with the reader")
+		}
+	}
+}
+
+func TestEncodeRawFrame(t *testing.T) {
+	var out bytes.Buffer
+	enc := NewEncoder(&out)
+	f := NewFrame(&ATCommand{FrameID: 1, Command: "AP"})
+	f.Length, f.Checksum = 0x0005, 0x00
+	if err := enc.EncodeFrame(f); err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{0x7e, 0x00, 0x05, 0x08, 0x01, 0x41, 0x50, 0x00}
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Error("Expected the raw frame as is:", expected, "Got:", out.Bytes())
+	}
+}
+
+func TestEncoderDecoderRoundTrip(t *testing.T) {
+	frames := fuzzSeedFrames()
+	for _, mode := range []APIMode{APIModeUnescaped, APIModeEscaped} {
+		var stream bytes.Buffer
+		enc := NewEncoder(&stream)
+		enc.SetAPIMode(mode)
+		for _, fd := range frames {
+			if err := enc.Encode(fd); err != nil {
+				t.Fatal(err)
+			}
+			want, _ := NewFrame(fd).SerializeAPIMode(mode)
+			if !bytes.HasSuffix(stream.Bytes(), want) {
+				t.Fatal("Encoder and SerializeAPIMode disagree in mode", mode)
+			}
+		}
+
+		dec := NewDecoder(&stream)
+		dec.SetAPIMode(mode)
+		i := 0
+		for f, err := range dec.Frames() {
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(f.FrameData.buf, frames[i].RawFrameData().buf) {
+				t.Error("Frame", i, "changed in mode", mode)
+			}
+			i++
+		}
+		if i != len(frames) {
+			t.Error("Expected", len(frames), "frames in mode", mode, "got", i)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package xbeeapi
+
+import (
+	"context"
+	"time"
+)
+
+// discoveryTimeoutMargin is added to the radio's NT node discovery timeout
+// to allow for the last responses to arrive over the serial port.
+const discoveryTimeoutMargin = time.Second
+
+// nodeDiscoveryTimeoutUnit is the unit of the NT parameter.
+const nodeDiscoveryTimeoutUnit = 100 * time.Millisecond
+
+// DiscoverNodes runs a network discovery (ND) and returns every node that
+// answered within the radio's NT timeout.
+func (api *XBeeAPI) DiscoverNodes(ctx context.Context) ([]*NodeInfo, error) {
+	nodes := []*NodeInfo(nil)
+	err := api.DiscoverNodesFunc(ctx, func(ni *NodeInfo) {
+		nodes = append(nodes, ni)
+	})
+
+	return nodes, err
+}
+
+// DiscoverNodesFunc runs a network discovery (ND), calling found for each
+// node as its response arrives. It returns once the radio's NT timeout
+// has passed, or ctx is done. Responses are parsed for the protocol set
+// with WithProtocol: one that cannot be parsed ends the discovery with
+// its error.
+func (api *XBeeAPI) DiscoverNodesFunc(ctx context.Context, found func(*NodeInfo)) error {
+	nt, err := api.GetDuration(ctx, "NT")
+	if err != nil {
+		return err
+	}
+	timeout := nt + discoveryTimeoutMargin
+	discoveryCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	w, err := api.pending.addStream(discoveryCtx)
+	if err != nil {
+		return err
+	}
+	defer api.pending.remove(w)
+
+	if _, err := api.sendFrames(discoveryCtx, &ATCommand{FrameID: w.id, Command: "ND"}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-w.ready:
+			for _, f := range w.take() {
+				resp, err := ParseATCommandResponse(f.FrameData)
+				if err != nil {
+					continue
+				}
+				if resp.Status != ATCommandOK {
+					return &ATCommandStatusError{Command: resp.Command, Status: resp.Status}
+				}
+				if len(resp.Params) == 0 {
+					// Some firmwares end the discovery with an empty response.
+					return nil
+				}
+				ni, err := ParseProtocolNodeDiscoveryResponse(api.protocol, resp.Params)
+				if err != nil {
+					return err
+				}
+				found(ni)
+			}
+		case <-w.failed:
+			return w.err
+		case <-discoveryCtx.Done():
+			// Reaching the discovery timeout is the normal way to finish.
+			return ctx.Err()
+		}
+	}
+}
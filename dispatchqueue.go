@@ -0,0 +1,159 @@
+package xbeeapi
+
+import "sync"
+
+// DefaultQueueSize is the number of frames buffered for the ReadCallback
+// and for each subscriber unless configured otherwise.
+const DefaultQueueSize = 64
+
+// OverflowPolicy decides what happens when a frame is dispatched to a
+// consumer whose queue is full.
+type OverflowPolicy byte
+
+const (
+	// OverflowDropOldest discards the oldest queued frame to make room.
+	OverflowDropOldest OverflowPolicy = iota
+	// OverflowDropNewest discards the frame being dispatched.
+	OverflowDropNewest
+	// OverflowBlock makes the reader wait for room. A slow consumer then
+	// stalls reading from the port.
+	OverflowBlock
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowDropOldest:
+		return "drop oldest"
+	case OverflowDropNewest:
+		return "drop newest"
+	case OverflowBlock:
+		return "block"
+	}
+	return "unknown"
+}
+
+// dispatchQueue is a bounded queue drained by its own goroutine, so the
+// reader never runs consumer code.
+type dispatchQueue[E any] struct {
+	mu     *sync.Mutex
+	cond   *sync.Cond
+	items  []E
+	head   int
+	n      int
+	policy OverflowPolicy
+	closed bool
+	onDrop func()
+}
+
+// newDispatchQueue starts a queue of the given size that calls handle for
+// each item in order, and onDrop for each item discarded on overflow.
+func newDispatchQueue[E any](size int, policy OverflowPolicy, onDrop func(), handle func(E)) *dispatchQueue[E] {
+	if size < 1 {
+		size = 1
+	}
+	q := &dispatchQueue[E]{
+		mu:     &sync.Mutex{},
+		items:  make([]E, size),
+		policy: policy,
+		onDrop: onDrop,
+	}
+	q.cond = sync.NewCond(q.mu)
+	go q.run(handle)
+
+	return q
+}
+
+// push queues e according to the queue's overflow policy.
+func (q *dispatchQueue[E]) push(e E) {
+	q.put(e, q.policy)
+}
+
+// pushLast queues e and closes the queue. Unless the policy is
+// OverflowBlock, e replaces the oldest item if the queue is full.
+func (q *dispatchQueue[E]) pushLast(e E) {
+	policy := q.policy
+	if policy == OverflowDropNewest {
+		policy = OverflowDropOldest
+	}
+	q.put(e, policy)
+	q.close(false)
+}
+
+func (q *dispatchQueue[E]) put(e E, policy OverflowPolicy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.n == len(q.items) && !q.closed {
+		switch policy {
+		case OverflowDropNewest:
+			q.drop()
+			return
+		case OverflowDropOldest:
+			q.pop()
+			q.drop()
+		default:
+			q.cond.Wait()
+		}
+	}
+	if q.closed {
+		return
+	}
+
+	q.items[(q.head+q.n)%len(q.items)] = e
+	q.n++
+	q.cond.Broadcast()
+}
+
+func (q *dispatchQueue[E]) pop() (e E) {
+	var zero E
+	e = q.items[q.head]
+	q.items[q.head] = zero
+	q.head = (q.head + 1) % len(q.items)
+	q.n--
+	return
+}
+
+func (q *dispatchQueue[E]) drop() {
+	if q.onDrop != nil {
+		q.onDrop()
+	}
+}
+
+func (q *dispatchQueue[E]) run(handle func(E)) {
+	for {
+		q.mu.Lock()
+		for q.n == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if q.n == 0 {
+			q.mu.Unlock()
+			return
+		}
+		e := q.pop()
+		q.cond.Broadcast()
+		q.mu.Unlock()
+
+		handle(e)
+	}
+}
+
+// close stops accepting items and wakes a blocked push. Items already
+// queued are still handled unless discard is set.
+func (q *dispatchQueue[E]) close(discard bool) {
+	q.mu.Lock()
+	q.closed = true
+	if discard {
+		for q.n > 0 {
+			q.pop()
+		}
+	}
+	q.cond.Broadcast()
+	q.mu.Unlock()
+}
+
+func (q *dispatchQueue[E]) len() (n int) {
+	q.mu.Lock()
+	n = q.n
+	q.mu.Unlock()
+	return
+}
@@ -0,0 +1,87 @@
+package xbeeapi
+
+import (
+	"io"
+	"sync"
+)
+
+// An Encoder writes frames to an output stream. Each frame is written
+// with a single Write call, and an Encoder is safe for concurrent use, so
+// frames written from several goroutines never interleave.
+type Encoder struct {
+	mu   sync.Mutex
+	w    io.Writer
+	mode APIMode
+	buf  []byte
+}
+
+// NewEncoder returns an Encoder writing to w in APIModeUnescaped.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, mode: APIModeUnescaped}
+}
+
+// SetAPIMode sets the framing of the frames written from now on. Only
+// APIModeEscaped adds escaping; every other mode writes frames as is.
+func (e *Encoder) SetAPIMode(mode APIMode) {
+	e.mu.Lock()
+	e.mode = mode
+	e.mu.Unlock()
+}
+
+// APIMode returns the framing the Encoder writes.
+func (e *Encoder) APIMode() (m APIMode) {
+	e.mu.Lock()
+	m = e.mode
+	e.mu.Unlock()
+	return
+}
+
+// Reset makes the Encoder write to w.
+func (e *Encoder) Reset(w io.Writer) {
+	e.mu.Lock()
+	e.w = w
+	e.mu.Unlock()
+}
+
+// Encode writes fd as a frame.
+func (e *Encoder) Encode(fd FrameData) error {
+	return e.EncodeFrame(NewFrame(fd))
+}
+
+// EncodeFrame writes f as is, with its Length and Checksum fields even if
+// they do not match its frame data. Frames from NewFrame or a Decoder
+// always match.
+func (e *Encoder) EncodeFrame(f *Frame) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.buf = appendFrame(e.buf[:0], f, e.mode)
+	_, err := e.w.Write(e.buf)
+	return err
+}
+
+// appendFrame appends the serialized frame f to dst, escaped for mode.
+func appendFrame(dst []byte, f *Frame, mode APIMode) []byte {
+	n, data := f.Length, f.FrameData.buf
+	dst = append(dst, frameStartDelimiter)
+	if mode != APIModeEscaped {
+		dst = append(dst, byte(n>>8), byte(n))
+		dst = append(dst, data...)
+		return append(dst, f.Checksum)
+	}
+
+	for _, c := range [2]byte{byte(n >> 8), byte(n)} {
+		dst = appendEscaped(dst, c)
+	}
+	for _, c := range data {
+		dst = appendEscaped(dst, c)
+	}
+	return appendEscaped(dst, f.Checksum)
+}
+
+func appendEscaped(dst []byte, c byte) []byte {
+	if needsEscape(c) {
+		return append(dst, frameEscape, c^frameEscapeXOR)
+	}
+	return append(dst, c)
+}
@@ -0,0 +1,171 @@
+package xbeeapi
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors for use with errors.Is. Every error returned for a
+// malformed frame matches ErrInvalidFrame as well as the sentinel of its
+// own kind.
+var (
+	ErrInvalidFrame         = errors.New("Invalid frame")
+	ErrChecksum             = errors.New("Checksum mismatch")
+	ErrLength               = errors.New("Length mismatch")
+	ErrDelimiter            = errors.New("Bad start delimiter")
+	ErrTruncated            = errors.New("Truncated field")
+	ErrUnsupportedFrameType = errors.New("Unsupported frame type")
+	ErrFrameType            = errors.New("Unexpected frame type")
+	ErrATCommandStatus      = errors.New("AT command failed")
+	ErrDeliveryFailed       = errors.New("Transmit delivery failed")
+	ErrNotAssociated        = errors.New("Not associated with a network")
+)
+
+// ChecksumError is returned for a frame whose checksum byte does not match
+// its frame data.
+type ChecksumError struct {
+	Expected byte
+	Actual   byte
+	Data     []byte
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("Checksum mismatch: expected %02x, got %02x", e.Expected, e.Actual)
+}
+
+func (e *ChecksumError) Is(target error) bool {
+	return target == ErrChecksum || target == ErrInvalidFrame
+}
+
+// LengthError is returned for a frame whose length field does not match
+// the length of its frame data.
+type LengthError struct {
+	Expected int
+	Actual   int
+	Data     []byte
+}
+
+func (e *LengthError) Error() string {
+	return fmt.Sprintf("Expected length %d, received %d", e.Expected, e.Actual)
+}
+
+func (e *LengthError) Is(target error) bool {
+	return target == ErrLength || target == ErrInvalidFrame
+}
+
+// DelimiterError is returned for a frame that does not start with the
+// start delimiter 0x7e.
+type DelimiterError struct {
+	Delimiter byte
+	Data      []byte
+}
+
+func (e *DelimiterError) Error() string {
+	return fmt.Sprintf("Invalid start delimiter: %02x", e.Delimiter)
+}
+
+func (e *DelimiterError) Is(target error) bool {
+	return target == ErrDelimiter || target == ErrInvalidFrame
+}
+
+// TruncatedError is returned when Data ends before the Size bytes of
+// Field starting at Offset.
+type TruncatedError struct {
+	Field  string
+	Offset int
+	Size   int
+	Data   []byte
+}
+
+func (e *TruncatedError) Error() string {
+	left := len(e.Data) - e.Offset
+	if left < 0 {
+		left = 0
+	}
+	return fmt.Sprintf("Truncated %s at offset %d: need %d bytes, %d left", e.Field, e.Offset, e.Size, left)
+}
+
+func (e *TruncatedError) Is(target error) bool {
+	return target == ErrTruncated || target == ErrInvalidFrame
+}
+
+// UnsupportedFrameTypeError is returned by ParseFrameData for frame types
+// it cannot decode.
+type UnsupportedFrameTypeError struct {
+	FrameType byte
+	Data      []byte
+}
+
+func (e *UnsupportedFrameTypeError) Error() string {
+	return fmt.Sprintf("Unsupported frame type: %02x", e.FrameType)
+}
+
+func (e *UnsupportedFrameTypeError) Is(target error) bool {
+	return target == ErrUnsupportedFrameType || target == ErrInvalidFrame
+}
+
+// FrameTypeError is returned by a Parse function given frame data of
+// another frame type.
+type FrameTypeError struct {
+	Name     string
+	Expected byte
+	Actual   byte
+	Data     []byte
+}
+
+func (e *FrameTypeError) Error() string {
+	return fmt.Sprintf("Expecting frame type %s (%02x), got %02x", e.Name, e.Expected, e.Actual)
+}
+
+func (e *FrameTypeError) Is(target error) bool {
+	return target == ErrFrameType || target == ErrInvalidFrame
+}
+
+// checkFrameType returns a *FrameTypeError unless rfd is of frame type
+// expected.
+func checkFrameType(rfd *RawFrameData, expected byte, name string) error {
+	if rfd.Len() == 0 {
+		return &TruncatedError{Field: "frame type", Size: 1, Data: rfd.buf}
+	}
+	if rfd.FrameType() != expected {
+		return &FrameTypeError{Name: name, Expected: expected, Actual: rfd.FrameType(), Data: rfd.buf}
+	}
+	return nil
+}
+
+// DeliveryError is returned when a TransmitStatus reports that a
+// transmission was not delivered.
+type DeliveryError struct {
+	FrameID         byte
+	Address16       Address16
+	RetryCount      byte
+	DeliveryStatus  DeliveryStatus
+	DiscoveryStatus DiscoveryStatus
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("Transmit delivery to %s failed: %s", e.Address16, e.DeliveryStatus.Description())
+}
+
+func (e *DeliveryError) Is(target error) bool {
+	return target == ErrDeliveryFailed
+}
+
+// AssociationError is returned when the radio did not join a network in
+// time. Status is its last known association indication.
+type AssociationError struct {
+	Status AssociationStatus
+	Err    error
+}
+
+func (e *AssociationError) Error() string {
+	return fmt.Sprintf("Not associated with a network: %s", e.Status.Description())
+}
+
+func (e *AssociationError) Is(target error) bool {
+	return target == ErrNotAssociated
+}
+
+func (e *AssociationError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,48 @@
+package xbeeapi
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFrameErrors(t *testing.T) {
+	valid := []byte{0x7e, 0x00, 0x04, 0x08, 0x01, 0x4d, 0x59, 0x50}
+
+	var cs *ChecksumError
+	_, err := Deserialize([]byte{0x7e, 0x00, 0x04, 0x08, 0x01, 0x4d, 0x59, 0x51})
+	if !errors.Is(err, ErrChecksum) || !errors.Is(err, ErrInvalidFrame) || !errors.As(err, &cs) {
+		t.Fatal("Expected checksum error, got", err)
+	}
+	if cs.Expected != 0x50 || cs.Actual != 0x51 || len(cs.Data) != len(valid) {
+		t.Error("Unexpected checksum error", cs.Expected, cs.Actual, cs.Data)
+	}
+
+	var de *DelimiterError
+	if _, err := Deserialize(append([]byte{0x7f}, valid[1:]...)); !errors.Is(err, ErrDelimiter) || !errors.As(err, &de) || de.Delimiter != 0x7f {
+		t.Error("Expected delimiter error, got", err)
+	}
+
+	var le *LengthError
+	if _, err := Deserialize([]byte{0x7e, 0x00, 0x05, 0x08, 0x01, 0x4d, 0x59, 0x50}); !errors.Is(err, ErrLength) || !errors.As(err, &le) || le.Expected != 5 || le.Actual != 4 {
+		t.Error("Expected length error, got", err)
+	}
+
+	var te *TruncatedError
+	if _, err := Deserialize(valid[:2]); !errors.Is(err, ErrTruncated) || !errors.As(err, &te) {
+		t.Error("Expected truncated error, got", err)
+	}
+
+	var ue *UnsupportedFrameTypeError
+	if _, err := ParseFrameData(NewRawFrameData(0xfe, 0x01)); !errors.Is(err, ErrUnsupportedFrameType) || !errors.As(err, &ue) || ue.FrameType != 0xfe {
+		t.Error("Expected unsupported frame type error, got", err)
+	}
+
+	var fte *FrameTypeError
+	if _, err := ParseRxPacket(NewRawFrameData(FrameTypeModemStatus, 0x00)); !errors.Is(err, ErrFrameType) || !errors.As(err, &fte) || fte.Actual != FrameTypeModemStatus {
+		t.Error("Expected frame type error, got", err)
+	}
+
+	if _, err := ParseATCommandResponse(NewRawFrameData(FrameTypeATCommandResponse, 0x01)); !errors.Is(err, ErrTruncated) {
+		t.Error("Expected truncated error, got", err)
+	}
+}
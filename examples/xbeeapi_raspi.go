@@ -1,31 +1,29 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/tarm/serial"
 	"github.com/zenbulabs/xbeeapi"
 	"time"
 )
 
-func readCb(frame *xbeeapi.Frame, status xbeeapi.XBeeReadStatus) {
-	fmt.Println("FrameLength:", frame.Length, "FrameType:", frame.FrameData.FrameType(), "Data:", frame.FrameData.Data(), "Checksum:", frame.Checksum)
-	if frame.FrameData.FrameType() == xbeeapi.FrameTypeATCommand {
-		atcommand, _ := xbeeapi.ParseATCommand(frame.FrameData)
-		fmt.Println("FrameID:", atcommand.FrameID, "CmdType:", atcommand.Command, "Params:", atcommand.Params)
-	} else if frame.FrameData.FrameType() == xbeeapi.FrameTypeATCommandResponse {
-		//TODO
-	}
-	fmt.Println("Status:", status.StatusCode, "Error:", status.Error)
-}
-
 func main() {
 	port, err := serial.OpenPort(&serial.Config{Name: "/dev/ttyAMA0", Baud: 9600})
 	if err != nil {
 		fmt.Println("Error with port:", err)
 		return
 	}
-	api := xbeeapi.NewXBeeAPI(port, readCb)
-	api.Start()
+	api := xbeeapi.NewXBeeAPI(port, nil)
+
+	xbeeapi.Subscribe(api, func(atr *xbeeapi.ATCommandResponse) {
+		fmt.Println("FrameID:", atr.FrameID, "CmdType:", atr.Command, "Status:", xbeeapi.ATCommandStatusDescription(atr.Status), "Params:", atr.Params)
+	})
+	xbeeapi.Subscribe(api, func(rx *xbeeapi.RxPacket) {
+		fmt.Println("From:", rx.Address64, "Data:", rx.Payload)
+	})
+
+	api.Start(context.Background())
 	time.Sleep(100 * time.Millisecond)
 
 	atcommands := []string{
@@ -33,12 +31,10 @@ func main() {
 		"AP",
 	}
 
-	for i, at := range atcommands {
-		api.SendFrames(&xbeeapi.ATCommand{FrameID: byte(i + 1), Command: at})
+	for _, at := range atcommands {
+		api.SendFrames(xbeeapi.AutoFrameID(&xbeeapi.ATCommand{Command: at}))
 	}
 	time.Sleep(5000 * time.Millisecond)
 
-	api.Finish()
-
-	time.Sleep(5000 * time.Millisecond)
+	api.Close()
 }
@@ -0,0 +1,216 @@
+package xbeeapi
+
+import "fmt"
+
+// JoinStatus is the step of a Zigbee join reported by an extended modem
+// status frame, sent when verbose join information is enabled.
+type JoinStatus byte
+
+const (
+	JoinRejoin             JoinStatus = 0x00
+	JoinStackStatus        JoinStatus = 0x01
+	JoinJoining            JoinStatus = 0x02
+	JoinJoined             JoinStatus = 0x03
+	JoinBeaconResponse     JoinStatus = 0x04
+	JoinRejectStackProfile JoinStatus = 0x05
+	JoinRejectPANID        JoinStatus = 0x06
+	JoinRejectNotJoinable  JoinStatus = 0x07
+	JoinPANIDMatch         JoinStatus = 0x08
+	JoinRejectLQI          JoinStatus = 0x09
+	JoinBeaconSaved        JoinStatus = 0x0a
+	JoinAIChange           JoinStatus = 0x0b
+	JoinPermitJoin         JoinStatus = 0x0c
+	JoinScanning           JoinStatus = 0x0d
+	JoinScanError          JoinStatus = 0x0e
+	JoinRequest            JoinStatus = 0x0f
+)
+
+func (s JoinStatus) Description() string {
+	switch s {
+	case JoinRejoin:
+		return "Rejoin"
+	case JoinStackStatus:
+		return "Stack Status"
+	case JoinJoining:
+		return "Joining"
+	case JoinJoined:
+		return "Joined"
+	case JoinBeaconResponse:
+		return "Beacon Response"
+	case JoinRejectStackProfile:
+		return "Beacon Rejected: Stack Profile Mismatch"
+	case JoinRejectPANID:
+		return "Beacon Rejected: Extended PAN ID Mismatch"
+	case JoinRejectNotJoinable:
+		return "Beacon Rejected: Not Allowing Joining"
+	case JoinPANIDMatch:
+		return "Beacon Extended PAN ID Match"
+	case JoinRejectLQI:
+		return "Beacon Rejected: Link Quality Too Low"
+	case JoinBeaconSaved:
+		return "Beacon Saved"
+	case JoinAIChange:
+		return "Association Indication Changed"
+	case JoinPermitJoin:
+		return "Permit Joining"
+	case JoinScanning:
+		return "Scanning Channel"
+	case JoinScanError:
+		return "Scan Error"
+	case JoinRequest:
+		return "Join Request Sent"
+	}
+
+	return fmt.Sprintf("Unknown Join Status: %x", byte(s))
+}
+
+// ExtendedModemStatus is an Extended Modem Status (0x98) frame. Data
+// depends on Status; the accessors decode it for the steps that carry a
+// PAN ID, channel, beacon or association indication.
+type ExtendedModemStatus struct {
+	Status JoinStatus
+	Data   []byte
+}
+
+// JoinBeacon is the beacon attached to a JoinBeaconResponse step.
+type JoinBeacon struct {
+	Address16     Address16
+	ExtendedPANID uint64
+	Channel       byte
+	AllowJoin     bool
+	StackProfile  byte
+	LQI           byte
+}
+
+func ParseExtendedModemStatus(rfd *RawFrameData) (*ExtendedModemStatus, error) {
+	c, err := newFrameCursor(rfd, FrameTypeXBExtendedModemStatus, "ExtendedModemStatus")
+	if err != nil {
+		return nil, err
+	}
+	ems := &ExtendedModemStatus{
+		Status: JoinStatus(c.uint8("Status")),
+		Data:   c.rest(),
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return ems, nil
+}
+
+// Beacon decodes the beacon of a JoinBeaconResponse step.
+func (ems *ExtendedModemStatus) Beacon() (*JoinBeacon, error) {
+	if ems.Status != JoinBeaconResponse {
+		return nil, &FrameParseError{msg: fmt.Sprintf("No beacon in join status %s", ems.Status.Description())}
+	}
+	c := newCursor(ems.Data)
+	b := &JoinBeacon{
+		Address16:     c.address16("Address16"),
+		ExtendedPANID: c.uint64("ExtendedPANID"),
+		Channel:       c.uint8("Channel"),
+		AllowJoin:     c.uint8("AllowJoin") != 0,
+		StackProfile:  c.uint8("StackProfile"),
+		LQI:           c.uint8("LQI"),
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return b, nil
+}
+
+// ExtendedPANID returns the extended PAN ID of a beacon response or of a
+// beacon matched or rejected by PAN ID.
+func (ems *ExtendedModemStatus) ExtendedPANID() (uint64, bool) {
+	switch ems.Status {
+	case JoinRejectPANID, JoinPANIDMatch:
+		c := newCursor(ems.Data)
+		id := c.uint64("ExtendedPANID")
+		return id, c.err == nil
+	case JoinBeaconResponse:
+		b, err := ems.Beacon()
+		if err != nil {
+			return 0, false
+		}
+		return b.ExtendedPANID, true
+	}
+	return 0, false
+}
+
+// Channel returns the channel being scanned, or of a beacon response.
+func (ems *ExtendedModemStatus) Channel() (byte, bool) {
+	switch ems.Status {
+	case JoinScanning:
+		if len(ems.Data) == 1 {
+			return ems.Data[0], true
+		}
+	case JoinBeaconResponse:
+		if b, err := ems.Beacon(); err == nil {
+			return b.Channel, true
+		}
+	}
+	return 0, false
+}
+
+// Association returns the new association indication of a JoinAIChange
+// step.
+func (ems *ExtendedModemStatus) Association() (AssociationStatus, bool) {
+	if ems.Status != JoinAIChange || len(ems.Data) != 1 {
+		return 0, false
+	}
+	return AssociationStatus(ems.Data[0]), true
+}
+
+// Details describes the data of the step.
+func (ems *ExtendedModemStatus) Details() string {
+	switch ems.Status {
+	case JoinBeaconResponse:
+		if b, err := ems.Beacon(); err == nil {
+			return fmt.Sprintf("from %s, extended PAN ID %016x, channel %d, allow join %t, stack profile %d, LQI %d",
+				b.Address16, b.ExtendedPANID, b.Channel, b.AllowJoin, b.StackProfile, b.LQI)
+		}
+	case JoinRejectPANID, JoinPANIDMatch:
+		if id, ok := ems.ExtendedPANID(); ok {
+			return fmt.Sprintf("extended PAN ID %016x", id)
+		}
+	case JoinScanning:
+		if ch, ok := ems.Channel(); ok {
+			return fmt.Sprintf("channel %d", ch)
+		}
+	case JoinAIChange:
+		if ai, ok := ems.Association(); ok {
+			return ai.Description()
+		}
+	case JoinRejectLQI:
+		if len(ems.Data) == 1 {
+			return fmt.Sprintf("LQI %d", ems.Data[0])
+		}
+	case JoinPermitJoin:
+		if len(ems.Data) == 1 {
+			return fmt.Sprintf("%d seconds", ems.Data[0])
+		}
+	}
+	if len(ems.Data) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("% x", ems.Data)
+}
+
+func (ems *ExtendedModemStatus) String() string {
+	if d := ems.Details(); d != "" {
+		return ems.Status.Description() + ": " + d
+	}
+	return ems.Status.Description()
+}
+
+func (ems *ExtendedModemStatus) RawFrameData() *RawFrameData {
+	return NewRawFrameData(concat([]byte{FrameTypeXBExtendedModemStatus, byte(ems.Status)}, ems.Data)...)
+}
+
+func (ems *ExtendedModemStatus) IsValid() bool {
+	return true
+}
+
+func (ems *ExtendedModemStatus) FrameType() byte {
+	return FrameTypeXBExtendedModemStatus
+}
@@ -3,19 +3,42 @@ package xbeeapi
 import (
 	"bytes"
 	"encoding/binary"
-	"fmt"
 )
 
 const frameStartDelimiter = 0x7e
 const minFrameSize = 4
 
+const (
+	frameEscape    = 0x7d
+	frameXON       = 0x11
+	frameXOFF      = 0x13
+	frameEscapeXOR = 0x20
+)
+
+// APIMode selects how frames are encoded on the wire, matching the
+// radio's AP setting.
+type APIMode byte
+
+const (
+	// APIModeTransparent is AP=0: the radio is not in API mode.
+	APIModeTransparent APIMode = 0
+	// APIModeUnescaped is AP=1: frames are written as is.
+	APIModeUnescaped APIMode = 1
+	// APIModeEscaped is AP=2: bytes after the start delimiter that collide
+	// with 0x7e, 0x7d, 0x11 or 0x13 are escaped with 0x7d and XORed with 0x20.
+	APIModeEscaped APIMode = 2
+	// APIModeAuto starts unescaped and switches to whatever the radio
+	// reports in response to the AP probe sent on start.
+	APIModeAuto APIMode = 0xff
+)
+
 // Frame is the structured data packet used in XBee API mode.
 //
-//    1 Byte          2 Bytes        Length bytes (Type is 1 Byte)    1 Byte
-// +----------+--------------------+--------------------------------+---------+
-// |  0x7e    |      Length        |        Frame Data              |Checksum |
-// |          |                    |    Type  |       Data          |         |
-// +----------+--------------------+--------------------------------+---------+
+//	   1 Byte          2 Bytes        Length bytes (Type is 1 Byte)    1 Byte
+//	+----------+--------------------+--------------------------------+---------+
+//	|  0x7e    |      Length        |        Frame Data              |Checksum |
+//	|          |                    |    Type  |       Data          |         |
+//	+----------+--------------------+--------------------------------+---------+
 type Frame struct {
 	Length    uint16
 	FrameData *RawFrameData
@@ -29,35 +52,38 @@ type FrameParseError struct {
 
 func (e *FrameParseError) Error() string { return e.msg }
 
+func (e *FrameParseError) Is(target error) bool { return target == ErrInvalidFrame }
+
 func checksumVerifyFrame(serializedFrame []byte) error {
 	var cs byte
 
 	if len(serializedFrame) < minFrameSize {
-		return &FrameParseError{msg: "Frame too short for checksum"}
+		return &TruncatedError{Field: "frame", Size: minFrameSize, Data: serializedFrame}
 	}
 	for _, b := range serializedFrame[3:] {
 		cs += b
 	}
 
 	if cs != 0xff {
-		return &FrameParseError{msg: "Checksum invalid"}
+		actual := serializedFrame[len(serializedFrame)-1]
+		return &ChecksumError{Expected: actual + 0xff - cs, Actual: actual, Data: serializedFrame}
 	}
 	return nil
 }
 
 func startDelimiterValid(serializedFrame []byte) error {
 	if len(serializedFrame) == 0 {
-		return &FrameParseError{msg: "Start delimiter field not found: Frame length not long enough"}
+		return &TruncatedError{Field: "start delimiter", Size: 1, Data: serializedFrame}
 	}
-	if serializedFrame[0] != 0x7e {
-		return &FrameParseError{msg: fmt.Sprintf("Invalid start delimiter: %x", 0x7e)}
+	if serializedFrame[0] != frameStartDelimiter {
+		return &DelimiterError{Delimiter: serializedFrame[0], Data: serializedFrame}
 	}
 	return nil
 }
 
 func lengthField(serializedFrame []byte) (uint16, error) {
 	if len(serializedFrame) < 3 {
-		return 0, &FrameParseError{msg: "Length field not found: Frame length not long enough"}
+		return 0, &TruncatedError{Field: "length", Offset: 1, Size: 2, Data: serializedFrame}
 	}
 	return uint16(int(serializedFrame[1])<<8) + uint16(serializedFrame[2]), nil
 }
@@ -96,27 +122,89 @@ func Deserialize(serializedFrame []byte) (*Frame, error) {
 		return nil, err
 	}
 	if int(expectedLength) != len(data) {
-		return nil, &FrameParseError{
-			msg: fmt.Sprintf("Expected length %u, received %u", expectedLength, len(data)),
-		}
+		return nil, &LengthError{Expected: int(expectedLength), Actual: len(data), Data: serializedFrame}
 	}
 
 	checksumIndex := len(serializedFrame) - 1
 	frameData := NewRawFrameData(data...)
 
 	if frameData.Checksum() != serializedFrame[checksumIndex] {
-		return nil, &FrameParseError{msg: "Invalid checksum"}
+		return nil, &ChecksumError{Expected: frameData.Checksum(), Actual: serializedFrame[checksumIndex], Data: serializedFrame}
 	}
 
 	f := &Frame{
 		Length:    uint16(expectedLength),
-		FrameData: NewRawFrameData(data...),
+		FrameData: frameData,
 		Checksum:  serializedFrame[checksumIndex],
 	}
 
 	return f, nil
 }
 
+// DeserializeAPIMode is like Deserialize, but first removes the escaping
+// used by mode.
+func DeserializeAPIMode(serializedFrame []byte, mode APIMode) (*Frame, error) {
+	if mode != APIModeEscaped {
+		return Deserialize(serializedFrame)
+	}
+	if err := startDelimiterValid(serializedFrame); err != nil {
+		return nil, err
+	}
+	unescaped, err := unescapeFrameBytes(serializedFrame[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return Deserialize(concat([]byte{frameStartDelimiter}, unescaped))
+}
+
+// SerializeAPIMode is like Serialize, but escapes the result for mode.
+// Length and checksum are always computed over the unescaped data.
+func (f *Frame) SerializeAPIMode(mode APIMode) ([]byte, error) {
+	b, err := f.Serialize()
+	if err != nil || mode != APIModeEscaped {
+		return b, err
+	}
+
+	return concat([]byte{frameStartDelimiter}, escapeFrameBytes(b[1:])), nil
+}
+
+func needsEscape(b byte) bool {
+	switch b {
+	case frameStartDelimiter, frameEscape, frameXON, frameXOFF:
+		return true
+	}
+	return false
+}
+
+func escapeFrameBytes(b []byte) []byte {
+	escaped := make([]byte, 0, len(b)+len(b)/8)
+	for _, c := range b {
+		if needsEscape(c) {
+			escaped = append(escaped, frameEscape, c^frameEscapeXOR)
+		} else {
+			escaped = append(escaped, c)
+		}
+	}
+	return escaped
+}
+
+func unescapeFrameBytes(b []byte) ([]byte, error) {
+	unescaped := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		if b[i] != frameEscape {
+			unescaped = append(unescaped, b[i])
+			continue
+		}
+		i++
+		if i == len(b) {
+			return nil, &FrameParseError{msg: "Escape byte at end of frame"}
+		}
+		unescaped = append(unescaped, b[i]^frameEscapeXOR)
+	}
+	return unescaped, nil
+}
+
 func (f *Frame) Serialize() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	err := buf.WriteByte(frameStartDelimiter)
@@ -49,3 +49,20 @@ func TestNewFrameFromData(t *testing.T) {
 		t.Error("Expected checksum", expectedChecksum, "but got", frame.Checksum)
 	}
 }
+
+func TestEscapedFrame(t *testing.T) {
+	escapedBytes := []byte{0x7e, 0x00, 0x02, 0x23, 0x7d, 0x31, 0xcb}
+	frame, err := DeserializeAPIMode(escapedBytes, APIModeEscaped)
+	if err != nil {
+		t.Error("Expected valid escaped frame:", err)
+		return
+	}
+	if !bytes.Equal(frame.FrameData.buf, []byte{0x23, 0x11}) {
+		t.Error("Unexpected unescaped frame data", frame.FrameData.buf)
+	}
+
+	serialized, err := frame.SerializeAPIMode(APIModeEscaped)
+	if err != nil || !bytes.Equal(escapedBytes, serialized) {
+		t.Error("Expected:", escapedBytes, "Got:", serialized, err)
+	}
+}
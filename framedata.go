@@ -1,7 +1,5 @@
 package xbeeapi
 
-import "fmt"
-
 const (
 	FrameTypeTxRequest64                       = 0x00
 	FrameTypeTxRequest16                       = 0x01
@@ -99,7 +97,7 @@ func NewFrameData(fd FrameData) *RawFrameData {
 
 func ParseFrameData(rfd *RawFrameData) (FrameData, error) {
 	if rfd.Len() == 0 {
-		return nil, &FrameParseError{msg: "Frame data not large enough"}
+		return nil, &TruncatedError{Field: "frame type", Size: 1, Data: rfd.buf}
 	}
 	switch rfd.FrameType() {
 	case FrameTypeATCommand:
@@ -108,12 +106,32 @@ func ParseFrameData(rfd *RawFrameData) (FrameData, error) {
 		return ParseATCommandResponse(rfd)
 	case FrameTypeModemStatus:
 		return ParseModemStatus(rfd)
+	case FrameTypeXBExtendedModemStatus:
+		return ParseExtendedModemStatus(rfd)
 	case FrameTypeATCommandQueueRegisterValue:
 		return ParseATCommandQueue(rfd)
 	case FrameTypeExplicitAddressingCommandFrame:
 		return ParseTxExplicitAddressing(rfd)
 	case FrameTypeExplicitRxIndicator:
 		return ParseRxExplicitIndicator(rfd)
+	case FrameTypeTxRequest:
+		return ParseTxRequest(rfd)
+	case FrameTypeXBRxResponse:
+		return ParseRxPacket(rfd)
+	case FrameTypeTxStatus, FrameTypeXBTxStatus:
+		return ParseTransmitStatus(rfd)
+	case FrameTypeRemoteATCommand:
+		return ParseRemoteATCommand(rfd)
+	case FrameTypeRemoteATCommandResponse:
+		return ParseRemoteATCommandResponse(rfd)
+	case FrameTypeXBIODataSampleRxIndicator:
+		return ParseIODataSampleRxIndicator(rfd)
+	case FrameTypeRxPacketIO64:
+		return ParseRxIOPacket64(rfd)
+	case FrameTypeRxPacketIO16:
+		return ParseRxIOPacket16(rfd)
+	case FrameTypeXBNodeIdentificationIndicator:
+		return ParseNodeIdentificationIndicator(rfd)
 	}
-	return nil, &FrameParseError{msg: fmt.Sprintf("Unsupported frame type: %02x", rfd.FrameType())}
+	return nil, &UnsupportedFrameTypeError{FrameType: rfd.FrameType(), Data: rfd.buf}
 }
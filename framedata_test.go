@@ -2,6 +2,7 @@ package xbeeapi
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 )
 
@@ -19,3 +20,150 @@ func TestATCommand(t *testing.T) {
 		t.Error("Expected:", expectedFrameBytes, "Got:", frameBytes)
 	}
 }
+
+func TestTxRequestWireLayout(t *testing.T) {
+	tx := &TxRequest{FrameID: 1, Address64: 0x0013a20040a1b2c3, Address16: Address16Unknown, Payload: []byte("hi")}
+	expected := []byte{FrameTypeTxRequest, 0x01, 0x00, 0x13, 0xa2, 0x00, 0x40, 0xa1, 0xb2, 0xc3, 0xff, 0xfe, 0x00, 0x00, 'h', 'i'}
+	rfd := tx.RawFrameData()
+	if !bytes.Equal(rfd.buf, expected) {
+		t.Error("Expected:", expected, "Got:", rfd.buf)
+	}
+
+	parsed, err := ParseTxRequest(rfd)
+	if err != nil || parsed.Address64 != tx.Address64 || parsed.Address16 != tx.Address16 || !bytes.Equal(parsed.Payload, tx.Payload) {
+		t.Error("TxRequest round trip mismatch", parsed, err)
+	}
+}
+
+func TestParseRxPacket(t *testing.T) {
+	frame, _ := Deserialize([]byte{0x7e, 0x00, 0x12, 0x90, 0x00, 0x13, 0xa2, 0x00, 0x40, 0x52, 0x2b, 0xaa, 0x7d, 0x84, 0x01, 0x52, 0x78, 0x44, 0x61, 0x74, 0x61, 0x0d})
+	fd, err := ParseFrameData(frame.FrameData)
+	if err != nil {
+		t.Error("Could not parse RxPacket", err)
+		return
+	}
+	rx, ok := fd.(*RxPacket)
+	if !ok || rx.Address64 != 0x0013a20040522baa || rx.Address16 != 0x7d84 || !rx.IsOptionsFlagSet(RxOptionPacketAcked) || string(rx.Payload) != "RxData" {
+		t.Error("Unexpected RxPacket", fd)
+	}
+	if !bytes.Equal(rx.RawFrameData().buf, frame.FrameData.buf) {
+		t.Error("RxPacket serialization mismatch", rx.RawFrameData().buf)
+	}
+}
+
+func TestParseTransmitStatus(t *testing.T) {
+	frame, _ := Deserialize([]byte{0x7e, 0x00, 0x07, 0x8b, 0x01, 0x7d, 0x84, 0x00, 0x00, 0x01, 0x71})
+	fd, err := ParseFrameData(frame.FrameData)
+	if err != nil {
+		t.Error("Could not parse TransmitStatus", err)
+		return
+	}
+	ts, ok := fd.(*TransmitStatus)
+	if !ok || ts.FrameID != 1 || ts.Address16 != 0x7d84 || !ts.Delivered() || ts.DiscoveryStatus != DiscoveryAddress {
+		t.Error("Unexpected TransmitStatus", fd)
+	}
+	if DeliveryRouteNotFound.Description() != "Route Not Found" {
+		t.Error("Unexpected description", DeliveryRouteNotFound.Description())
+	}
+
+	legacy := NewRawFrameData(FrameTypeTxStatus, 0x02, byte(DeliveryMACAckFailure))
+	fd, err = ParseFrameData(legacy)
+	ts, ok = fd.(*TransmitStatus)
+	if err != nil || !ok || ts.FrameID != 2 || ts.Address16 != Address16Unknown || ts.DeliveryStatus != DeliveryMACAckFailure {
+		t.Fatal("Unexpected legacy TransmitStatus", fd, err)
+	}
+	if !bytes.Equal(ts.RawFrameData().buf, legacy.buf) {
+		t.Error("Legacy TransmitStatus serialization mismatch", ts.RawFrameData().buf)
+	}
+	if _, err := ParseTransmitStatus(NewRawFrameData(FrameTypeTxStatus, 0x02)); !errors.Is(err, ErrTruncated) {
+		t.Error("Expected truncated error, got", err)
+	}
+}
+
+func TestModemStatusRoundTrip(t *testing.T) {
+	ms := &ModemStatus{Status: ModemJoined}
+	frameBytes, err := NewFrame(ms).Serialize()
+	expected := []byte{0x7e, 0x00, 0x02, 0x8a, 0x02, 0x73}
+	if err != nil || !bytes.Equal(frameBytes, expected) {
+		t.Error("Expected:", expected, "Got:", frameBytes, err)
+	}
+
+	frame, err := Deserialize(frameBytes)
+	if err != nil {
+		t.Fatal("Could not deserialize ModemStatus", err)
+	}
+	fd, err := ParseFrameData(frame.FrameData)
+	if parsed, ok := fd.(*ModemStatus); err != nil || !ok || parsed.Status != ModemJoined {
+		t.Error("ModemStatus round trip mismatch", fd, err)
+	}
+}
+
+func TestRemoteATCommand(t *testing.T) {
+	cmd := &RemoteATCommand{FrameID: 1, Address64: 0x0013a20040401122, Address16: Address16Unknown, Command: "BH", Params: []byte{0x01}}
+	cmd.SetOptionsFlags(RemoteATOptionApplyChanges)
+	frameBytes, _ := NewFrame(cmd).Serialize()
+
+	expected := []byte{0x7e, 0x00, 0x10, 0x17, 0x01, 0x00, 0x13, 0xa2, 0x00, 0x40, 0x40, 0x11, 0x22, 0xff, 0xfe, 0x02, 0x42, 0x48, 0x01, 0xf5}
+	if !bytes.Equal(frameBytes, expected) {
+		t.Error("Expected:", expected, "Got:", frameBytes)
+	}
+
+	frame, _ := Deserialize(frameBytes)
+	fd, err := ParseFrameData(frame.FrameData)
+	if parsed, ok := fd.(*RemoteATCommand); !ok || parsed.Command != "BH" || !parsed.IsOptionsFlagSet(RemoteATOptionApplyChanges) {
+		t.Error("Unexpected RemoteATCommand", fd, err)
+	}
+}
+
+func TestParseNodeIdentificationIndicator(t *testing.T) {
+	frame, err := Deserialize([]byte{0x7e, 0x00, 0x20, 0x95, 0x00, 0x13, 0xa2, 0x00, 0x40, 0x52, 0x2b, 0xaa, 0x7d, 0x84, 0x02, 0x7d, 0x84, 0x00, 0x13, 0xa2, 0x00, 0x40, 0x52, 0x2b, 0xaa, 0x20, 0x00, 0xff, 0xfe, 0x01, 0x01, 0xc1, 0x05, 0x10, 0x1e, 0x1b})
+	if err != nil {
+		t.Error("Expected valid frame:", err)
+		return
+	}
+	fd, err := ParseFrameData(frame.FrameData)
+	ni, ok := fd.(*NodeIdentificationIndicator)
+	if err != nil || !ok {
+		t.Error("Could not parse NodeIdentificationIndicator", fd, err)
+		return
+	}
+	node := ni.Node
+	if node.Address64 != 0x0013a20040522baa || node.Address16 != 0x7d84 || node.NodeIdentifier != " " ||
+		node.ParentAddress16 != Address16Unknown || node.DeviceType != DeviceTypeRouter ||
+		node.SourceEvent != SourceEventPushbutton || node.ProfileID != 0xc105 || node.ManufacturerID != 0x101e {
+		t.Error("Unexpected node info", node)
+	}
+	if !bytes.Equal(ni.RawFrameData().buf, frame.FrameData.buf) {
+		t.Error("NodeIdentificationIndicator serialization mismatch", ni.RawFrameData().buf)
+	}
+}
+
+func TestParseExtendedModemStatus(t *testing.T) {
+	rfd := NewRawFrameData(FrameTypeXBExtendedModemStatus, byte(JoinBeaconResponse), 0x7d, 0x84, 0x00, 0x13, 0xa2, 0x00, 0x00, 0x00, 0x12, 0x34, 0x0f, 0x01, 0x02, 0xc8)
+	fd, err := ParseFrameData(rfd)
+	ems, ok := fd.(*ExtendedModemStatus)
+	if err != nil || !ok {
+		t.Fatal("Could not parse ExtendedModemStatus", fd, err)
+	}
+	b, err := ems.Beacon()
+	if err != nil || b.Address16 != 0x7d84 || b.ExtendedPANID != 0x0013a20000001234 || b.Channel != 0x0f || !b.AllowJoin || b.StackProfile != 2 || b.LQI != 0xc8 {
+		t.Error("Unexpected beacon", b, err)
+	}
+	if ch, ok := ems.Channel(); !ok || ch != 0x0f {
+		t.Error("Unexpected channel", ch, ok)
+	}
+	if !bytes.Equal(ems.RawFrameData().buf, rfd.buf) {
+		t.Error("ExtendedModemStatus serialization mismatch", ems.RawFrameData().buf)
+	}
+
+	ai := &ExtendedModemStatus{Status: JoinAIChange, Data: []byte{byte(AssociationJoinNotAllowed)}}
+	if s, ok := ai.Association(); !ok || s != AssociationJoinNotAllowed {
+		t.Error("Unexpected association", s, ok)
+	}
+	if ai.String() != "Association Indication Changed: Network Not Allowing Joining" {
+		t.Error("Unexpected description", ai.String())
+	}
+	if _, err := (&ExtendedModemStatus{Status: JoinBeaconResponse, Data: []byte{0x7d}}).Beacon(); !errors.Is(err, ErrTruncated) {
+		t.Error("Expected truncated beacon, got", err)
+	}
+}
@@ -0,0 +1,192 @@
+package xbeeapi
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultFrameIDExpiry is how long a frame ID assigned with AutoFrameID is
+// kept reserved when no response for it arrives.
+const DefaultFrameIDExpiry = 10 * time.Second
+
+type frameIDEntry struct {
+	used    bool
+	expires time.Time
+}
+
+// frameIDAllocator hands out frame IDs 1-255 and tracks which of them are
+// outstanding. Frame ID 0 is never handed out since it tells the radio not
+// to send a response.
+type frameIDAllocator struct {
+	mu       *sync.Mutex
+	ids      [256]frameIDEntry
+	inUse    int
+	next     byte
+	released chan struct{}
+}
+
+func newFrameIDAllocator() *frameIDAllocator {
+	return &frameIDAllocator{
+		mu:       &sync.Mutex{},
+		next:     1,
+		released: make(chan struct{}),
+	}
+}
+
+// tryAcquire reserves a free frame ID, or returns ErrNoFrameID if all of
+// them are outstanding. A zero expiry reserves the ID until it is released.
+// A non-nil accept limits the frame IDs handed out to those it accepts.
+func (a *frameIDAllocator) tryAcquire(expiry time.Duration, accept func(id byte) bool) (byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	id, ok := a.acquireLocked(time.Now(), expiry, accept)
+	if !ok {
+		return 0, ErrNoFrameID
+	}
+	return id, nil
+}
+
+// acquire is like tryAcquire, but blocks until a frame ID is released or
+// expires, or ctx is done.
+func (a *frameIDAllocator) acquire(ctx context.Context, expiry time.Duration, accept func(id byte) bool) (byte, error) {
+	for {
+		a.mu.Lock()
+		now := time.Now()
+		id, ok := a.acquireLocked(now, expiry, accept)
+		released := a.released
+		wait := a.nextExpiryLocked(now)
+		a.mu.Unlock()
+
+		if ok {
+			return id, nil
+		}
+
+		var t *time.Timer
+		var timer <-chan time.Time
+		if wait > 0 {
+			t = time.NewTimer(wait)
+			timer = t.C
+		}
+		select {
+		case <-released:
+		case <-timer:
+		case <-ctx.Done():
+		}
+		if t != nil {
+			t.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (a *frameIDAllocator) acquireLocked(now time.Time, expiry time.Duration, accept func(id byte) bool) (byte, bool) {
+	for i := 0; i < 255; i++ {
+		id := a.next
+		a.next++
+		if a.next == 0 {
+			a.next = 1
+		}
+
+		e := &a.ids[id]
+		if e.used && !e.expires.IsZero() && now.After(e.expires) {
+			e.used = false
+			a.inUse--
+		}
+		if e.used || (accept != nil && !accept(id)) {
+			continue
+		}
+
+		e.used = true
+		e.expires = time.Time{}
+		if expiry > 0 {
+			e.expires = now.Add(expiry)
+		}
+		a.inUse++
+		return id, true
+	}
+
+	return 0, false
+}
+
+// nextExpiryLocked returns the time until the earliest outstanding frame
+// ID expires, or zero if none of them expire.
+func (a *frameIDAllocator) nextExpiryLocked(now time.Time) time.Duration {
+	var earliest time.Time
+	for id := 1; id < len(a.ids); id++ {
+		e := a.ids[id]
+		if e.used && !e.expires.IsZero() && (earliest.IsZero() || e.expires.Before(earliest)) {
+			earliest = e.expires
+		}
+	}
+	if earliest.IsZero() {
+		return 0
+	}
+	if d := earliest.Sub(now); d > 0 {
+		return d
+	}
+	return time.Millisecond
+}
+
+func (a *frameIDAllocator) release(id byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if id == 0 || !a.ids[id].used {
+		return
+	}
+	a.ids[id] = frameIDEntry{}
+	a.inUse--
+	a.notifyLocked()
+}
+
+// releaseExpiring releases id only if it was acquired with an expiry,
+// leaving IDs owned by a waiting request alone.
+func (a *frameIDAllocator) releaseExpiring(id byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if e := a.ids[id]; !e.used || e.expires.IsZero() {
+		return
+	}
+	a.ids[id] = frameIDEntry{}
+	a.inUse--
+	a.notifyLocked()
+}
+
+// reset releases every outstanding frame ID.
+func (a *frameIDAllocator) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.ids = [256]frameIDEntry{}
+	a.inUse = 0
+	a.next = 1
+	a.notifyLocked()
+}
+
+func (a *frameIDAllocator) notifyLocked() {
+	close(a.released)
+	a.released = make(chan struct{})
+}
+
+func (a *frameIDAllocator) outstanding() (n int) {
+	a.mu.Lock()
+	n = a.inUse
+	a.mu.Unlock()
+	return
+}
+
+type autoFrameID struct {
+	FrameIDSetter
+}
+
+// AutoFrameID marks frameData to be given a free frame ID by
+// XBeeAPI.SendFrames, so callers never pick frame IDs themselves. The ID
+// is reclaimed when the response arrives or after the frame ID expiry.
+func AutoFrameID(frameData FrameIDSetter) FrameData {
+	return &autoFrameID{frameData}
+}
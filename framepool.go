@@ -0,0 +1,53 @@
+package xbeeapi
+
+type pooledFrame struct {
+	frame Frame
+	data  RawFrameData
+}
+
+// framePool hands out the frames of one read of the reader, reusing the
+// frames and buffers of the read before: a frame from get belongs to the
+// pool and is only valid until the next call to recycle. Frames that
+// leave the reader are copied with clone, so whoever keeps one owns its
+// buffer and holds on to nothing else.
+type framePool struct {
+	free []*pooledFrame
+	used []*pooledFrame
+}
+
+// recycle makes the frames handed out since the last call available
+// again.
+func (p *framePool) recycle() {
+	p.free = append(p.free, p.used...)
+	clear(p.used)
+	p.used = p.used[:0]
+}
+
+// get returns a frame whose frame data is n bytes long.
+func (p *framePool) get(n int) *Frame {
+	var pf *pooledFrame
+	if last := len(p.free) - 1; last >= 0 {
+		pf = p.free[last]
+		p.free[last] = nil
+		p.free = p.free[:last]
+	} else {
+		pf = &pooledFrame{}
+		pf.frame.FrameData = &pf.data
+	}
+	p.used = append(p.used, pf)
+
+	if cap(pf.data.buf) < n {
+		pf.data.buf = make([]byte, n)
+	}
+	pf.data.buf = pf.data.buf[:n]
+	pf.frame.Length = uint16(n)
+	return &pf.frame
+}
+
+// clone copies f into an allocation of its own.
+func (f *Frame) clone() *Frame {
+	pf := &pooledFrame{data: RawFrameData{buf: make([]byte, len(f.FrameData.buf))}}
+	copy(pf.data.buf, f.FrameData.buf)
+	pf.frame = Frame{Length: f.Length, FrameData: &pf.data, Checksum: f.Checksum}
+	return &pf.frame
+}
@@ -1,107 +1,93 @@
 package xbeeapi
 
 import (
-	"bytes"
 	"io"
 	"sync"
 )
 
+// frameReadWriter frames the traffic with the radio through a Decoder and
+// an Encoder, and follows the API mode the radio reports when configured
+// with APIModeAuto.
 type frameReadWriter struct {
-	rw  io.ReadWriter
 	mu  *sync.Mutex
-	buf *bytes.Buffer
+	dec *Decoder
+	enc *Encoder
+	// mode is the configured API mode, active the one currently used for
+	// framing. They only differ while mode is APIModeAuto.
+	mode   APIMode
+	active APIMode
 }
 
-func newFrameReader(rw io.ReadWriter) *frameReadWriter {
-	return &frameReadWriter{
-		rw:  rw,
-		mu:  &sync.Mutex{},
-		buf: bytes.NewBuffer([]byte{}),
+func newFrameReader(rw io.ReadWriter, mode APIMode) *frameReadWriter {
+	fr := &frameReadWriter{
+		mu:   &sync.Mutex{},
+		dec:  NewDecoder(rw),
+		enc:  NewEncoder(rw),
+		mode: mode,
 	}
-}
-
-func (fr *frameReadWriter) read() ([]*Frame, error) {
-	var b [16]byte
-	n, err := fr.rw.Read(b[:])
-
-	if err != nil {
-		return nil, err
-	}
-	if n == 0 {
-		return nil, io.EOF
-	}
-
-	n, err = fr.buf.Write(b[:n])
-
-	if err != nil {
-		return nil, err
-	}
-	if n == 0 {
-		return nil, io.EOF
-	}
-
-	frames := make([]*Frame, 0)
-
-	for {
-		buf := fr.buf.Bytes()
-		if len(buf) < minFrameSize {
-			break
-		}
-
-		if buf[0] != frameStartDelimiter {
-			fr.buf.ReadByte()
-			continue
-		}
+	fr.dec.SetInterByteTimeout(DefaultInterByteTimeout)
+	fr.setAPIMode(fr.initialMode())
 
-		dataLen, err := lengthField(buf)
-		if err != nil {
-			break
-		}
-
-		frameLen := totalFrameLength(dataLen)
-		if len(buf) < frameLen {
-			break
-		}
-
-		buf = fr.buf.Next(frameLen)
-		frame, err := Deserialize(buf)
+	return fr
+}
 
-		if err == nil {
-			frames = append(frames, frame)
-		}
+// initialMode is the framing used until the radio reports its API mode.
+func (fr *frameReadWriter) initialMode() APIMode {
+	if fr.mode == APIModeAuto {
+		return APIModeUnescaped
 	}
+	return fr.mode
+}
 
-	if fr.buf.Cap() > 128 {
-		// Shrink buffer if capacity grows too large
-		newBuffer := bytes.NewBuffer([]byte{})
-		newBuffer.Write(fr.buf.Bytes())
-		fr.buf = newBuffer
-	}
+// reset switches to a new port, dropping any partial frame read from the
+// old one, and goes back to the initial framing. It is called on the
+// reader goroutine.
+func (fr *frameReadWriter) reset(rw io.ReadWriter) {
+	fr.dec.Reset(rw)
+	fr.enc.Reset(rw)
+	fr.setAPIMode(fr.initialMode())
+}
 
-	return frames, nil
+func (fr *frameReadWriter) apiMode() (m APIMode) {
+	fr.mu.Lock()
+	m = fr.active
+	fr.mu.Unlock()
+	return
 }
 
-func (fr *frameReadWriter) write(frames ...*Frame) (int, error) {
-	var err error
-	totalWritten := 0
+// setAPIMode switches the framing. It is called on the reader goroutine,
+// which owns the Decoder.
+func (fr *frameReadWriter) setAPIMode(m APIMode) {
+	fr.mu.Lock()
+	fr.active = m
+	fr.mu.Unlock()
+	fr.dec.SetAPIMode(m)
+	fr.enc.SetAPIMode(m)
+}
 
-	//fr.mu.Lock()
-	for _, f := range frames {
-		frameBytes, err := f.Serialize()
-		if err != nil {
-			break
-		}
-		_, err = fr.rw.Write(frameBytes)
-		if err != nil {
-			break
-		}
-		totalWritten++
+// read reads from the port once and returns the frames completed by the
+// bytes read, along with any read error. The returned slice and frames
+// are reused by the next call.
+func (fr *frameReadWriter) read() ([]*Frame, error) {
+	frames, err := fr.dec.readFrames()
+	for _, frame := range frames {
+		fr.detectAPIMode(frame)
 	}
-	//fr.mu.Unlock()
-
-	return totalWritten, err
+	return frames, err
 }
 
-func (fr *frameReadWriter) init() (int, error) {
-	return fr.rw.Write([]byte{0x7e, 0x00, 0x04, 0x08, 0x01, 0x41, 0x50, 0x65})
+// detectAPIMode switches the active mode when running in APIModeAuto and
+// frame is the radio's answer to an AP query.
+func (fr *frameReadWriter) detectAPIMode(frame *Frame) {
+	if fr.mode != APIModeAuto || frame.FrameData.FrameType() != FrameTypeATCommandResponse {
+		return
+	}
+	atr, err := ParseATCommandResponse(frame.FrameData)
+	if err != nil || atr.Command != "AP" || atr.Status != ATCommandOK || len(atr.Params) != 1 {
+		return
+	}
+	switch m := APIMode(atr.Params[0]); m {
+	case APIModeUnescaped, APIModeEscaped:
+		fr.setAPIMode(m)
+	}
 }
@@ -0,0 +1,105 @@
+package xbeeapi
+
+import (
+	"bytes"
+	"testing"
+)
+
+// fuzzSeedFrames returns a valid frame of each type ParseFrameData
+// supports.
+func fuzzSeedFrames() []FrameData {
+	sample := IOSample{
+		DigitalMask: 0x0003,
+		AnalogMask:  0x01,
+		Digital:     map[IOPin]bool{PinD0: true, PinD1: false},
+		Analog:      map[IOPin]uint16{PinAD0: 0x0200},
+	}
+	node := NodeInfo{Address64: 0x0013a20040522baa, Address16: 0x7d84, ParentAddress16: Address16Unknown, NodeIdentifier: "node", DeviceType: DeviceTypeRouter}
+
+	return []FrameData{
+		&ATCommand{FrameID: 1, Command: "NI", Params: []byte("x")},
+		&ATCommandQueue{FrameID: 2, Command: "NI"},
+		&ATCommandResponse{FrameID: 1, Command: "ND", Status: ATCommandOK, Params: node.NodeDiscoveryBytes()},
+		&ModemStatus{Status: ModemJoined},
+		&ExtendedModemStatus{Status: JoinBeaconResponse, Data: []byte{0x7d, 0x84, 0, 0, 0, 0, 0, 0, 0x12, 0x34, 0x0f, 0x01, 0x02, 0xff}},
+		&TxRequest{FrameID: 3, Address64: Address64Broadcast, Address16: Address16Unknown, Payload: []byte("hi")},
+		&TxExplicitAddressing{FrameID: 4, Address64: 0x0013a20040522baa, Address16: Address16Unknown, SrcEndPoint: 0xe8, DstEndPoint: 0xe8, ClusterID: 0x0011, ProfileID: 0xc105, Payload: []byte{0x7e, 0x7d}},
+		&RxPacket{Address64: 0x0013a20040522baa, Address16: 0x7d84, Payload: []byte("hello")},
+		&RxExplicitIndicator{Address64: 0x0013a20040522baa, Address16: 0x7d84, ClusterID: 0x0011, Payload: []byte{0x11, 0x13}},
+		&TransmitStatus{FrameID: 3, Address16: 0x7d84, DeliveryStatus: DeliverySuccess},
+		&RemoteATCommand{FrameID: 5, Address64: 0x0013a20040522baa, Address16: Address16Unknown, Command: "D0", Params: []byte{0x05}},
+		&RemoteATCommandResponse{FrameID: 5, Address64: 0x0013a20040522baa, Address16: 0x7d84, Command: "D0"},
+		&IODataSampleRxIndicator{Address64: 0x0013a20040522baa, Address16: 0x7d84, Sample: sample},
+		&RxIOPacket64{Address64: 0x0013a20040522baa, Samples: []IOSample{sample}},
+		&RxIOPacket16{Address16: 0x7d84, Samples: []IOSample{sample, sample}},
+		&NodeIdentificationIndicator{SenderAddress64: node.Address64, SenderAddress16: node.Address16, Node: node},
+	}
+}
+
+func FuzzDeserialize(f *testing.F) {
+	for _, fd := range fuzzSeedFrames() {
+		b, _ := NewFrame(fd).Serialize()
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		frame, err := Deserialize(data)
+		if err != nil {
+			return
+		}
+		b, err := frame.Serialize()
+		if err != nil || !bytes.Equal(b, data) {
+			t.Errorf("Frame % x serialized as % x, %v", data, b, err)
+		}
+	})
+}
+
+func FuzzParseFrameData(f *testing.F) {
+	for _, fd := range fuzzSeedFrames() {
+		f.Add(fd.RawFrameData().buf)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fd, err := ParseFrameData(NewRawFrameData(data...))
+		if err == nil {
+			fd.RawFrameData()
+		}
+	})
+}
+
+func FuzzDecoder(f *testing.F) {
+	for _, mode := range []APIMode{APIModeUnescaped, APIModeEscaped} {
+		var stream []byte
+		for _, fd := range fuzzSeedFrames() {
+			b, _ := NewFrame(fd).SerializeAPIMode(mode)
+			stream = append(stream, b...)
+		}
+		f.Add(stream, byte(mode), byte(7))
+	}
+	f.Fuzz(func(t *testing.T, stream []byte, mode byte, chunk byte) {
+		var chunks [][]byte
+		size := int(chunk) + 1
+		for len(stream) > 0 {
+			n := min(size, len(stream))
+			chunks = append(chunks, stream[:n])
+			stream = stream[n:]
+		}
+		dec := NewDecoder(&chunkPort{chunks: chunks})
+		dec.SetAPIMode(APIMode(mode))
+
+		for frame, err := range dec.Frames() {
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Deserialize(must(frame.Serialize())); err != nil {
+				t.Error("Decoder returned invalid frame", err)
+			}
+			ParseFrameData(frame.FrameData)
+		}
+	})
+}
+
+func must(b []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
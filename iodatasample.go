@@ -0,0 +1,53 @@
+package xbeeapi
+
+// IODataSampleRxIndicator is a Zigbee IO Data Sample Rx Indicator (0x92)
+// reporting the IO lines of a remote radio.
+type IODataSampleRxIndicator struct {
+	Address64 Address64
+	Address16 Address16
+	Options   byte
+	Sample    IOSample
+}
+
+func ParseIODataSampleRxIndicator(rfd *RawFrameData) (*IODataSampleRxIndicator, error) {
+	c, err := newFrameCursor(rfd, FrameTypeXBIODataSampleRxIndicator, "IODataSampleRxIndicator")
+	if err != nil {
+		return nil, err
+	}
+
+	rx := &IODataSampleRxIndicator{
+		Address64: c.address64("Address64"),
+		Address16: c.address16("Address16"),
+		Options:   c.uint8("Options"),
+	}
+	// The number of samples is always 1.
+	c.uint8("sample count")
+
+	sample, err := parseIOSample(c)
+	if err != nil {
+		return nil, err
+	}
+	rx.Sample = sample
+
+	return rx, nil
+}
+
+func (rx *IODataSampleRxIndicator) RawFrameData() *RawFrameData {
+	b := []byte{FrameTypeXBIODataSampleRxIndicator}
+	b = concat(b, rx.Address64.Bytes(), rx.Address16.Bytes())
+	b = append(b, rx.Options, 0x01)
+
+	return NewRawFrameData(concat(b, rx.Sample.bytes())...)
+}
+
+func (rx *IODataSampleRxIndicator) IsValid() bool {
+	return true
+}
+
+func (rx *IODataSampleRxIndicator) FrameType() byte {
+	return FrameTypeXBIODataSampleRxIndicator
+}
+
+func (rx *IODataSampleRxIndicator) IsOptionsFlagSet(rxOptionFlag RxOptionFlag) bool {
+	return isRxOptionsFlagSet(rx.Options, rxOptionFlag)
+}
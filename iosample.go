@@ -0,0 +1,194 @@
+package xbeeapi
+
+import "encoding/binary"
+
+// IOPin names a digital or analog IO line of the radio.
+type IOPin string
+
+const (
+	PinD0  IOPin = "D0"
+	PinD1  IOPin = "D1"
+	PinD2  IOPin = "D2"
+	PinD3  IOPin = "D3"
+	PinD4  IOPin = "D4"
+	PinD5  IOPin = "D5"
+	PinD6  IOPin = "D6"
+	PinD7  IOPin = "D7"
+	PinD8  IOPin = "D8"
+	PinD9  IOPin = "D9"
+	PinD10 IOPin = "D10"
+	PinD11 IOPin = "D11"
+	PinD12 IOPin = "D12"
+
+	PinAD0 IOPin = "AD0"
+	PinAD1 IOPin = "AD1"
+	PinAD2 IOPin = "AD2"
+	PinAD3 IOPin = "AD3"
+	PinAD4 IOPin = "AD4"
+	PinAD5 IOPin = "AD5"
+
+	// PinSupplyVoltage is the analog channel reporting the module's
+	// supply voltage.
+	PinSupplyVoltage IOPin = "VCC"
+)
+
+var digitalPins = [...]IOPin{PinD0, PinD1, PinD2, PinD3, PinD4, PinD5, PinD6, PinD7, PinD8, PinD9, PinD10, PinD11, PinD12}
+
+var analogPins = [...]IOPin{PinAD0, PinAD1, PinAD2, PinAD3, PinAD4, PinAD5}
+
+const (
+	// ZigbeeADCReferenceMillivolts is the internal ADC reference of Zigbee
+	// modules. 802.15.4 modules use the voltage on their VREF pin instead.
+	ZigbeeADCReferenceMillivolts = 1200
+
+	adcMaxCounts          = 1023
+	supplyVoltageMaskBit  = 0x80
+	legacyDigitalMaskBits = 0x01ff
+)
+
+// IOSample holds one set of IO readings. Channel masks use the layout of
+// the Zigbee IO Data Sample frame: bit n of DigitalMask is Dn, bit n of
+// AnalogMask is ADn and bit 7 is the supply voltage.
+type IOSample struct {
+	DigitalMask uint16
+	AnalogMask  byte
+	Digital     map[IOPin]bool
+	Analog      map[IOPin]uint16
+}
+
+// Millivolts returns the analog reading of pin converted to millivolts for
+// the given ADC reference voltage.
+func (s *IOSample) Millivolts(pin IOPin, referenceMillivolts int) (int, bool) {
+	counts, ok := s.Analog[pin]
+	if !ok {
+		return 0, false
+	}
+	return ADCToMillivolts(counts, referenceMillivolts), true
+}
+
+// ADCToMillivolts converts a 10-bit ADC reading to millivolts for the
+// given ADC reference voltage.
+func ADCToMillivolts(counts uint16, referenceMillivolts int) int {
+	return int(counts) * referenceMillivolts / adcMaxCounts
+}
+
+// parseIOSample decodes a Zigbee style sample: digital mask, analog mask,
+// then digital states if any digital channel is enabled and one reading
+// per enabled analog channel.
+func parseIOSample(c *cursor) (IOSample, error) {
+	s := IOSample{
+		DigitalMask: c.uint16("DigitalMask"),
+		AnalogMask:  c.uint8("AnalogMask"),
+	}
+	if c.err != nil {
+		return IOSample{}, c.err
+	}
+
+	analog := []IOPin(nil)
+	for i, pin := range analogPins[:4] {
+		if s.AnalogMask&(1<<uint(i)) != 0 {
+			analog = append(analog, pin)
+		}
+	}
+	if s.AnalogMask&supplyVoltageMaskBit != 0 {
+		analog = append(analog, PinSupplyVoltage)
+	}
+
+	return s, s.decodeReadings(c, analog)
+}
+
+// parseLegacyIOSamples decodes the samples of an 802.15.4 IO packet: a
+// sample count and a 2 byte channel indicator (bits 14-9 A5-A0, bits 8-0
+// D8-D0) followed by that many sets of readings.
+func parseLegacyIOSamples(c *cursor) ([]IOSample, error) {
+	count := int(c.uint8("sample count"))
+	indicator := c.uint16("channel indicator")
+	if c.err != nil {
+		return nil, c.err
+	}
+	digitalMask := indicator & legacyDigitalMaskBits
+	analogMask := byte(indicator>>9) & 0x3f
+
+	analog := []IOPin(nil)
+	for i, pin := range analogPins {
+		if analogMask&(1<<uint(i)) != 0 {
+			analog = append(analog, pin)
+		}
+	}
+
+	samples := make([]IOSample, 0, count)
+	for i := 0; i < count; i++ {
+		s := IOSample{DigitalMask: digitalMask, AnalogMask: analogMask}
+		if err := s.decodeReadings(c, analog); err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+
+	return samples, nil
+}
+
+func (s *IOSample) decodeReadings(c *cursor, analog []IOPin) error {
+	s.Digital = make(map[IOPin]bool)
+	s.Analog = make(map[IOPin]uint16)
+
+	if s.DigitalMask != 0 {
+		states := c.uint16("digital samples")
+		for i, pin := range digitalPins {
+			if s.DigitalMask&(1<<uint(i)) != 0 {
+				s.Digital[pin] = states&(1<<uint(i)) != 0
+			}
+		}
+	}
+
+	for _, pin := range analog {
+		s.Analog[pin] = c.uint16("analog sample " + string(pin))
+	}
+
+	return c.err
+}
+
+// bytes encodes the sample in Zigbee layout.
+func (s *IOSample) bytes() []byte {
+	b := make([]byte, 3, 5+2*len(s.Analog))
+	binary.BigEndian.PutUint16(b, s.DigitalMask)
+	b[2] = s.AnalogMask
+	b = s.appendReadings(b, s.AnalogMask&supplyVoltageMaskBit != 0, 4)
+	return b
+}
+
+func (s *IOSample) appendReadings(b []byte, supplyVoltage bool, analogChannels int) []byte {
+	if s.DigitalMask != 0 {
+		var states uint16
+		for i, pin := range digitalPins {
+			if s.Digital[pin] {
+				states |= 1 << uint(i)
+			}
+		}
+		b = append(b, byte(states>>8), byte(states))
+	}
+	for i, pin := range analogPins[:analogChannels] {
+		if s.AnalogMask&(1<<uint(i)) != 0 {
+			b = append(b, byte(s.Analog[pin]>>8), byte(s.Analog[pin]))
+		}
+	}
+	if supplyVoltage {
+		b = append(b, byte(s.Analog[PinSupplyVoltage]>>8), byte(s.Analog[PinSupplyVoltage]))
+	}
+	return b
+}
+
+// legacyIOSampleBytes encodes samples, which must share channel masks, in
+// 802.15.4 IO packet layout.
+func legacyIOSampleBytes(samples []IOSample) []byte {
+	b := []byte{byte(len(samples)), 0x00, 0x00}
+	if len(samples) == 0 {
+		return b
+	}
+	indicator := samples[0].DigitalMask&legacyDigitalMaskBits | uint16(samples[0].AnalogMask&0x3f)<<9
+	binary.BigEndian.PutUint16(b[1:], indicator)
+	for i := range samples {
+		b = samples[i].appendReadings(b, false, len(analogPins))
+	}
+	return b
+}
@@ -0,0 +1,65 @@
+package xbeeapi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseIODataSample(t *testing.T) {
+	frameBytes := []byte{0x7e, 0x00, 0x14, 0x92, 0x00, 0x13, 0xa2, 0x00, 0x40, 0x52, 0x2b, 0xaa, 0x7d, 0x84, 0x01, 0x01, 0x00, 0x1c, 0x02, 0x00, 0x14, 0x02, 0x25, 0xf5}
+	frame, err := Deserialize(frameBytes)
+	if err != nil {
+		t.Error("Expected valid frame:", err)
+		return
+	}
+	fd, err := ParseFrameData(frame.FrameData)
+	rx, ok := fd.(*IODataSampleRxIndicator)
+	if err != nil || !ok {
+		t.Error("Could not parse IO data sample", fd, err)
+		return
+	}
+
+	s := rx.Sample
+	if len(s.Digital) != 3 || !s.Digital[PinD2] || s.Digital[PinD3] || !s.Digital[PinD4] {
+		t.Error("Unexpected digital readings", s.Digital)
+	}
+	if len(s.Analog) != 1 || s.Analog[PinAD1] != 0x225 {
+		t.Error("Unexpected analog readings", s.Analog)
+	}
+	if mv, ok := s.Millivolts(PinAD1, ZigbeeADCReferenceMillivolts); !ok || mv != 643 {
+		t.Error("Unexpected millivolts", mv)
+	}
+	if !bytes.Equal(rx.RawFrameData().buf, frame.FrameData.buf) {
+		t.Error("IO data sample serialization mismatch", rx.RawFrameData().buf)
+	}
+}
+
+func TestParseRxIOPacket16MultiSample(t *testing.T) {
+	// Two samples of D0, D1 and A0, A1.
+	data := []byte{FrameTypeRxPacketIO16, 0x56, 0x78, 0x28, 0x00, 0x02, 0x06, 0x03,
+		0x00, 0x01, 0x01, 0x00, 0x00, 0x10,
+		0x00, 0x02, 0x03, 0xff, 0x00, 0x20}
+	rx, err := ParseRxIOPacket16(NewRawFrameData(data...))
+	if err != nil {
+		t.Error("Could not parse RxIOPacket16", err)
+		return
+	}
+	if rx.Address16 != 0x5678 || rx.RSSI != 0x28 || len(rx.Samples) != 2 {
+		t.Error("Unexpected RxIOPacket16", rx)
+		return
+	}
+	first, second := rx.Samples[0], rx.Samples[1]
+	if !first.Digital[PinD0] || first.Digital[PinD1] || first.Analog[PinAD0] != 0x100 || first.Analog[PinAD1] != 0x10 {
+		t.Error("Unexpected first sample", first)
+	}
+	if second.Digital[PinD0] || !second.Digital[PinD1] || second.Analog[PinAD0] != 0x3ff || second.Analog[PinAD1] != 0x20 {
+		t.Error("Unexpected second sample", second)
+	}
+	if !bytes.Equal(rx.RawFrameData().buf, data) {
+		t.Error("RxIOPacket16 serialization mismatch", rx.RawFrameData().buf)
+	}
+
+	if _, err := ParseRxIOPacket16(NewRawFrameData(data[:len(data)-1]...)); err == nil {
+		t.Error("Expected error for truncated samples")
+	}
+}
@@ -0,0 +1,126 @@
+package xbeeapi
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// joinTraceHistory is how many join attempts JoinTraces keeps.
+const joinTraceHistory = 8
+
+// JoinStep is an extended modem status received during a join attempt.
+type JoinStep struct {
+	Time   time.Time
+	Status *ExtendedModemStatus
+}
+
+// JoinTrace is the sequence of verbose join steps reported by the radio
+// for one join attempt.
+type JoinTrace struct {
+	Started time.Time
+	Steps   []JoinStep
+	// Joined is set once the attempt reached JoinJoined. Steps reported
+	// after the join, such as the stack status, are kept with it.
+	Joined bool
+}
+
+// String lists the steps of the attempt, one per line, with the time
+// elapsed since it started.
+func (t *JoinTrace) String() string {
+	var b strings.Builder
+	outcome := "not joined"
+	if t.Joined {
+		outcome = "joined"
+	}
+	fmt.Fprintf(&b, "join attempt at %s: %s\n", t.Started.Format(time.RFC3339Nano), outcome)
+	for _, s := range t.Steps {
+		fmt.Fprintf(&b, "  +%-10s %s\n", s.Time.Sub(t.Started).Round(time.Millisecond), s.Status)
+	}
+	return b.String()
+}
+
+// joinTracer groups extended modem status frames into join attempts.
+type joinTracer struct {
+	mu     *sync.Mutex
+	traces []*JoinTrace
+}
+
+func newJoinTracer() *joinTracer {
+	return &joinTracer{mu: &sync.Mutex{}}
+}
+
+// record adds ems to the current attempt. Only a rejoin, or joining
+// unless the current attempt has only seen the rejoin, starts a new one;
+// steps after a join, such as stack status, are added to the joined
+// attempt, and steps before any attempt are dropped.
+func (j *joinTracer) record(now time.Time, ems *ExtendedModemStatus) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var cur *JoinTrace
+	if n := len(j.traces); n > 0 {
+		cur = j.traces[n-1]
+	}
+	switch ems.Status {
+	case JoinRejoin:
+		cur = nil
+	case JoinJoining:
+		if cur != nil && (cur.Joined || cur.hasStep(JoinJoining)) {
+			cur = nil
+		}
+	default:
+		if cur == nil {
+			return
+		}
+	}
+	if cur == nil {
+		cur = &JoinTrace{Started: now}
+		j.traces = append(j.traces, cur)
+		if len(j.traces) > joinTraceHistory {
+			j.traces = append(j.traces[:0], j.traces[1:]...)
+		}
+	}
+	cur.Steps = append(cur.Steps, JoinStep{Time: now, Status: ems})
+	if ems.Status == JoinJoined {
+		cur.Joined = true
+	}
+}
+
+func (t *JoinTrace) hasStep(s JoinStatus) bool {
+	for _, step := range t.Steps {
+		if step.Status.Status == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *joinTracer) snapshot() []JoinTrace {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	traces := make([]JoinTrace, len(j.traces))
+	for i, t := range j.traces {
+		traces[i] = *t
+		traces[i].Steps = append([]JoinStep(nil), t.Steps...)
+	}
+	return traces
+}
+
+// JoinTraces returns the most recent join attempts reported through
+// extended modem status frames, oldest first. The radio only sends them
+// with verbose join information enabled.
+func (api *XBeeAPI) JoinTraces() []JoinTrace {
+	return api.joins.snapshot()
+}
+
+func (api *XBeeAPI) observeJoin(f *Frame) {
+	if f.FrameData.FrameType() != FrameTypeXBExtendedModemStatus {
+		return
+	}
+	if ems, err := ParseExtendedModemStatus(f.FrameData); err == nil {
+		api.joins.record(time.Now(), ems)
+	}
+}
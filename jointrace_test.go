@@ -0,0 +1,53 @@
+package xbeeapi
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJoinTrace(t *testing.T) {
+	j := newJoinTracer()
+	start := time.Now()
+	steps := []*ExtendedModemStatus{
+		{Status: JoinScanning, Data: []byte{0x0b}},
+		{Status: JoinRejoin},
+		{Status: JoinJoining},
+		{Status: JoinScanning, Data: []byte{0x0b}},
+		{Status: JoinRejectNotJoinable},
+		{Status: JoinAIChange, Data: []byte{byte(AssociationJoinNotAllowed)}},
+		{Status: JoinJoining},
+		{Status: JoinScanning, Data: []byte{0x0c}},
+		{Status: JoinJoined},
+		{Status: JoinStackStatus, Data: []byte{0x90}},
+		{Status: JoinJoining},
+	}
+	for i, s := range steps {
+		j.record(start.Add(time.Duration(i)*time.Millisecond), s)
+	}
+
+	traces := j.snapshot()
+	if len(traces) != 3 {
+		t.Fatal("Expected 3 join attempts, got", len(traces))
+	}
+	if len(traces[0].Steps) != 5 || traces[0].Joined || traces[0].Steps[0].Status.Status != JoinRejoin {
+		t.Error("Unexpected first attempt", traces[0].String())
+	}
+	if len(traces[1].Steps) != 4 || !traces[1].Joined || traces[1].Steps[3].Status.Status != JoinStackStatus {
+		t.Error("Unexpected second attempt", traces[1].String())
+	}
+	if len(traces[2].Steps) != 1 || traces[2].Joined {
+		t.Error("Unexpected third attempt", traces[2].String())
+	}
+	dump := traces[0].String()
+	if !strings.Contains(dump, "not joined") || !strings.Contains(dump, "Scanning Channel: channel 11") {
+		t.Error("Unexpected trace dump", dump)
+	}
+
+	for i := 0; i < 2*joinTraceHistory; i++ {
+		j.record(start, &ExtendedModemStatus{Status: JoinRejoin})
+	}
+	if n := len(j.snapshot()); n != joinTraceHistory {
+		t.Error("Expected", joinTraceHistory, "attempts kept, got", n)
+	}
+}
@@ -24,11 +24,19 @@ type ModemStatus struct {
 }
 
 func ParseModemStatus(rfd *RawFrameData) (*ModemStatus, error) {
-	if !rfd.IsValid() || len(rfd.Data()) != MinModemStatusSize {
-		return nil, &FrameParseError{msg: "Expecting frame type ModemStatus"}
+	c, err := newFrameCursor(rfd, FrameTypeModemStatus, "ModemStatus")
+	if err != nil {
+		return nil, err
+	}
+	ms := &ModemStatus{Status: c.uint8("Status")}
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.remaining() != 0 {
+		return nil, &LengthError{Expected: MinModemStatusSize, Actual: len(rfd.Data()), Data: rfd.Data()}
 	}
 
-	return &ModemStatus{Status: rfd.Data()[0]}, nil
+	return ms, nil
 }
 
 func (ms *ModemStatus) Description() string {
@@ -63,7 +71,7 @@ func (ms *ModemStatus) Description() string {
 }
 
 func (ms *ModemStatus) RawFrameData() *RawFrameData {
-	return NewRawFrameData(ms.Status)
+	return NewRawFrameData(FrameTypeModemStatus, ms.Status)
 }
 
 func (ms *ModemStatus) IsValid() bool {
@@ -0,0 +1,52 @@
+package xbeeapi
+
+// NodeIdentificationIndicator (0x95) is received when a radio announces
+// itself, e.g. after joining or when its commissioning button is pressed.
+type NodeIdentificationIndicator struct {
+	// SenderAddress64 and SenderAddress16 identify the radio that sent
+	// the frame, Node the radio being identified.
+	SenderAddress64 Address64
+	SenderAddress16 Address16
+	Options         byte
+	Node            NodeInfo
+}
+
+func ParseNodeIdentificationIndicator(rfd *RawFrameData) (*NodeIdentificationIndicator, error) {
+	c, err := newFrameCursor(rfd, FrameTypeXBNodeIdentificationIndicator, "NodeIdentificationIndicator")
+	if err != nil {
+		return nil, err
+	}
+
+	ni := &NodeIdentificationIndicator{
+		SenderAddress64: c.address64("SenderAddress64"),
+		SenderAddress16: c.address16("SenderAddress16"),
+		Options:         c.uint8("Options"),
+	}
+	ni.Node.Address16 = c.address16("Node.Address16")
+	ni.Node.Address64 = c.address64("Node.Address64")
+	if err := ni.Node.parseIdentification(c); err != nil {
+		return nil, err
+	}
+
+	return ni, nil
+}
+
+func (ni *NodeIdentificationIndicator) RawFrameData() *RawFrameData {
+	b := concat([]byte{FrameTypeXBNodeIdentificationIndicator}, ni.SenderAddress64.Bytes(), ni.SenderAddress16.Bytes())
+	b = append(b, ni.Options)
+	b = concat(b, ni.Node.Address16.Bytes(), ni.Node.Address64.Bytes(), ni.Node.identificationBytes())
+
+	return NewRawFrameData(b...)
+}
+
+func (ni *NodeIdentificationIndicator) IsValid() bool {
+	return true
+}
+
+func (ni *NodeIdentificationIndicator) FrameType() byte {
+	return FrameTypeXBNodeIdentificationIndicator
+}
+
+func (ni *NodeIdentificationIndicator) IsOptionsFlagSet(rxOptionFlag RxOptionFlag) bool {
+	return isRxOptionsFlagSet(ni.Options, rxOptionFlag)
+}
@@ -0,0 +1,162 @@
+package xbeeapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type DeviceType byte
+
+const (
+	DeviceTypeCoordinator DeviceType = 0x00
+	DeviceTypeRouter      DeviceType = 0x01
+	DeviceTypeEndDevice   DeviceType = 0x02
+)
+
+// SourceEvent tells why a Node Identification Indicator was sent.
+type SourceEvent byte
+
+const (
+	SourceEventNone       SourceEvent = 0x00
+	SourceEventPushbutton SourceEvent = 0x01
+	SourceEventJoined     SourceEvent = 0x02
+	SourceEventPowerCycle SourceEvent = 0x03
+)
+
+// NodeInfo describes a radio in the network, as reported by a Node
+// Identification Indicator or a node discovery (ND) response.
+type NodeInfo struct {
+	Address64       Address64
+	Address16       Address16
+	ParentAddress16 Address16
+	NodeIdentifier  string
+	DeviceType      DeviceType
+	// SourceEvent is only set by Node Identification Indicators. Node
+	// discovery responses carry a reserved status byte in its place.
+	SourceEvent    SourceEvent
+	ProfileID      uint16
+	ManufacturerID uint16
+	// DeviceTypeIdentifier (the DD value) and RSSI are only present if
+	// enabled with the NO command on the reporting radio.
+	DeviceTypeIdentifier    uint32
+	HasDeviceTypeIdentifier bool
+	RSSI                    byte
+	HasRSSI                 bool
+}
+
+// ParseNodeDiscoveryResponse decodes the parameters of an ND AT command
+// response from a Zigbee radio.
+func ParseNodeDiscoveryResponse(params []byte) (*NodeInfo, error) {
+	return ParseProtocolNodeDiscoveryResponse(ProtocolZigbee, params)
+}
+
+// ParseProtocolNodeDiscoveryResponse decodes the parameters of an ND AT
+// command response from a radio running protocol p. 802.15.4 responses
+// only carry the addresses, the RSSI of the response (DB) and the node
+// identifier.
+func ParseProtocolNodeDiscoveryResponse(p Protocol, params []byte) (*NodeInfo, error) {
+	c := newCursor(params)
+	ni := &NodeInfo{
+		Address16: c.address16("Address16"),
+		Address64: c.address64("Address64"),
+	}
+	parse := ni.parseIdentification
+	if p == Protocol802154 {
+		parse = ni.parse802154Identification
+	}
+	if err := parse(c); err != nil {
+		return nil, err
+	}
+
+	return ni, nil
+}
+
+// parse802154Identification decodes the fields following the addresses
+// in 802.15.4 ND responses: the RSSI and the node identifier, whose
+// terminating null byte some firmwares leave out.
+func (ni *NodeInfo) parse802154Identification(c *cursor) error {
+	ni.ParentAddress16 = Address16Unknown
+	ni.RSSI, ni.HasRSSI = c.uint8("RSSI"), true
+	ni.NodeIdentifier = string(bytes.TrimSuffix(c.rest(), []byte{0x00}))
+
+	return c.err
+}
+
+// parseIdentification decodes the fields following the addresses, shared
+// by ND responses and Node Identification Indicators.
+func (ni *NodeInfo) parseIdentification(c *cursor) error {
+	ni.NodeIdentifier = c.cstring("NodeIdentifier")
+	ni.ParentAddress16 = c.address16("ParentAddress16")
+	ni.DeviceType = DeviceType(c.uint8("DeviceType"))
+	ni.SourceEvent = SourceEvent(c.uint8("SourceEvent"))
+	ni.ProfileID = c.uint16("ProfileID")
+	ni.ManufacturerID = c.uint16("ManufacturerID")
+	if c.err != nil {
+		return c.err
+	}
+
+	switch c.remaining() {
+	case 0:
+	case 1:
+		ni.RSSI, ni.HasRSSI = c.uint8("RSSI"), true
+	case 4, 5:
+		ni.DeviceTypeIdentifier = c.uint32("DeviceTypeIdentifier")
+		ni.HasDeviceTypeIdentifier = true
+		if c.remaining() == 1 {
+			ni.RSSI, ni.HasRSSI = c.uint8("RSSI"), true
+		}
+	default:
+		return &FrameParseError{msg: fmt.Sprintf("Unexpected %d trailing bytes in node identification", c.remaining())}
+	}
+
+	return nil
+}
+
+func (ni *NodeInfo) identificationBytes() []byte {
+	b := concat([]byte(ni.NodeIdentifier), []byte{0x00}, ni.ParentAddress16.Bytes())
+	b = append(b, byte(ni.DeviceType), byte(ni.SourceEvent), 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(b[len(b)-4:], ni.ProfileID)
+	binary.BigEndian.PutUint16(b[len(b)-2:], ni.ManufacturerID)
+	if ni.HasDeviceTypeIdentifier {
+		b = append(b, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(b[len(b)-4:], ni.DeviceTypeIdentifier)
+	}
+	if ni.HasRSSI {
+		b = append(b, ni.RSSI)
+	}
+	return b
+}
+
+// NodeDiscoveryBytes encodes ni as the parameters of an ND response.
+func (ni *NodeInfo) NodeDiscoveryBytes() []byte {
+	return concat(ni.Address16.Bytes(), ni.Address64.Bytes(), ni.identificationBytes())
+}
+
+func (t DeviceType) Description() string {
+	switch t {
+	case DeviceTypeCoordinator:
+		return "Coordinator"
+	case DeviceTypeRouter:
+		return "Router"
+	case DeviceTypeEndDevice:
+		return "End Device"
+	}
+
+	return fmt.Sprintf("Unknown Device Type: %x", byte(t))
+}
+
+func (e SourceEvent) Description() string {
+	switch e {
+	case SourceEventNone:
+		return "None"
+	case SourceEventPushbutton:
+		return "Commissioning Pushbutton"
+	case SourceEventJoined:
+		return "Joined Network"
+	case SourceEventPowerCycle:
+		return "Power Cycle"
+	}
+
+	return fmt.Sprintf("Unknown Source Event: %x", byte(e))
+}
@@ -0,0 +1,197 @@
+package xbeeapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNoFrameID is returned when all 255 frame IDs are waiting for a response.
+var ErrNoFrameID = errors.New("No free frame ID available")
+
+// FrameIDSetter is implemented by frame data that carries a frame ID, so
+// that the response to it can be correlated.
+type FrameIDSetter interface {
+	FrameData
+	SetFrameID(id byte)
+}
+
+// pendingRequests tracks requests waiting for a response frame, keyed by
+// frame ID.
+type pendingRequests struct {
+	mu      *sync.Mutex
+	ids     *frameIDAllocator
+	waiters map[byte]*responseWaiter
+	// gen counts the calls to failAll, so that a waiter failed by it does
+	// not release a frame ID handed out again since.
+	gen int
+}
+
+// responseWaiter receives the response frames for one frame ID. A stream
+// waiter keeps receiving until removed, for commands such as ND that are
+// answered with several frames: they are queued without bound and ready
+// is signalled, so a slow consumer never holds up the reader. failed is
+// closed, with err set, if the request is abandoned by failAll.
+type responseWaiter struct {
+	id     byte
+	ch     chan *Frame
+	done   chan struct{}
+	failed chan struct{}
+	err    error
+	stream bool
+	gen    int
+
+	mu     *sync.Mutex
+	queued []*Frame
+	ready  chan struct{}
+}
+
+// push queues f for a stream waiter.
+func (w *responseWaiter) push(f *Frame) {
+	w.mu.Lock()
+	w.queued = append(w.queued, f)
+	w.mu.Unlock()
+	select {
+	case w.ready <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the frames queued for a stream waiter since the last call.
+func (w *responseWaiter) take() (frames []*Frame) {
+	w.mu.Lock()
+	frames, w.queued = w.queued, nil
+	w.mu.Unlock()
+	return
+}
+
+func newPendingRequests() *pendingRequests {
+	return &pendingRequests{
+		mu:      &sync.Mutex{},
+		ids:     newFrameIDAllocator(),
+		waiters: make(map[byte]*responseWaiter),
+	}
+}
+
+// add reserves a free frame ID, blocking while all of them are
+// outstanding, and returns the waiter its response will be delivered to.
+// A non-nil accept limits the frame IDs to those it accepts.
+func (p *pendingRequests) add(ctx context.Context, accept func(id byte) bool) (*responseWaiter, error) {
+	return p.addWaiter(ctx, false, accept)
+}
+
+// addStream is like add, but every response frame with the frame ID is
+// delivered until remove is called.
+func (p *pendingRequests) addStream(ctx context.Context) (*responseWaiter, error) {
+	return p.addWaiter(ctx, true, nil)
+}
+
+func (p *pendingRequests) addWaiter(ctx context.Context, stream bool, accept func(id byte) bool) (*responseWaiter, error) {
+	id, err := p.ids.acquire(ctx, 0, accept)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &responseWaiter{
+		id:     id,
+		ch:     make(chan *Frame, 1),
+		done:   make(chan struct{}),
+		failed: make(chan struct{}),
+		stream: stream,
+		mu:     &sync.Mutex{},
+		ready:  make(chan struct{}, 1),
+	}
+	p.mu.Lock()
+	w.gen = p.gen
+	p.waiters[id] = w
+	p.mu.Unlock()
+
+	return w, nil
+}
+
+// remove stops waiting for a response on w's frame ID and releases it.
+func (p *pendingRequests) remove(w *responseWaiter) {
+	p.mu.Lock()
+	if p.waiters[w.id] == w {
+		close(w.done)
+		delete(p.waiters, w.id)
+	}
+	current := w.gen == p.gen
+	p.mu.Unlock()
+	if current {
+		p.ids.release(w.id)
+	}
+}
+
+// failAll abandons every waiting request with err and releases all frame
+// IDs, since responses to them can no longer be told apart from responses
+// to new requests.
+func (p *pendingRequests) failAll(err error) {
+	p.mu.Lock()
+	for _, w := range p.waiters {
+		w.err = err
+		close(w.failed)
+		close(w.done)
+	}
+	p.waiters = make(map[byte]*responseWaiter)
+	p.gen++
+	p.mu.Unlock()
+	p.ids.reset()
+}
+
+// reserve takes a frame ID for a request sent without waiting for its
+// response. It is released by deliver or once expiry has passed.
+func (p *pendingRequests) reserve(expiry time.Duration, accept func(id byte) bool) (byte, error) {
+	return p.ids.tryAcquire(expiry, accept)
+}
+
+// deliver hands a response frame to the request waiting on its frame ID,
+// or releases the ID if it was reserved without a waiter. It returns false
+// if nobody was waiting for it.
+func (p *pendingRequests) deliver(f *Frame) bool {
+	id, ok := responseFrameID(f.FrameData)
+	if !ok {
+		return false
+	}
+
+	p.mu.Lock()
+	w, ok := p.waiters[id]
+	if ok && !w.stream {
+		delete(p.waiters, id)
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		p.ids.releaseExpiring(id)
+		return false
+	}
+	if w.stream {
+		w.push(f)
+		return true
+	}
+	select {
+	case w.ch <- f:
+	case <-w.done:
+	}
+	return true
+}
+
+// responseFrameID returns the frame ID of frame types sent by the radio
+// in response to a request.
+func responseFrameID(rfd *RawFrameData) (byte, bool) {
+	if rfd.Len() < 2 {
+		return 0, false
+	}
+	switch rfd.FrameType() {
+	case FrameTypeATCommandResponse,
+		FrameTypeTxStatus,
+		FrameTypeXBTxStatus,
+		FrameTypeRemoteATCommandResponse,
+		FrameTypeWiFiRemoteATCommandResponse:
+		id := rfd.Data()[0]
+		return id, id != 0
+	}
+
+	return 0, false
+}
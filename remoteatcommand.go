@@ -0,0 +1,75 @@
+package xbeeapi
+
+type RemoteATOptionFlag byte
+
+const (
+	RemoteATOptionDisableAck      RemoteATOptionFlag = 0x01
+	RemoteATOptionApplyChanges    RemoteATOptionFlag = 0x02
+	RemoteATOptionExtendedTimeout RemoteATOptionFlag = 0x40
+)
+
+// RemoteATCommand queries or sets an AT command parameter on another radio
+// in the network (0x17).
+type RemoteATCommand struct {
+	FrameID   byte
+	Address64 Address64
+	Address16 Address16
+	Options   byte
+	Command   string
+	Params    []byte
+}
+
+func ParseRemoteATCommand(rfd *RawFrameData) (*RemoteATCommand, error) {
+	c, err := newFrameCursor(rfd, FrameTypeRemoteATCommand, "RemoteATCommand")
+	if err != nil {
+		return nil, err
+	}
+	at := &RemoteATCommand{
+		FrameID:   c.uint8("FrameID"),
+		Address64: c.address64("Address64"),
+		Address16: c.address16("Address16"),
+		Options:   c.uint8("Options"),
+		Command:   c.string("Command", 2),
+		Params:    c.rest(),
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	if !at.IsValid() {
+		return nil, &FrameParseError{msg: "Invalid frame data for RemoteATCommand"}
+	}
+
+	return at, nil
+}
+
+func (at *RemoteATCommand) RawFrameData() *RawFrameData {
+	b := []byte{FrameTypeRemoteATCommand, at.FrameID}
+	b = concat(b, at.Address64.Bytes(), at.Address16.Bytes())
+	b = append(b, at.Options)
+
+	return NewRawFrameData(concat(b, []byte(at.Command), at.Params)...)
+}
+
+func (at *RemoteATCommand) IsValid() bool {
+	return len(at.Command) == 2
+}
+
+func (at *RemoteATCommand) FrameType() byte {
+	return FrameTypeRemoteATCommand
+}
+
+func (at *RemoteATCommand) SetFrameID(id byte) {
+	at.FrameID = id
+}
+
+func (at *RemoteATCommand) SetOptionsFlags(optionFlags ...RemoteATOptionFlag) {
+	var options byte
+	for _, flag := range optionFlags {
+		options |= byte(flag)
+	}
+	at.Options = options
+}
+
+func (at *RemoteATCommand) IsOptionsFlagSet(optionFlag RemoteATOptionFlag) bool {
+	return at.Options&byte(optionFlag) != 0
+}
@@ -0,0 +1,50 @@
+package xbeeapi
+
+// RemoteATCommandResponse is the answer to a RemoteATCommand (0x97).
+type RemoteATCommandResponse struct {
+	FrameID   byte
+	Address64 Address64
+	Address16 Address16
+	Command   string
+	Status    byte
+	Params    []byte
+}
+
+func ParseRemoteATCommandResponse(rfd *RawFrameData) (*RemoteATCommandResponse, error) {
+	c, err := newFrameCursor(rfd, FrameTypeRemoteATCommandResponse, "RemoteATCommandResponse")
+	if err != nil {
+		return nil, err
+	}
+	atr := &RemoteATCommandResponse{
+		FrameID:   c.uint8("FrameID"),
+		Address64: c.address64("Address64"),
+		Address16: c.address16("Address16"),
+		Command:   c.string("Command", 2),
+		Status:    c.uint8("Status"),
+		Params:    c.rest(),
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	if !atr.IsValid() {
+		return nil, &FrameParseError{msg: "Invalid frame data for RemoteATCommandResponse"}
+	}
+
+	return atr, nil
+}
+
+func (atr *RemoteATCommandResponse) RawFrameData() *RawFrameData {
+	b := []byte{FrameTypeRemoteATCommandResponse, atr.FrameID}
+	b = concat(b, atr.Address64.Bytes(), atr.Address16.Bytes(), []byte(atr.Command))
+	b = append(b, atr.Status)
+
+	return NewRawFrameData(concat(b, atr.Params)...)
+}
+
+func (atr *RemoteATCommandResponse) IsValid() bool {
+	return atr.Status < ATCommandStatusUnknown && len(atr.Command) == 2
+}
+
+func (atr *RemoteATCommandResponse) FrameType() byte {
+	return FrameTypeRemoteATCommandResponse
+}
@@ -0,0 +1,128 @@
+package xbeeapi
+
+import "bytes"
+
+// ringBuffer is a growable circular byte buffer. Its size is always a
+// power of two.
+type ringBuffer struct {
+	buf  []byte
+	head int
+	n    int
+}
+
+func newRingBuffer(size int) ringBuffer {
+	return ringBuffer{buf: make([]byte, ceilPow2(size))}
+}
+
+func ceilPow2(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+func (r *ringBuffer) len() int {
+	return r.n
+}
+
+func (r *ringBuffer) mask(i int) int {
+	return i & (len(r.buf) - 1)
+}
+
+// at returns the byte at offset i from the start of the buffered bytes.
+func (r *ringBuffer) at(i int) byte {
+	return r.buf[r.mask(r.head+i)]
+}
+
+// segments returns the n bytes at offset off as up to two slices of the
+// underlying buffer.
+func (r *ringBuffer) segments(off, n int) ([]byte, []byte) {
+	start := r.mask(r.head + off)
+	if start+n <= len(r.buf) {
+		return r.buf[start : start+n], nil
+	}
+	return r.buf[start:], r.buf[:start+n-len(r.buf)]
+}
+
+// sum returns the byte sum of the n bytes at offset off.
+func (r *ringBuffer) sum(off, n int) (cs byte) {
+	a, b := r.segments(off, n)
+	for _, c := range a {
+		cs += c
+	}
+	for _, c := range b {
+		cs += c
+	}
+	return
+}
+
+// index returns the offset of the first c at or after offset from, or -1.
+func (r *ringBuffer) index(c byte, from int) int {
+	if from >= r.n {
+		return -1
+	}
+	a, b := r.segments(from, r.n-from)
+	if i := bytes.IndexByte(a, c); i >= 0 {
+		return from + i
+	}
+	if i := bytes.IndexByte(b, c); i >= 0 {
+		return from + len(a) + i
+	}
+	return -1
+}
+
+// copyOut fills dst with the bytes at offset off.
+func (r *ringBuffer) copyOut(dst []byte, off int) {
+	a, b := r.segments(off, len(dst))
+	copy(dst[copy(dst, a):], b)
+}
+
+func (r *ringBuffer) discard(n int) {
+	r.head = r.mask(r.head + n)
+	r.n -= n
+	if r.n == 0 {
+		r.head = 0
+	}
+}
+
+// reserve grows the buffer to hold at least size bytes.
+func (r *ringBuffer) reserve(size int) {
+	if size <= len(r.buf) {
+		return
+	}
+	buf := make([]byte, ceilPow2(size))
+	r.copyOut(buf[:r.n], 0)
+	r.buf = buf
+	r.head = 0
+}
+
+// free returns the writable space following the buffered bytes, growing
+// the buffer if it is full. Bytes written to it are added with commit.
+func (r *ringBuffer) free() []byte {
+	if r.n == len(r.buf) {
+		r.reserve(2 * len(r.buf))
+	}
+	tail := r.mask(r.head + r.n)
+	if tail < r.head {
+		return r.buf[tail:r.head]
+	}
+	return r.buf[tail:]
+}
+
+func (r *ringBuffer) commit(n int) {
+	r.n += n
+}
+
+func (r *ringBuffer) writeByte(c byte) {
+	r.free()[0] = c
+	r.n++
+}
+
+func (r *ringBuffer) write(b []byte) {
+	for len(b) > 0 {
+		n := copy(r.free(), b)
+		r.n += n
+		b = b[n:]
+	}
+}
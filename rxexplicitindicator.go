@@ -1,16 +1,12 @@
 package xbeeapi
 
-import (
-	"bytes"
-	"encoding/binary"
-)
+import "encoding/binary"
 
-const MinRxExplicitIndicatorSize = 29
+const MinRxExplicitIndicatorSize = 18
 
 type RxExplicitIndicator struct {
-	FrameID     byte
-	Address64   string
-	Address16   string
+	Address64   Address64
+	Address16   Address16
 	SrcEndPoint byte
 	DstEndPoint byte
 	ClusterID   uint16
@@ -20,24 +16,23 @@ type RxExplicitIndicator struct {
 }
 
 func ParseRxExplicitIndicator(rfd *RawFrameData) (*RxExplicitIndicator, error) {
-	if !rfd.IsValid() || rfd.FrameType() != FrameTypeExplicitRxIndicator {
-		return nil, &FrameParseError{msg: "Expecting frame type RxExplicitIndicator"}
+	c, err := newFrameCursor(rfd, FrameTypeExplicitRxIndicator, "RxExplicitIndicator")
+	if err != nil {
+		return nil, err
 	}
-	if rfd.Len() < MinRxExplicitIndicatorSize {
-		return nil, &FrameParseError{msg: "Frame data too small for RxExplicitIndicator"}
-	}
-	buf := bytes.NewBuffer(rfd.Data())
 
 	tx := &RxExplicitIndicator{
-		FrameID:     buf.Next(1)[0],
-		Address64:   bytesToHex(buf.Next(16)),
-		Address16:   bytesToHex(buf.Next(4)),
-		SrcEndPoint: buf.Next(1)[0],
-		DstEndPoint: buf.Next(1)[0],
-		ClusterID:   binary.BigEndian.Uint16(buf.Next(2)),
-		ProfileID:   binary.BigEndian.Uint16(buf.Next(2)),
-		Options:     buf.Next(1)[0],
-		Payload:     copySlice(buf.Bytes()),
+		Address64:   c.address64("Address64"),
+		Address16:   c.address16("Address16"),
+		SrcEndPoint: c.uint8("SrcEndPoint"),
+		DstEndPoint: c.uint8("DstEndPoint"),
+		ClusterID:   c.uint16("ClusterID"),
+		ProfileID:   c.uint16("ProfileID"),
+		Options:     c.uint8("Options"),
+		Payload:     c.rest(),
+	}
+	if c.err != nil {
+		return nil, c.err
 	}
 
 	if !tx.IsValid() {
@@ -48,10 +43,8 @@ func ParseRxExplicitIndicator(rfd *RawFrameData) (*RxExplicitIndicator, error) {
 }
 
 func (rx *RxExplicitIndicator) RawFrameData() *RawFrameData {
-	b := []byte{FrameTypeExplicitRxIndicator, rx.FrameID}
-	address64, _ := hexToBytes(rx.Address64)
-	address16, _ := hexToBytes(rx.Address16)
-	b = concat(b, address64, address16)
+	b := []byte{FrameTypeExplicitRxIndicator}
+	b = concat(b, rx.Address64.Bytes(), rx.Address16.Bytes())
 	b = append(b, rx.SrcEndPoint, rx.DstEndPoint, 0x00, 0x00, 0x00, 0x00)
 	binary.BigEndian.PutUint16(b[(len(b)-4):], rx.ClusterID)
 	binary.BigEndian.PutUint16(b[(len(b)-2):], rx.ProfileID)
@@ -62,13 +55,7 @@ func (rx *RxExplicitIndicator) RawFrameData() *RawFrameData {
 }
 
 func (rx *RxExplicitIndicator) IsValid() bool {
-	address64, _ := hexToBytes(rx.Address64)
-	address16, _ := hexToBytes(rx.Address16)
-	if len(address64) == 16 && len(address16) == 4 {
-		return true
-	}
-
-	return false
+	return true
 }
 
 func (rx *RxExplicitIndicator) FrameType() byte {
@@ -0,0 +1,90 @@
+package xbeeapi
+
+// RxIOPacket64 is an 802.15.4 IO sample packet (0x82) from a radio
+// addressed by its 64-bit address. It can carry several samples taken
+// with the same channel configuration.
+type RxIOPacket64 struct {
+	Address64 Address64
+	RSSI      byte
+	Options   byte
+	Samples   []IOSample
+}
+
+// RxIOPacket16 is an 802.15.4 IO sample packet (0x83) from a radio
+// addressed by its 16-bit address.
+type RxIOPacket16 struct {
+	Address16 Address16
+	RSSI      byte
+	Options   byte
+	Samples   []IOSample
+}
+
+func ParseRxIOPacket64(rfd *RawFrameData) (*RxIOPacket64, error) {
+	c, err := newFrameCursor(rfd, FrameTypeRxPacketIO64, "RxIOPacket64")
+	if err != nil {
+		return nil, err
+	}
+
+	rx := &RxIOPacket64{
+		Address64: c.address64("Address64"),
+		RSSI:      c.uint8("RSSI"),
+		Options:   c.uint8("Options"),
+	}
+	samples, err := parseLegacyIOSamples(c)
+	if err != nil {
+		return nil, err
+	}
+	rx.Samples = samples
+
+	return rx, nil
+}
+
+func (rx *RxIOPacket64) RawFrameData() *RawFrameData {
+	b := concat([]byte{FrameTypeRxPacketIO64}, rx.Address64.Bytes())
+	b = append(b, rx.RSSI, rx.Options)
+
+	return NewRawFrameData(concat(b, legacyIOSampleBytes(rx.Samples))...)
+}
+
+func (rx *RxIOPacket64) IsValid() bool {
+	return true
+}
+
+func (rx *RxIOPacket64) FrameType() byte {
+	return FrameTypeRxPacketIO64
+}
+
+func ParseRxIOPacket16(rfd *RawFrameData) (*RxIOPacket16, error) {
+	c, err := newFrameCursor(rfd, FrameTypeRxPacketIO16, "RxIOPacket16")
+	if err != nil {
+		return nil, err
+	}
+
+	rx := &RxIOPacket16{
+		Address16: c.address16("Address16"),
+		RSSI:      c.uint8("RSSI"),
+		Options:   c.uint8("Options"),
+	}
+	samples, err := parseLegacyIOSamples(c)
+	if err != nil {
+		return nil, err
+	}
+	rx.Samples = samples
+
+	return rx, nil
+}
+
+func (rx *RxIOPacket16) RawFrameData() *RawFrameData {
+	b := concat([]byte{FrameTypeRxPacketIO16}, rx.Address16.Bytes())
+	b = append(b, rx.RSSI, rx.Options)
+
+	return NewRawFrameData(concat(b, legacyIOSampleBytes(rx.Samples))...)
+}
+
+func (rx *RxIOPacket16) IsValid() bool {
+	return true
+}
+
+func (rx *RxIOPacket16) FrameType() byte {
+	return FrameTypeRxPacketIO16
+}
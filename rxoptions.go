@@ -7,6 +7,9 @@ const (
 	RxOptionBroadcastPacket    RxOptionFlag = 0x02
 	RxOptionEnableAPSEncyption RxOptionFlag = 0x20
 	RxOptionUseTimeout         RxOptionFlag = 0x40
+	// RxOptionFromEndDevice is set on Receive Packet frames sent by an
+	// end device. It shares its value with RxOptionUseTimeout.
+	RxOptionFromEndDevice RxOptionFlag = 0x40
 )
 
 func setRxOptionsFlags(options byte, rxOptionFlags ...RxOptionFlag) byte {
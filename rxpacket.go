@@ -0,0 +1,58 @@
+package xbeeapi
+
+// RxPacket is a Zigbee Receive Packet (0x90), delivered for data sent to
+// this radio with a TxRequest.
+type RxPacket struct {
+	Address64 Address64
+	Address16 Address16
+	Options   byte
+	Payload   []byte
+}
+
+func ParseRxPacket(rfd *RawFrameData) (*RxPacket, error) {
+	c, err := newFrameCursor(rfd, FrameTypeXBRxResponse, "RxPacket")
+	if err != nil {
+		return nil, err
+	}
+
+	rx := &RxPacket{
+		Address64: c.address64("Address64"),
+		Address16: c.address16("Address16"),
+		Options:   c.uint8("Options"),
+		Payload:   c.rest(),
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	if !rx.IsValid() {
+		return nil, &FrameParseError{msg: "Invalid frame data for RxPacket"}
+	}
+
+	return rx, nil
+}
+
+func (rx *RxPacket) RawFrameData() *RawFrameData {
+	b := []byte{FrameTypeXBRxResponse}
+	b = concat(b, rx.Address64.Bytes(), rx.Address16.Bytes())
+	b = append(b, rx.Options)
+	b = concat(b, rx.Payload)
+
+	return NewRawFrameData(b...)
+}
+
+func (rx *RxPacket) IsValid() bool {
+	return true
+}
+
+func (rx *RxPacket) FrameType() byte {
+	return FrameTypeXBRxResponse
+}
+
+func (rx *RxPacket) SetOptionsFlags(rxOptionFlags ...RxOptionFlag) {
+	rx.Options = setRxOptionsFlags(rx.Options, rxOptionFlags...)
+}
+
+func (rx *RxPacket) IsOptionsFlagSet(rxOptionFlag RxOptionFlag) bool {
+	return isRxOptionsFlagSet(rx.Options, rxOptionFlag)
+}
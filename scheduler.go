@@ -0,0 +1,316 @@
+package xbeeapi
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultMaxInFlightTransmits is how many transmissions may await their
+// TransmitStatus unless set with WithMaxInFlightTransmits.
+const DefaultMaxInFlightTransmits = 4
+
+// resourceBackoff is how long transmissions are held back after the radio
+// reports it ran out of buffers.
+const resourceBackoff = 250 * time.Millisecond
+
+// Priority orders frames waiting to be written to the radio. Frames of a
+// higher priority are written first; frames of the same priority in the
+// order they were sent.
+type Priority byte
+
+const (
+	// PriorityControl is the default for AT commands.
+	PriorityControl Priority = iota
+	// PriorityNormal is the default for every other frame.
+	PriorityNormal
+	// PriorityBulk is for data that can wait for everything else.
+	PriorityBulk
+
+	numPriorities = iota
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityControl:
+		return "control"
+	case PriorityNormal:
+		return "normal"
+	case PriorityBulk:
+		return "bulk"
+	}
+	return "unknown"
+}
+
+type prioritized struct {
+	FrameData
+	priority Priority
+}
+
+// WithPriority makes SendFrames queue fd with priority p instead of the
+// default for its frame type. fd may be marked with AutoFrameID. A p
+// past PriorityBulk is taken as PriorityBulk.
+func WithPriority(p Priority, fd FrameData) FrameData {
+	if p >= numPriorities {
+		p = PriorityBulk
+	}
+	return &prioritized{FrameData: fd, priority: p}
+}
+
+func defaultPriority(frameType byte) Priority {
+	switch frameType {
+	case FrameTypeATCommand, FrameTypeATCommandQueueRegisterValue, FrameTypeRemoteATCommand:
+		return PriorityControl
+	}
+	return PriorityNormal
+}
+
+// isTransmit reports whether frames of frameType are answered with a
+// transmit status.
+func isTransmit(frameType byte) bool {
+	switch frameType {
+	case FrameTypeTxRequest64, FrameTypeTxRequest16, FrameTypeTxRequest, FrameTypeExplicitAddressingCommandFrame:
+		return true
+	}
+	return false
+}
+
+type txRequest struct {
+	frame    *Frame
+	priority Priority
+	// transmitID is the frame ID of a transmission that counts towards
+	// the in-flight limit, zero otherwise.
+	transmitID byte
+}
+
+// txScheduler writes frames to the radio one whole frame at a time, in
+// priority order, holding back transmissions while too many of them await
+// their transmit status and everything while paused.
+type txScheduler struct {
+	mu          *sync.Mutex
+	enc         *Encoder
+	queues      [numPriorities][]*txRequest
+	inFlight    map[byte]time.Time
+	maxInFlight int
+	expiry      time.Duration
+	backoff     time.Time
+	paused      bool
+	writing     bool
+	changed     chan struct{}
+	// down fails every frame while the port is being replaced.
+	down error
+	// onWrite is called with the result of every write.
+	onWrite func(error)
+}
+
+func newTxScheduler(enc *Encoder, maxInFlight int, expiry time.Duration) *txScheduler {
+	return &txScheduler{
+		mu:          &sync.Mutex{},
+		enc:         enc,
+		inFlight:    make(map[byte]time.Time),
+		maxInFlight: maxInFlight,
+		expiry:      expiry,
+		changed:     make(chan struct{}),
+	}
+}
+
+// notifyLocked wakes every sender waiting for its turn.
+func (s *txScheduler) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func newTxRequest(f *Frame, priority Priority) *txRequest {
+	req := &txRequest{frame: f, priority: priority}
+	if f.FrameData.Len() > 1 && isTransmit(f.FrameData.FrameType()) {
+		req.transmitID = f.FrameData.Data()[0]
+	}
+	return req
+}
+
+// send writes reqs in order, each once it is its turn. It returns the
+// number of frames written.
+func (s *txScheduler) send(ctx context.Context, reqs ...*txRequest) (int, error) {
+	for i, req := range reqs {
+		if err := s.write(ctx, req); err != nil {
+			return i, err
+		}
+	}
+	return len(reqs), nil
+}
+
+func (s *txScheduler) write(ctx context.Context, req *txRequest) error {
+	s.mu.Lock()
+	s.queues[req.priority] = append(s.queues[req.priority], req)
+	for s.nextLocked(time.Now()) != req {
+		if s.down != nil {
+			s.removeLocked(req)
+			err := s.down
+			s.mu.Unlock()
+			return err
+		}
+		changed := s.changed
+		wait := s.waitLocked(time.Now())
+		s.mu.Unlock()
+
+		var t *time.Timer
+		var timeout <-chan time.Time
+		if wait > 0 {
+			t = time.NewTimer(wait)
+			timeout = t.C
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+		case <-ctx.Done():
+		}
+		if t != nil {
+			t.Stop()
+		}
+		s.mu.Lock()
+		if ctx.Err() != nil {
+			s.removeLocked(req)
+			s.notifyLocked()
+			s.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+	s.removeLocked(req)
+	s.writing = true
+	s.mu.Unlock()
+
+	err := s.enc.EncodeFrame(req.frame)
+
+	s.mu.Lock()
+	s.writing = false
+	if err == nil && req.transmitID != 0 {
+		s.inFlight[req.transmitID] = time.Now().Add(s.expiry)
+	}
+	s.notifyLocked()
+	onWrite := s.onWrite
+	s.mu.Unlock()
+
+	if onWrite != nil {
+		onWrite(err)
+	}
+	return err
+}
+
+// nextLocked returns the request to write next, or nil if none may be
+// written now.
+func (s *txScheduler) nextLocked(now time.Time) *txRequest {
+	if s.paused || s.writing || s.down != nil {
+		return nil
+	}
+	s.expireLocked(now)
+	blocked := s.maxInFlight > 0 && len(s.inFlight) >= s.maxInFlight || now.Before(s.backoff)
+	for _, q := range s.queues {
+		for _, req := range q {
+			if req.transmitID == 0 || !blocked {
+				return req
+			}
+		}
+	}
+	return nil
+}
+
+// waitLocked returns how long until a held back transmission may go, or
+// zero if that only changes with a notification.
+func (s *txScheduler) waitLocked(now time.Time) time.Duration {
+	next := s.backoff
+	for _, expires := range s.inFlight {
+		if next.Before(now) || expires.Before(next) {
+			next = expires
+		}
+	}
+	if next.Before(now) {
+		return 0
+	}
+	return next.Sub(now)
+}
+
+func (s *txScheduler) expireLocked(now time.Time) {
+	for id, expires := range s.inFlight {
+		if !now.Before(expires) {
+			delete(s.inFlight, id)
+		}
+	}
+}
+
+func (s *txScheduler) removeLocked(req *txRequest) {
+	q := s.queues[req.priority]
+	for i, r := range q {
+		if r == req {
+			s.queues[req.priority] = append(q[:i:i], q[i+1:]...)
+			return
+		}
+	}
+}
+
+// observe ends the flight of the transmission a transmit status is for,
+// and backs off when the radio ran out of buffers.
+func (s *txScheduler) observe(f *Frame) {
+	rfd := f.FrameData
+	if rfd.Len() < 2 {
+		return
+	}
+	switch rfd.FrameType() {
+	case FrameTypeTxStatus, FrameTypeXBTxStatus:
+	default:
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.inFlight, rfd.Data()[0])
+	if ts, err := ParseTransmitStatus(rfd); err == nil {
+		switch ts.DeliveryStatus {
+		case DeliveryResourceErrorBuffers, DeliveryInternalResourceError, DeliveryResourceError:
+			s.backoff = time.Now().Add(resourceBackoff)
+		}
+	}
+	s.notifyLocked()
+}
+
+// setDown fails queued and new frames with err until called with nil, and
+// forgets the transmissions in flight, whose statuses will never arrive.
+func (s *txScheduler) setDown(err error) {
+	s.mu.Lock()
+	s.down = err
+	clear(s.inFlight)
+	s.backoff = time.Time{}
+	s.notifyLocked()
+	s.mu.Unlock()
+}
+
+func (s *txScheduler) setPaused(paused bool) {
+	s.mu.Lock()
+	s.paused = paused
+	s.notifyLocked()
+	s.mu.Unlock()
+}
+
+func (s *txScheduler) isPaused() (p bool) {
+	s.mu.Lock()
+	p = s.paused
+	s.mu.Unlock()
+	return
+}
+
+// depth returns the number of queued frames per priority.
+func (s *txScheduler) depth() (d [numPriorities]int) {
+	s.mu.Lock()
+	for p, q := range s.queues {
+		d[p] = len(q)
+	}
+	s.mu.Unlock()
+	return
+}
+
+func (s *txScheduler) inFlightCount() (n int) {
+	s.mu.Lock()
+	s.expireLocked(time.Now())
+	n = len(s.inFlight)
+	s.mu.Unlock()
+	return
+}
@@ -0,0 +1,227 @@
+package xbeeapi
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordPort records the frames written to it and fails the test if a
+// write is not exactly one whole frame.
+type recordPort struct {
+	t       *testing.T
+	mu      sync.Mutex
+	frames  []*Frame
+	written chan struct{}
+}
+
+func newRecordPort(t *testing.T) *recordPort {
+	return &recordPort{t: t, written: make(chan struct{}, 1024)}
+}
+
+func (p *recordPort) Read(b []byte) (int, error) {
+	select {}
+}
+
+func (p *recordPort) Write(b []byte) (int, error) {
+	f, err := Deserialize(b)
+	if err != nil {
+		p.t.Error("Write of partial or interleaved frame:", err)
+		return len(b), nil
+	}
+	p.mu.Lock()
+	p.frames = append(p.frames, f)
+	p.mu.Unlock()
+	p.written <- struct{}{}
+	return len(b), nil
+}
+
+func (p *recordPort) frameTypes() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	types := []byte(nil)
+	for _, f := range p.frames {
+		types = append(types, f.FrameData.FrameType())
+	}
+	return types
+}
+
+func (p *recordPort) waitWritten(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-p.written:
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for frame", i+1, "of", n)
+		}
+	}
+}
+
+func waitQueueDepth(t *testing.T, api *XBeeAPI, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for api.QueueDepth() != n {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected queue depth", n, "got", api.QueueDepth())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerPriority(t *testing.T) {
+	port := newRecordPort(t)
+	api := NewXBeeAPI(port, nil)
+	api.PauseTransmit()
+
+	send := func(fd FrameData) {
+		go func() {
+			if _, err := api.SendFrames(fd); err != nil {
+				t.Error("SendFrames error", err)
+			}
+		}()
+	}
+	send(WithPriority(PriorityBulk, &TxRequest{FrameID: 1}))
+	waitQueueDepth(t, api, 1)
+	send(&TxRequest{FrameID: 2})
+	waitQueueDepth(t, api, 2)
+	send(WithPriority(PriorityBulk, AutoFrameID(&TxRequest{})))
+	waitQueueDepth(t, api, 3)
+	send(&ATCommand{FrameID: 4, Command: "NI"})
+	waitQueueDepth(t, api, 4)
+
+	if n := api.QueueDepthByPriority(PriorityBulk); n != 2 {
+		t.Error("Expected 2 bulk frames queued, got", n)
+	}
+	if len(port.frameTypes()) != 0 {
+		t.Fatal("Frames written while paused")
+	}
+
+	api.ResumeTransmit()
+	port.waitWritten(t, 4)
+
+	types := port.frameTypes()
+	expected := []byte{FrameTypeATCommand, FrameTypeTxRequest, FrameTypeTxRequest, FrameTypeTxRequest}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Fatalf("Expected frame types % x, got % x", expected, types)
+		}
+	}
+	if port.frames[1].FrameData.Data()[0] != 2 || port.frames[2].FrameData.Data()[0] != 1 {
+		t.Error("Expected normal transmit before bulk, got frame IDs", port.frames[1].FrameData.Data()[0], port.frames[2].FrameData.Data()[0])
+	}
+}
+
+func TestSchedulerPriorityOutOfRange(t *testing.T) {
+	port := newRecordPort(t)
+	api := NewXBeeAPI(port, nil)
+	api.PauseTransmit()
+
+	go func() {
+		if _, err := api.SendFrames(WithPriority(Priority(3), &TxRequest{FrameID: 1})); err != nil {
+			t.Error("SendFrames error", err)
+		}
+	}()
+	waitQueueDepth(t, api, 1)
+	if n := api.QueueDepthByPriority(PriorityBulk); n != 1 {
+		t.Error("Expected the frame queued as bulk, got", n)
+	}
+	api.ResumeTransmit()
+	port.waitWritten(t, 1)
+}
+
+func TestSchedulerInFlightLimit(t *testing.T) {
+	port := newRecordPort(t)
+	api := NewXBeeAPI(port, nil, WithMaxInFlightTransmits(2))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := api.SendFrames(&TxRequest{FrameID: 1}, &TxRequest{FrameID: 2}, &TxRequest{FrameID: 3}); err != nil {
+			t.Error("SendFrames error", err)
+		}
+	}()
+	port.waitWritten(t, 2)
+	waitQueueDepth(t, api, 1)
+	if n := api.TransmitsInFlight(); n != 2 {
+		t.Error("Expected 2 transmits in flight, got", n)
+	}
+
+	// Control frames are not held back by the in-flight limit.
+	if _, err := api.SendFrames(&ATCommand{FrameID: 9, Command: "NI"}); err != nil {
+		t.Fatal("SendFrames error", err)
+	}
+	port.waitWritten(t, 1)
+
+	api.tx.observe(NewFrame(&TransmitStatus{FrameID: 1}))
+	port.waitWritten(t, 1)
+	<-done
+
+	types := port.frameTypes()
+	if len(types) != 4 || types[2] != FrameTypeATCommand || port.frames[3].FrameData.Data()[0] != 3 {
+		t.Errorf("Unexpected frames written: % x", types)
+	}
+}
+
+func TestSchedulerResourceBackoff(t *testing.T) {
+	port := newRecordPort(t)
+	api := NewXBeeAPI(port, nil)
+
+	api.tx.observe(NewFrame(&TransmitStatus{FrameID: 1, DeliveryStatus: DeliveryResourceErrorBuffers}))
+	start := time.Now()
+	if _, err := api.SendFrames(&TxRequest{FrameID: 2}); err != nil {
+		t.Fatal("SendFrames error", err)
+	}
+	if d := time.Since(start); d < resourceBackoff/2 {
+		t.Error("Transmit not held back after resource error, sent after", d)
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	port := newRecordPort(t)
+	api := NewXBeeAPI(port, nil)
+	api.PauseTransmit()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := api.SendAndWait(ctx, &ATCommand{Command: "NI"}); err != context.DeadlineExceeded {
+		t.Error("Expected context.DeadlineExceeded, got", err)
+	}
+	if n := api.QueueDepth(); n != 0 {
+		t.Error("Expected empty queue after cancel, got", n)
+	}
+
+	// Frame IDs assigned to frames that were never written are released.
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if n, err := api.sendFrames(ctx, AutoFrameID(&TxRequest{}), AutoFrameID(&TxRequest{})); n != 0 || err != context.DeadlineExceeded {
+		t.Error("Expected context.DeadlineExceeded, got", n, err)
+	}
+	if n := api.FrameIDsInFlight(); n != 0 {
+		t.Error("Expected no frame IDs in flight after cancel, got", n)
+	}
+}
+
+func TestSchedulerConcurrentWrites(t *testing.T) {
+	port := newRecordPort(t)
+	api := NewXBeeAPI(port, nil, WithMaxInFlightTransmits(0))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 16; j++ {
+				tx := &TxRequest{FrameID: byte(i*16 + j), Payload: make([]byte, 64)}
+				if _, err := api.SendFrames(tx); err != nil {
+					t.Error("SendFrames error", err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := len(port.frameTypes()); n != 256 {
+		t.Error("Expected 256 frames written, got", n)
+	}
+}
@@ -0,0 +1,376 @@
+package simulator
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zenbulabs/xbeeapi"
+)
+
+const (
+	digiProfileID      = 0xc105
+	digiManufacturerID = 0x101e
+)
+
+// Link describes the RF path between two radios.
+type Link struct {
+	// Loss is the probability, from 0 to 1, that a packet sent over the
+	// link is lost.
+	Loss    float64
+	Latency time.Duration
+	// RSSI is the received signal strength in -dBm, as reported by DB.
+	RSSI byte
+}
+
+type linkKey struct {
+	a, b xbeeapi.Address64
+}
+
+func newLinkKey(a, b xbeeapi.Address64) linkKey {
+	if a > b {
+		a, b = b, a
+	}
+	return linkKey{a, b}
+}
+
+// Network is a virtual RF medium shared by simulated radios. Packets
+// travel between radios over the configured links, routed through
+// coordinators and routers; end devices never relay.
+type Network struct {
+	mu     *sync.Mutex
+	radios map[xbeeapi.Address64]*Radio
+	order  []*Radio
+	links  map[linkKey]Link
+	rand   *rand.Rand
+}
+
+// NewNetwork returns an empty network. seed makes packet loss
+// reproducible.
+func NewNetwork(seed int64) *Network {
+	return &Network{
+		mu:     &sync.Mutex{},
+		radios: make(map[xbeeapi.Address64]*Radio),
+		links:  make(map[linkKey]Link),
+		rand:   rand.New(rand.NewSource(seed)),
+	}
+}
+
+// AddRadio creates a radio of the given device type attached to the
+// network. It has no links until Connect is called.
+func (n *Network) AddRadio(deviceType xbeeapi.DeviceType, address64 xbeeapi.Address64, address16 xbeeapi.Address16) *Radio {
+	r := NewRadio(address64, address16)
+	r.network = n
+	r.deviceType = deviceType
+
+	n.mu.Lock()
+	n.radios[address64] = r
+	n.order = append(n.order, r)
+	n.mu.Unlock()
+
+	return r
+}
+
+// Connect puts two radios in range of each other.
+func (n *Network) Connect(a, b xbeeapi.Address64, link Link) {
+	n.mu.Lock()
+	n.links[newLinkKey(a, b)] = link
+	n.mu.Unlock()
+}
+
+// Disconnect takes two radios out of range of each other.
+func (n *Network) Disconnect(a, b xbeeapi.Address64) {
+	n.mu.Lock()
+	delete(n.links, newLinkKey(a, b))
+	n.mu.Unlock()
+}
+
+// ConnectAll puts every pair of radios in range of each other.
+func (n *Network) ConnectAll(link Link) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for i, a := range n.order {
+		for _, b := range n.order[i+1:] {
+			n.links[newLinkKey(a.address64(), b.address64())] = link
+		}
+	}
+}
+
+// route is a path found through the network.
+type route struct {
+	hops []Link
+}
+
+func (rt route) latency() (d time.Duration) {
+	for _, l := range rt.hops {
+		d += l.Latency
+	}
+	return
+}
+
+func (rt route) rssi() byte {
+	return rt.hops[len(rt.hops)-1].RSSI
+}
+
+// routeLocked finds the path with fewest hops from src to dst. Only
+// coordinators and routers relay packets.
+func (n *Network) routeLocked(src, dst *Radio) (route, bool) {
+	type step struct {
+		radio *Radio
+		hops  []Link
+	}
+	visited := map[*Radio]bool{src: true}
+	queue := []step{{radio: src}}
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if cur.radio != src && cur.radio.deviceType == xbeeapi.DeviceTypeEndDevice {
+			continue
+		}
+		for _, next := range n.order {
+			if visited[next] {
+				continue
+			}
+			link, ok := n.links[newLinkKey(cur.radio.address64(), next.address64())]
+			if !ok {
+				continue
+			}
+			hops := append(append([]Link(nil), cur.hops...), link)
+			if next == dst {
+				return route{hops: hops}, true
+			}
+			visited[next] = true
+			queue = append(queue, step{radio: next, hops: hops})
+		}
+	}
+
+	return route{}, false
+}
+
+// lostLocked decides whether a packet is dropped along rt.
+func (n *Network) lostLocked(rt route) bool {
+	for _, l := range rt.hops {
+		if l.Loss > 0 && n.rand.Float64() < l.Loss {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Network) lookupLocked(address64 xbeeapi.Address64, address16 xbeeapi.Address16) *Radio {
+	if address64 == xbeeapi.Address64Coordinator {
+		for _, r := range n.order {
+			if r.deviceType == xbeeapi.DeviceTypeCoordinator {
+				return r
+			}
+		}
+		return nil
+	}
+	if address64 != xbeeapi.Address64Unknown {
+		return n.radios[address64]
+	}
+	for _, r := range n.order {
+		if r.address16() == address16 {
+			return r
+		}
+	}
+	return nil
+}
+
+func isBroadcast(tx *xbeeapi.TxRequest) bool {
+	return tx.Address64 == xbeeapi.Address64Broadcast ||
+		(tx.Address64 == xbeeapi.Address64Unknown && tx.Address16.IsBroadcast())
+}
+
+// delivery is a packet scheduled to reach a radio.
+type delivery struct {
+	to    *Radio
+	after time.Duration
+	frame xbeeapi.FrameData
+}
+
+// transmit carries a transmission from src to its destinations and then
+// reports the transmit status to src's host.
+func (n *Network) transmit(src *Radio, tx *xbeeapi.TxRequest, explicit *xbeeapi.TxExplicitAddressing) {
+	srcAddress64, srcAddress16 := src.address64(), src.address16()
+	options := byte(xbeeapi.RxOptionPacketAcked)
+	if isBroadcast(tx) {
+		options = byte(xbeeapi.RxOptionBroadcastPacket)
+	}
+	rxFrame := func() xbeeapi.FrameData {
+		if explicit != nil {
+			return &xbeeapi.RxExplicitIndicator{
+				Address64:   srcAddress64,
+				Address16:   srcAddress16,
+				SrcEndPoint: explicit.SrcEndPoint,
+				DstEndPoint: explicit.DstEndPoint,
+				ClusterID:   explicit.ClusterID,
+				ProfileID:   explicit.ProfileID,
+				Options:     options,
+				Payload:     explicit.Payload,
+			}
+		}
+		return &xbeeapi.RxPacket{Address64: srcAddress64, Address16: srcAddress16, Options: options, Payload: tx.Payload}
+	}
+
+	status := xbeeapi.DeliverySuccess
+	statusAfter := time.Duration(0)
+	address16 := tx.Address16
+	deliveries := []delivery(nil)
+
+	n.mu.Lock()
+	if isBroadcast(tx) {
+		address16 = xbeeapi.Address16Unknown
+		for _, dst := range n.order {
+			if dst == src {
+				continue
+			}
+			if rt, ok := n.routeLocked(src, dst); ok && !n.lostLocked(rt) {
+				deliveries = append(deliveries, delivery{to: dst, after: rt.latency(), frame: rxFrame()})
+			}
+		}
+	} else if dst := n.lookupLocked(tx.Address64, tx.Address16); dst == nil {
+		status = xbeeapi.DeliveryAddressNotFound
+	} else if rt, ok := n.routeLocked(src, dst); !ok {
+		status = xbeeapi.DeliveryRouteNotFound
+	} else {
+		address16 = dst.address16()
+		statusAfter = 2 * rt.latency()
+		if n.lostLocked(rt) {
+			status = xbeeapi.DeliveryNetworkAckFailure
+		} else {
+			deliveries = append(deliveries, delivery{to: dst, after: rt.latency(), frame: rxFrame()})
+			dst.setLastRSSI(rt.rssi())
+		}
+	}
+	n.mu.Unlock()
+
+	for _, d := range deliveries {
+		d := d
+		time.AfterFunc(d.after, func() { d.to.Inject(d.frame) })
+	}
+	if tx.FrameID != 0 {
+		time.AfterFunc(statusAfter, func() {
+			src.Inject(&xbeeapi.TransmitStatus{FrameID: tx.FrameID, Address16: address16, DeliveryStatus: status})
+		})
+	}
+}
+
+// remoteATCommand runs at on the addressed radio and reports its
+// response to src's host.
+func (n *Network) remoteATCommand(src *Radio, at *xbeeapi.RemoteATCommand) {
+	resp := &xbeeapi.RemoteATCommandResponse{
+		FrameID:   at.FrameID,
+		Address64: at.Address64,
+		Address16: xbeeapi.Address16Unknown,
+		Command:   at.Command,
+		Status:    xbeeapi.ATCommandRemoteTransFailed,
+	}
+
+	n.mu.Lock()
+	dst := n.lookupLocked(at.Address64, at.Address16)
+	rt, reachable := route{}, false
+	if dst != nil && dst != src {
+		rt, reachable = n.routeLocked(src, dst)
+		reachable = reachable && !n.lostLocked(rt)
+	}
+	n.mu.Unlock()
+
+	if reachable {
+		time.Sleep(2 * rt.latency())
+		dst.mu.Lock()
+		status, params, apply := dst.atCommandLocked(at.Command, at.Params)
+		apply()
+		dst.mu.Unlock()
+		resp.Address64 = dst.address64()
+		resp.Address16 = dst.address16()
+		resp.Status = status
+		resp.Params = params
+	}
+
+	if at.FrameID != 0 {
+		src.Inject(resp)
+	}
+}
+
+// discover answers an ND command sent to src's radio with every node
+// reachable through the network, then ends the discovery with an empty
+// response.
+func (n *Network) discover(src *Radio, frameID byte) {
+	type answer struct {
+		after time.Duration
+		info  xbeeapi.NodeInfo
+	}
+	answers := []answer(nil)
+
+	src.mu.Lock()
+	options := byte(0)
+	if no := src.registers["NO"]; len(no) == 1 {
+		options = no[0]
+	}
+	src.mu.Unlock()
+
+	n.mu.Lock()
+	for _, dst := range n.order {
+		if dst == src {
+			continue
+		}
+		rt, ok := n.routeLocked(src, dst)
+		if !ok || n.lostLocked(rt) {
+			continue
+		}
+		info := dst.nodeInfo()
+		if options&0x01 != 0 {
+			info.HasDeviceTypeIdentifier = true
+		}
+		if options&0x04 != 0 {
+			info.RSSI, info.HasRSSI = rt.rssi(), true
+		}
+		answers = append(answers, answer{after: 2 * rt.latency(), info: info})
+	}
+	n.mu.Unlock()
+
+	sort.SliceStable(answers, func(i, j int) bool { return answers[i].after < answers[j].after })
+	start := time.Now()
+	for _, a := range answers {
+		time.Sleep(a.after - time.Since(start))
+		if frameID != 0 {
+			src.Inject(&xbeeapi.ATCommandResponse{FrameID: frameID, Command: "ND", Status: xbeeapi.ATCommandOK, Params: a.info.NodeDiscoveryBytes()})
+		}
+	}
+	if frameID != 0 {
+		src.Inject(&xbeeapi.ATCommandResponse{FrameID: frameID, Command: "ND", Status: xbeeapi.ATCommandOK})
+	}
+}
+
+func (r *Radio) nodeInfo() xbeeapi.NodeInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a64, _ := xbeeapi.Address64FromBytes(append(append([]byte(nil), r.registers["SH"]...), r.registers["SL"]...))
+	a16, _ := xbeeapi.Address16FromBytes(r.registers["MY"])
+	parent, _ := xbeeapi.Address16FromBytes(r.registers["MP"])
+	info := xbeeapi.NodeInfo{
+		Address64:       a64,
+		Address16:       a16,
+		ParentAddress16: parent,
+		NodeIdentifier:  string(r.registers["NI"]),
+		DeviceType:      r.deviceType,
+		ProfileID:       digiProfileID,
+		ManufacturerID:  digiManufacturerID,
+	}
+	if dd := r.registers["DD"]; len(dd) == 4 {
+		info.DeviceTypeIdentifier = uint32(dd[0])<<24 | uint32(dd[1])<<16 | uint32(dd[2])<<8 | uint32(dd[3])
+	}
+
+	return info
+}
+
+func (r *Radio) setLastRSSI(rssi byte) {
+	r.mu.Lock()
+	r.registers["DB"] = []byte{rssi}
+	r.mu.Unlock()
+}
@@ -0,0 +1,128 @@
+package simulator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zenbulabs/xbeeapi"
+)
+
+const (
+	coordinator64 xbeeapi.Address64 = 0x0013a20040000001
+	router64      xbeeapi.Address64 = 0x0013a20040000002
+	endDevice64   xbeeapi.Address64 = 0x0013a20040000003
+)
+
+func newTestNetwork() (*Network, *Radio, *Radio, *Radio) {
+	n := NewNetwork(1)
+	c := n.AddRadio(xbeeapi.DeviceTypeCoordinator, coordinator64, xbeeapi.Address16Coordinator)
+	r := n.AddRadio(xbeeapi.DeviceTypeRouter, router64, 0x1111)
+	e := n.AddRadio(xbeeapi.DeviceTypeEndDevice, endDevice64, 0x2222)
+	n.Connect(coordinator64, router64, Link{Latency: time.Millisecond, RSSI: 0x30})
+	n.Connect(router64, endDevice64, Link{Latency: time.Millisecond, RSSI: 0x40})
+
+	return n, c, r, e
+}
+
+func transmit(t *testing.T, api *xbeeapi.XBeeAPI, tx *xbeeapi.TxRequest) *xbeeapi.TransmitStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	f, err := api.SendAndWait(ctx, tx)
+	if err != nil {
+		t.Fatal("SendAndWait error", err)
+	}
+	ts, err := xbeeapi.ParseTransmitStatus(f.FrameData)
+	if err != nil {
+		t.Fatal("Expected transmit status", err)
+	}
+	return ts
+}
+
+func TestNetworkRouting(t *testing.T) {
+	n, c, _, e := newTestNetwork()
+	capi, _ := startAPI(t, c)
+	defer capi.Close()
+	eapi, erx := startAPI(t, e)
+	defer eapi.Close()
+
+	ts := transmit(t, capi, &xbeeapi.TxRequest{Address64: endDevice64, Address16: xbeeapi.Address16Unknown, Payload: []byte("hop")})
+	if !ts.Delivered() || ts.Address16 != 0x2222 {
+		t.Error("Expected delivery through router", ts)
+	}
+	rx, err := xbeeapi.ParseRxPacket(erx.next(t, xbeeapi.FrameTypeXBRxResponse).FrameData)
+	if err != nil || rx.Address64 != coordinator64 || string(rx.Payload) != "hop" {
+		t.Error("Unexpected RxPacket", rx, err)
+	} else if !rx.IsOptionsFlagSet(xbeeapi.RxOptionPacketAcked) || rx.IsOptionsFlagSet(xbeeapi.RxOptionBroadcastPacket) {
+		t.Errorf("Expected unicast receive options, got %#02x", rx.Options)
+	}
+	if db, _ := e.Register("DB"); len(db) != 1 || db[0] != 0x40 {
+		t.Error("Expected RSSI of last hop, got", db)
+	}
+
+	ts = transmit(t, capi, &xbeeapi.TxRequest{Address64: 0x0013a200400000ff, Address16: xbeeapi.Address16Unknown})
+	if ts.DeliveryStatus != xbeeapi.DeliveryAddressNotFound {
+		t.Error("Expected address not found", ts.DeliveryStatus.Description())
+	}
+
+	n.Disconnect(router64, endDevice64)
+	ts = transmit(t, capi, &xbeeapi.TxRequest{Address64: endDevice64, Address16: xbeeapi.Address16Unknown})
+	if ts.DeliveryStatus != xbeeapi.DeliveryRouteNotFound {
+		t.Error("Expected route not found", ts.DeliveryStatus.Description())
+	}
+
+	n.Connect(router64, endDevice64, Link{Loss: 1})
+	ts = transmit(t, capi, &xbeeapi.TxRequest{Address64: endDevice64, Address16: xbeeapi.Address16Unknown})
+	if ts.DeliveryStatus != xbeeapi.DeliveryNetworkAckFailure {
+		t.Error("Expected network ACK failure", ts.DeliveryStatus.Description())
+	}
+}
+
+func TestNetworkBroadcast(t *testing.T) {
+	_, c, r, e := newTestNetwork()
+	eapi, erx := startAPI(t, e)
+	defer eapi.Close()
+	rapi, rrx := startAPI(t, r)
+	defer rapi.Close()
+	capi, _ := startAPI(t, c)
+	defer capi.Close()
+
+	transmit(t, capi, &xbeeapi.TxRequest{Address64: xbeeapi.Address64Broadcast, Address16: xbeeapi.Address16Unknown, Payload: []byte("all")})
+	for _, rcv := range []*received{rrx, erx} {
+		rx, err := xbeeapi.ParseRxPacket(rcv.next(t, xbeeapi.FrameTypeXBRxResponse).FrameData)
+		if err != nil || string(rx.Payload) != "all" {
+			t.Error("Unexpected broadcast RxPacket", rx, err)
+			continue
+		}
+		if !rx.IsOptionsFlagSet(xbeeapi.RxOptionBroadcastPacket) || rx.IsOptionsFlagSet(xbeeapi.RxOptionPacketAcked) {
+			t.Errorf("Expected broadcast receive options, got %#02x", rx.Options)
+		}
+	}
+}
+
+func TestNetworkDiscovery(t *testing.T) {
+	n, c, r, _ := newTestNetwork()
+	r.SetRegister("NI", []byte("router"))
+	n.AddRadio(xbeeapi.DeviceTypeRouter, 0x0013a20040000004, 0x4444)
+	capi, _ := startAPI(t, c)
+	defer capi.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	nodes, err := capi.DiscoverNodes(ctx)
+	if err != nil || len(nodes) != 2 {
+		t.Fatal("Expected router and end device in range", nodes, err)
+	}
+	if nodes[0].Address64 != router64 || nodes[0].NodeIdentifier != "router" || nodes[0].DeviceType != xbeeapi.DeviceTypeRouter {
+		t.Error("Unexpected router node", nodes[0])
+	}
+	if nodes[1].Address64 != endDevice64 || nodes[1].Address16 != 0x2222 {
+		t.Error("Unexpected end device node", nodes[1])
+	}
+
+	resp, err := capi.SendRemoteATCommand(ctx, &xbeeapi.RemoteATCommand{Address64: endDevice64, Address16: xbeeapi.Address16Unknown, Command: "MY"})
+	if err != nil || string(resp.Params) != string([]byte{0x22, 0x22}) {
+		t.Error("Unexpected remote MY", resp, err)
+	}
+}
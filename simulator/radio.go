@@ -0,0 +1,405 @@
+// Package simulator provides in-memory XBee radios speaking the API frame
+// protocol, so code built on xbeeapi can be exercised without hardware.
+package simulator
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/zenbulabs/xbeeapi"
+)
+
+// ErrClosed is returned by writes to a closed Radio.
+var ErrClosed = errors.New("Simulated radio closed")
+
+// TxHandler decides the outcome of a transmission requested by the host.
+type TxHandler func(tx *xbeeapi.TxRequest) xbeeapi.DeliveryStatus
+
+// Radio is a simulated XBee radio in API mode. The host side talks to it
+// through Read and Write like a serial port: local AT commands are
+// answered from a register map, transmissions are acknowledged with a
+// transmit status, and tests can inject frames, raw bytes and errors.
+type Radio struct {
+	mu        *sync.Mutex
+	cond      *sync.Cond
+	out       bytes.Buffer
+	in        bytes.Buffer
+	enc       *xbeeapi.Encoder
+	dec       *xbeeapi.Decoder
+	registers map[string][]byte
+	readErrs  []error
+	deadline  time.Time
+	writeErr  error
+	closed    bool
+	txHandler TxHandler
+	sent      []*xbeeapi.TxRequest
+	// network and deviceType are set for radios added to a Network.
+	network    *Network
+	deviceType xbeeapi.DeviceType
+}
+
+// NewRadio returns a radio with the given addresses and default register
+// values, in API mode 1.
+func NewRadio(address64 xbeeapi.Address64, address16 xbeeapi.Address16) *Radio {
+	r := &Radio{
+		mu:        &sync.Mutex{},
+		registers: defaultRegisters(address64, address16),
+	}
+	r.cond = sync.NewCond(r.mu)
+	r.enc = xbeeapi.NewEncoder(&r.out)
+	r.dec = xbeeapi.NewDecoder(&r.in)
+
+	return r
+}
+
+func defaultRegisters(address64 xbeeapi.Address64, address16 xbeeapi.Address16) map[string][]byte {
+	a64 := address64.Bytes()
+	return map[string][]byte{
+		"SH": a64[:4],
+		"SL": a64[4:],
+		"MY": address16.Bytes(),
+		"MP": xbeeapi.Address16Unknown.Bytes(),
+		"ID": make([]byte, 8),
+		"OP": make([]byte, 8),
+		"OI": {0x00, 0x00},
+		"SC": {0x7f, 0xff},
+		"CH": {0x0c},
+		"NI": {},
+		"BD": {0x00, 0x00, 0x00, 0x03},
+		"AP": {byte(xbeeapi.APIModeUnescaped)},
+		"PL": {0x04},
+		"SM": {0x00},
+		"SP": {0x00, 0x20},
+		"ST": {0x13, 0x88},
+		"NT": {0x3c},
+		"NO": {0x00},
+		"NJ": {0xff},
+		"IR": {0x00, 0x00},
+		"AI": {0x00},
+		"DB": {0x00},
+		"DD": {0x00, 0x0a, 0x00, 0x00},
+		"VR": {0x40, 0x5f},
+		"HV": {0x1e, 0x42},
+		"D0": {0x01},
+		"D1": {0x00},
+		"D2": {0x00},
+		"D3": {0x00},
+		"D4": {0x00},
+		"D5": {0x01},
+		"D6": {0x00},
+		"D7": {0x01},
+		"D8": {0x00},
+		"D9": {0x00},
+	}
+}
+
+// Read returns bytes the radio sends to the host, blocking until some are
+// available. It returns io.EOF once the radio is closed and drained, and
+// os.ErrDeadlineExceeded once the read deadline has passed.
+func (r *Radio) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for r.out.Len() == 0 && len(r.readErrs) == 0 && !r.closed {
+		if !r.deadline.IsZero() && !time.Now().Before(r.deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		r.cond.Wait()
+	}
+	if len(r.readErrs) > 0 {
+		err := r.readErrs[0]
+		r.readErrs = r.readErrs[1:]
+		return 0, err
+	}
+	if r.out.Len() == 0 {
+		return 0, io.EOF
+	}
+
+	return r.out.Read(p)
+}
+
+// Write accepts bytes from the host. Complete frames are handled as the
+// radio would, other bytes are discarded.
+func (r *Radio) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, ErrClosed
+	}
+	if r.writeErr != nil {
+		return 0, r.writeErr
+	}
+
+	r.in.Write(p)
+	for {
+		r.dec.SetAPIMode(r.apiModeLocked())
+		f, err := r.dec.Decode()
+		if err != nil {
+			break
+		}
+		r.handleLocked(f)
+	}
+
+	return len(p), nil
+}
+
+// SetReadDeadline makes pending and future reads that would block fail
+// once t has passed. A zero t disables the deadline.
+func (r *Radio) SetReadDeadline(t time.Time) error {
+	r.mu.Lock()
+	r.deadline = t
+	r.cond.Broadcast()
+	r.mu.Unlock()
+
+	if !t.IsZero() {
+		time.AfterFunc(time.Until(t), func() {
+			r.mu.Lock()
+			r.cond.Broadcast()
+			r.mu.Unlock()
+		})
+	}
+	return nil
+}
+
+// Close makes pending and future reads return io.EOF once buffered data
+// has been read.
+func (r *Radio) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	r.cond.Broadcast()
+	r.mu.Unlock()
+	return nil
+}
+
+// SetRegister sets the value returned when the host queries command.
+func (r *Radio) SetRegister(command string, value []byte) {
+	r.mu.Lock()
+	r.registers[command] = append([]byte(nil), value...)
+	r.mu.Unlock()
+}
+
+// Register returns the current value of command.
+func (r *Radio) Register(command string) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	v, ok := r.registers[command]
+	return append([]byte(nil), v...), ok
+}
+
+// SetTxHandler sets the function deciding the delivery status of
+// transmissions of a radio outside a Network. By default every
+// transmission succeeds. h is called with
+// the radio locked and must not call its methods.
+func (r *Radio) SetTxHandler(h TxHandler) {
+	r.mu.Lock()
+	r.txHandler = h
+	r.mu.Unlock()
+}
+
+// Transmitted returns the transmit requests received from the host.
+func (r *Radio) Transmitted() []*xbeeapi.TxRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*xbeeapi.TxRequest(nil), r.sent...)
+}
+
+// Inject sends a frame to the host.
+func (r *Radio) Inject(fd xbeeapi.FrameData) {
+	r.mu.Lock()
+	r.sendLocked(fd)
+	r.mu.Unlock()
+}
+
+// InjectRxPacket sends a Receive Packet from the given source to the host.
+func (r *Radio) InjectRxPacket(src64 xbeeapi.Address64, src16 xbeeapi.Address16, payload []byte) {
+	r.Inject(&xbeeapi.RxPacket{Address64: src64, Address16: src16, Options: byte(xbeeapi.RxOptionPacketAcked), Payload: payload})
+}
+
+// InjectIOSample sends an IO Data Sample Rx Indicator from the given source
+// to the host.
+func (r *Radio) InjectIOSample(src64 xbeeapi.Address64, src16 xbeeapi.Address16, sample xbeeapi.IOSample) {
+	r.Inject(&xbeeapi.IODataSampleRxIndicator{Address64: src64, Address16: src16, Options: byte(xbeeapi.RxOptionPacketAcked), Sample: sample})
+}
+
+// InjectModemStatus sends a modem status frame to the host.
+func (r *Radio) InjectModemStatus(status byte) {
+	r.Inject(&xbeeapi.ModemStatus{Status: status})
+}
+
+// InjectBytes sends raw bytes to the host as is, e.g. to simulate noise
+// or corrupted frames.
+func (r *Radio) InjectBytes(b []byte) {
+	r.mu.Lock()
+	r.out.Write(b)
+	r.cond.Broadcast()
+	r.mu.Unlock()
+}
+
+// InjectReadError makes the next Read return err.
+func (r *Radio) InjectReadError(err error) {
+	r.mu.Lock()
+	r.readErrs = append(r.readErrs, err)
+	r.cond.Broadcast()
+	r.mu.Unlock()
+}
+
+// SetWriteError makes writes fail with err until it is cleared with nil.
+func (r *Radio) SetWriteError(err error) {
+	r.mu.Lock()
+	r.writeErr = err
+	r.mu.Unlock()
+}
+
+func (r *Radio) apiModeLocked() xbeeapi.APIMode {
+	if ap := r.registers["AP"]; len(ap) == 1 && xbeeapi.APIMode(ap[0]) == xbeeapi.APIModeEscaped {
+		return xbeeapi.APIModeEscaped
+	}
+	return xbeeapi.APIModeUnescaped
+}
+
+func (r *Radio) sendLocked(fd xbeeapi.FrameData) {
+	r.enc.SetAPIMode(r.apiModeLocked())
+	r.enc.Encode(fd)
+	r.cond.Broadcast()
+}
+
+func (r *Radio) handleLocked(f *xbeeapi.Frame) {
+	fd, err := xbeeapi.ParseFrameData(f.FrameData)
+	if err != nil {
+		return
+	}
+
+	switch req := fd.(type) {
+	case *xbeeapi.ATCommand:
+		r.handleATCommandLocked(req)
+	case *xbeeapi.TxRequest:
+		r.handleTxLocked(req, nil)
+	case *xbeeapi.TxExplicitAddressing:
+		r.handleTxLocked(&xbeeapi.TxRequest{
+			FrameID:         req.FrameID,
+			Address64:       req.Address64,
+			Address16:       req.Address16,
+			BroadcastRadius: req.BroadcastRadius,
+			Options:         req.Options,
+			Payload:         req.Payload,
+		}, req)
+	case *xbeeapi.RemoteATCommand:
+		r.handleRemoteATCommandLocked(req)
+	}
+}
+
+func (r *Radio) handleATCommandLocked(at *xbeeapi.ATCommand) {
+	if at.Command == "ND" && r.network != nil {
+		go r.network.discover(r, at.FrameID)
+		return
+	}
+
+	status, params, apply := r.atCommandLocked(at.Command, at.Params)
+	if at.FrameID != 0 {
+		r.sendLocked(&xbeeapi.ATCommandResponse{FrameID: at.FrameID, Command: at.Command, Status: status, Params: params})
+	}
+	// Settings such as AP take effect after the response is sent.
+	apply()
+}
+
+// atCommandLocked runs an AT command against the registers, returning the
+// response status and parameters, and a function applying its effects.
+func (r *Radio) atCommandLocked(command string, params []byte) (byte, []byte, func()) {
+	apply := func() {}
+
+	switch command {
+	case "WR", "AC":
+	case "FR":
+		apply = func() { r.sendLocked(&xbeeapi.ModemStatus{Status: xbeeapi.ModemWatchdogTimerReset}) }
+	case "RE":
+		apply = func() {
+			a64 := append(append([]byte(nil), r.registers["SH"]...), r.registers["SL"]...)
+			address64, _ := xbeeapi.Address64FromBytes(a64)
+			address16, _ := xbeeapi.Address16FromBytes(r.registers["MY"])
+			r.registers = defaultRegisters(address64, address16)
+		}
+	case "ND":
+		// A radio without a network has no neighbours; end the discovery
+		// right away.
+	default:
+		value, ok := r.registers[command]
+		switch {
+		case !ok:
+			return xbeeapi.ATCommandInvalidCommand, nil, apply
+		case len(params) == 0:
+			return xbeeapi.ATCommandOK, append([]byte(nil), value...), apply
+		case isReadOnly(command):
+			return xbeeapi.ATCommandInvalidParam, nil, apply
+		default:
+			params = append([]byte(nil), params...)
+			apply = func() { r.registers[command] = params }
+		}
+	}
+
+	return xbeeapi.ATCommandOK, nil, apply
+}
+
+func (r *Radio) handleTxLocked(tx *xbeeapi.TxRequest, explicit *xbeeapi.TxExplicitAddressing) {
+	r.sent = append(r.sent, tx)
+
+	if r.network != nil {
+		go r.network.transmit(r, tx, explicit)
+		return
+	}
+
+	status := xbeeapi.DeliverySuccess
+	if r.txHandler != nil {
+		status = r.txHandler(tx)
+	}
+	if tx.FrameID != 0 {
+		r.sendLocked(&xbeeapi.TransmitStatus{
+			FrameID:        tx.FrameID,
+			Address16:      tx.Address16,
+			DeliveryStatus: status,
+		})
+	}
+}
+
+func (r *Radio) handleRemoteATCommandLocked(at *xbeeapi.RemoteATCommand) {
+	if r.network != nil {
+		go r.network.remoteATCommand(r, at)
+		return
+	}
+	if at.FrameID != 0 {
+		r.sendLocked(&xbeeapi.RemoteATCommandResponse{
+			FrameID:   at.FrameID,
+			Address64: at.Address64,
+			Address16: xbeeapi.Address16Unknown,
+			Command:   at.Command,
+			Status:    xbeeapi.ATCommandRemoteTransFailed,
+		})
+	}
+}
+
+func (r *Radio) address64() xbeeapi.Address64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, _ := xbeeapi.Address64FromBytes(append(append([]byte(nil), r.registers["SH"]...), r.registers["SL"]...))
+	return a
+}
+
+func (r *Radio) address16() xbeeapi.Address16 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, _ := xbeeapi.Address16FromBytes(r.registers["MY"])
+	return a
+}
+
+func isReadOnly(command string) bool {
+	spec, err := xbeeapi.LookupATCommand(command)
+	return err == nil && spec.ReadOnly
+}
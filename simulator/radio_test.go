@@ -0,0 +1,148 @@
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zenbulabs/xbeeapi"
+)
+
+type received struct {
+	frames chan *xbeeapi.Frame
+}
+
+func (r *received) readCb(f *xbeeapi.Frame, s xbeeapi.XBeeReadStatus) {
+	if f != nil {
+		r.frames <- f
+	}
+}
+
+func (r *received) next(t *testing.T, frameType byte) *xbeeapi.Frame {
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case f := <-r.frames:
+			if f.FrameData.FrameType() == frameType {
+				return f
+			}
+		case <-timeout:
+			t.Fatal("Timed out waiting for frame type", frameType)
+		}
+	}
+}
+
+func startAPI(t *testing.T, radio *Radio, opts ...xbeeapi.Option) (*xbeeapi.XBeeAPI, *received) {
+	rcv := &received{frames: make(chan *xbeeapi.Frame, 16)}
+	api := xbeeapi.NewXBeeAPI(radio, rcv.readCb, opts...)
+	if err := api.Start(context.Background()); err != nil {
+		t.Fatal("Could not start", err)
+	}
+	return api, rcv
+}
+
+func TestRadioATCommands(t *testing.T) {
+	radio := NewRadio(0x0013a20040522baa, 0x7d84)
+	api, _ := startAPI(t, radio)
+	defer api.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if sl, err := api.GetUint(ctx, "SL"); err != nil || sl != 0x40522baa {
+		t.Error("Unexpected SL", sl, err)
+	}
+	if err := api.SetATParam(ctx, "NI", "gateway"); err != nil {
+		t.Error("Could not set NI", err)
+	}
+	if ni, err := api.GetString(ctx, "NI"); err != nil || ni != "gateway" {
+		t.Error("Unexpected NI", ni, err)
+	}
+	if _, err := api.SendATCommand(ctx, &xbeeapi.ATCommand{Command: "MY", Params: []byte{0x00, 0x01}}); err == nil {
+		t.Error("Expected error setting read-only MY")
+	}
+}
+
+func TestRadioTransmitStatus(t *testing.T) {
+	radio := NewRadio(0x0013a20040522baa, 0x7d84)
+	radio.SetTxHandler(func(tx *xbeeapi.TxRequest) xbeeapi.DeliveryStatus {
+		if tx.Address64 == xbeeapi.Address64Broadcast {
+			return xbeeapi.DeliverySuccess
+		}
+		return xbeeapi.DeliveryRouteNotFound
+	})
+	api, _ := startAPI(t, radio)
+	defer api.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	f, err := api.SendAndWait(ctx, &xbeeapi.TxRequest{Address64: 0x0013a20040401122, Address16: xbeeapi.Address16Unknown, Payload: []byte("hi")})
+	if err != nil {
+		t.Fatal("SendAndWait error", err)
+	}
+	ts, err := xbeeapi.ParseTransmitStatus(f.FrameData)
+	if err != nil || ts.DeliveryStatus != xbeeapi.DeliveryRouteNotFound {
+		t.Error("Unexpected transmit status", ts, err)
+	}
+	if sent := radio.Transmitted(); len(sent) != 1 || !bytes.Equal(sent[0].Payload, []byte("hi")) {
+		t.Error("Unexpected transmitted requests", sent)
+	}
+
+	_, err = api.Transmit(ctx, &xbeeapi.TxRequest{Address64: 0x0013a20040401122, Address16: xbeeapi.Address16Unknown})
+	var de *xbeeapi.DeliveryError
+	if !errors.Is(err, xbeeapi.ErrDeliveryFailed) || !errors.As(err, &de) || de.DeliveryStatus != xbeeapi.DeliveryRouteNotFound {
+		t.Error("Expected delivery error, got", err)
+	}
+	if ts, err := api.Transmit(ctx, &xbeeapi.TxRequest{Address64: xbeeapi.Address64Broadcast, Address16: xbeeapi.Address16Unknown}); err != nil || !ts.Delivered() {
+		t.Error("Expected broadcast to be delivered", ts, err)
+	}
+}
+
+func TestRadioInject(t *testing.T) {
+	radio := NewRadio(0x0013a20040522baa, 0x7d84)
+	api, rcv := startAPI(t, radio, xbeeapi.WithAPIMode(xbeeapi.APIModeAuto))
+	defer api.Close()
+
+	// Switch both sides to escaped framing, then send data that needs it.
+	radio.SetRegister("AP", []byte{byte(xbeeapi.APIModeEscaped)})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if ap, err := api.GetAPIModeSetting(ctx); err != nil || ap != xbeeapi.APIModeEscaped || api.APIMode() != xbeeapi.APIModeEscaped {
+		t.Fatal("Expected API mode to be detected", api.APIMode(), err)
+	}
+
+	payload := []byte{0x7e, 0x7d, 0x11, 0x13}
+	radio.InjectRxPacket(0x0013a20040401122, 0x1234, payload)
+	rx, err := xbeeapi.ParseRxPacket(rcv.next(t, xbeeapi.FrameTypeXBRxResponse).FrameData)
+	if err != nil || rx.Address64 != 0x0013a20040401122 || !bytes.Equal(rx.Payload, payload) {
+		t.Error("Unexpected RxPacket", rx, err)
+	}
+
+	radio.InjectModemStatus(xbeeapi.ModemJoined)
+	ms, err := xbeeapi.ParseModemStatus(rcv.next(t, xbeeapi.FrameTypeModemStatus).FrameData)
+	if err != nil || ms.Status != xbeeapi.ModemJoined {
+		t.Error("Unexpected modem status", ms, err)
+	}
+}
+
+func TestRadioRestart(t *testing.T) {
+	radio := NewRadio(0x0013a20040522baa, 0x7d84)
+	api, _ := startAPI(t, radio)
+
+	if err := api.Close(); err != nil || api.Running() {
+		t.Fatal("Expected clean close", err)
+	}
+	if err := api.Start(context.Background()); err != nil {
+		t.Fatal("Could not restart", err)
+	}
+	defer api.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := api.GetUint(ctx, "MY"); err != nil {
+		t.Error("Expected restarted API to work", err)
+	}
+}
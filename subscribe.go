@@ -0,0 +1,414 @@
+package xbeeapi
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Filter selects the frames a subscriber receives. fd is the parsed frame
+// data, or the *RawFrameData for frame types ParseFrameData does not
+// support.
+type Filter func(f *Frame, fd FrameData) bool
+
+func (f Filter) applySubscribe(c *subscribeConfig) {
+	c.filters = append(c.filters, f)
+}
+
+// SubscribeOption configures a subscription. Every Filter is a
+// SubscribeOption.
+type SubscribeOption interface {
+	applySubscribe(c *subscribeConfig)
+}
+
+type subscribeConfig struct {
+	filters []Filter
+	size    int
+	policy  OverflowPolicy
+}
+
+type queueOption struct {
+	size   int
+	policy OverflowPolicy
+}
+
+func (o queueOption) applySubscribe(c *subscribeConfig) {
+	c.size, c.policy = o.size, o.policy
+}
+
+// SubscribeQueue sets how many frames are buffered for a subscriber and
+// what happens when the buffer is full. The default is set with
+// WithDispatchQueue.
+func SubscribeQueue(size int, policy OverflowPolicy) SubscribeOption {
+	return queueOption{size: size, policy: policy}
+}
+
+// MatchFrameType matches frames of the given frame type.
+func MatchFrameType(frameType byte) Filter {
+	return func(f *Frame, fd FrameData) bool {
+		return f.FrameData.FrameType() == frameType
+	}
+}
+
+// MatchFrameID matches request and response frames carrying frame ID id.
+func MatchFrameID(id byte) Filter {
+	return func(f *Frame, fd FrameData) bool {
+		fid, ok := frameID(f.FrameData)
+		return ok && fid == id
+	}
+}
+
+// MatchSource64 matches frames received from the radio with 64-bit
+// address a.
+func MatchSource64(a Address64) Filter {
+	return func(f *Frame, fd FrameData) bool {
+		src, _, ok := sourceAddress(fd)
+		return ok && src == a
+	}
+}
+
+// MatchSource16 matches frames received from the radio with 16-bit
+// address a.
+func MatchSource16(a Address16) Filter {
+	return func(f *Frame, fd FrameData) bool {
+		_, src, ok := sourceAddress(fd)
+		return ok && src == a
+	}
+}
+
+// MatchEndpoint matches explicit frames addressed to destination
+// endpoint ep.
+func MatchEndpoint(ep byte) Filter {
+	return func(f *Frame, fd FrameData) bool {
+		switch v := fd.(type) {
+		case *RxExplicitIndicator:
+			return v.DstEndPoint == ep
+		case *TxExplicitAddressing:
+			return v.DstEndPoint == ep
+		}
+		return false
+	}
+}
+
+// MatchCluster matches explicit frames for cluster ID id.
+func MatchCluster(id uint16) Filter {
+	return func(f *Frame, fd FrameData) bool {
+		switch v := fd.(type) {
+		case *RxExplicitIndicator:
+			return v.ClusterID == id
+		case *TxExplicitAddressing:
+			return v.ClusterID == id
+		}
+		return false
+	}
+}
+
+// frameID returns the frame ID of frame types that carry one.
+func frameID(rfd *RawFrameData) (byte, bool) {
+	if rfd.Len() < 2 {
+		return 0, false
+	}
+	switch rfd.FrameType() {
+	case FrameTypeTxRequest64,
+		FrameTypeTxRequest16,
+		FrameTypeATCommand,
+		FrameTypeATCommandQueueRegisterValue,
+		FrameTypeTxRequest,
+		FrameTypeExplicitAddressingCommandFrame,
+		FrameTypeRemoteATCommand:
+		return rfd.Data()[0], true
+	}
+	id, ok := responseFrameID(rfd)
+	return id, ok
+}
+
+// sourceAddress returns the addresses of the radio that sent a received
+// frame.
+func sourceAddress(fd FrameData) (Address64, Address16, bool) {
+	switch v := fd.(type) {
+	case *RxPacket:
+		return v.Address64, v.Address16, true
+	case *RxExplicitIndicator:
+		return v.Address64, v.Address16, true
+	case *IODataSampleRxIndicator:
+		return v.Address64, v.Address16, true
+	case *RemoteATCommandResponse:
+		return v.Address64, v.Address16, true
+	case *NodeIdentificationIndicator:
+		return v.SenderAddress64, v.SenderAddress16, true
+	case *RxIOPacket64:
+		return v.Address64, Address16Unknown, true
+	case *RxIOPacket16:
+		return Address64Unknown, v.Address16, true
+	}
+	return 0, 0, false
+}
+
+type subscription struct {
+	filters []Filter
+	deliver func(f *Frame, fd FrameData)
+}
+
+func (s *subscription) matches(f *Frame, fd FrameData) bool {
+	for _, filter := range s.filters {
+		if !filter(f, fd) {
+			return false
+		}
+	}
+	return true
+}
+
+// Subscription is a frame subscription returned by Subscribe and
+// SubscribeChan, or an event subscription returned by SubscribeConnState,
+// SubscribeHealth or SubscribeNetwork.
+type Subscription struct {
+	dropped atomic.Uint64
+	total   *atomic.Uint64
+	queued  func() int
+	cancel  func()
+	once    sync.Once
+}
+
+// Unsubscribe ends the subscription. Frames still queued are discarded; a
+// handler call already in progress may still be running.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(s.cancel)
+}
+
+// Dropped returns the number of frames, or events, discarded because the
+// subscriber's queue was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Queued returns the number of frames waiting to be delivered.
+func (s *Subscription) Queued() int {
+	return s.queued()
+}
+
+func (s *Subscription) drop() {
+	s.dropped.Add(1)
+	s.total.Add(1)
+}
+
+// subscribers is the set of frame subscriptions of an XBeeAPI.
+type subscribers struct {
+	mu   *sync.Mutex
+	next int
+	subs map[int]*subscription
+}
+
+func newSubscribers() *subscribers {
+	return &subscribers{
+		mu:   &sync.Mutex{},
+		subs: make(map[int]*subscription),
+	}
+}
+
+func (s *subscribers) add(sub *subscription) func() {
+	s.mu.Lock()
+	id := s.next
+	s.next++
+	s.subs[id] = sub
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		delete(s.subs, id)
+		s.mu.Unlock()
+	}
+}
+
+func (s *subscribers) snapshot() []*subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.subs) == 0 {
+		return nil
+	}
+	subs := make([]*subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+// dispatch parses f once and queues it for every subscription of subs,
+// taken with snapshot, that matches it.
+func dispatch(subs []*subscription, f *Frame) {
+	if subs == nil {
+		return
+	}
+
+	var fd FrameData
+	fd, err := ParseFrameData(f.FrameData)
+	if err != nil {
+		fd = f.FrameData
+	}
+	for _, sub := range subs {
+		if sub.matches(f, fd) {
+			sub.deliver(f, fd)
+		}
+	}
+}
+
+func (api *XBeeAPI) subscribeConfig(opts []SubscribeOption) subscribeConfig {
+	c := subscribeConfig{size: api.queueSize, policy: api.overflow}
+	for _, opt := range opts {
+		opt.applySubscribe(&c)
+	}
+	return c
+}
+
+// Subscribe calls handler for every received frame whose parsed frame data
+// is a T and that matches all filters, e.g. Subscribe(api, func(rx
+// *RxPacket) {...}). Use FrameData as T to receive every frame. Several
+// subscribers can receive the same frame.
+//
+// Frames are queued for each subscriber and handler runs on a goroutine
+// of its own, in the order frames were received, so a slow handler does
+// not hold up the reader. What happens when the queue is full is set with
+// SubscribeQueue.
+func Subscribe[T FrameData](api *XBeeAPI, handler func(T), opts ...SubscribeOption) *Subscription {
+	c := api.subscribeConfig(opts)
+	sub := &Subscription{total: &api.dropped}
+	q := newDispatchQueue(c.size, c.policy, sub.drop, handler)
+
+	remove := api.subs.add(&subscription{
+		filters: c.filters,
+		deliver: func(f *Frame, fd FrameData) {
+			if v, ok := fd.(T); ok {
+				q.push(v)
+			}
+		},
+	})
+	sub.queued = q.len
+	sub.cancel = func() {
+		remove()
+		q.close(true)
+	}
+
+	return sub
+}
+
+// SubscribeChan is like Subscribe, but delivers frames on a channel whose
+// buffer is the subscriber's queue. Unsubscribing closes the channel.
+func SubscribeChan[T FrameData](api *XBeeAPI, opts ...SubscribeOption) (<-chan T, *Subscription) {
+	c := api.subscribeConfig(opts)
+	if c.size < 1 {
+		c.size = 1
+	}
+	ch := make(chan T, c.size)
+	done := make(chan struct{})
+	mu := &sync.RWMutex{}
+	closed := false
+	sub := &Subscription{total: &api.dropped}
+
+	remove := api.subs.add(&subscription{
+		filters: c.filters,
+		deliver: func(f *Frame, fd FrameData) {
+			v, ok := fd.(T)
+			if !ok {
+				return
+			}
+			mu.RLock()
+			defer mu.RUnlock()
+			if closed {
+				return
+			}
+			sendChan(ch, v, c.policy, done, sub.drop)
+		},
+	})
+	sub.queued = func() int { return len(ch) }
+	sub.cancel = func() {
+		remove()
+		close(done)
+		mu.Lock()
+		closed = true
+		close(ch)
+		mu.Unlock()
+	}
+
+	return ch, sub
+}
+
+// sendChan sends v on ch, applying policy if ch is full.
+func sendChan[T any](ch chan T, v T, policy OverflowPolicy, done <-chan struct{}, drop func()) {
+	switch policy {
+	case OverflowBlock:
+		select {
+		case ch <- v:
+		case <-done:
+		}
+	case OverflowDropNewest:
+		select {
+		case ch <- v:
+		default:
+			drop()
+		}
+	default:
+		for {
+			select {
+			case ch <- v:
+				return
+			default:
+			}
+			select {
+			case <-ch:
+				drop()
+			default:
+			}
+		}
+	}
+}
+
+// eventSubscribers fans events other than frames, such as connection
+// state changes, out to subscriber queues.
+type eventSubscribers[E any] struct {
+	mu     *sync.Mutex
+	next   int
+	queues map[int]*dispatchQueue[E]
+}
+
+func newEventSubscribers[E any]() *eventSubscribers[E] {
+	return &eventSubscribers[E]{
+		mu:     &sync.Mutex{},
+		queues: make(map[int]*dispatchQueue[E]),
+	}
+}
+
+func (s *eventSubscribers[E]) publish(e E) {
+	s.mu.Lock()
+	queues := make([]*dispatchQueue[E], 0, len(s.queues))
+	for _, q := range s.queues {
+		queues = append(queues, q)
+	}
+	s.mu.Unlock()
+
+	for _, q := range queues {
+		q.push(e)
+	}
+}
+
+// subscribeEvents calls handler for every event published to s, on a
+// goroutine of its own. Only the queue options of opts are used.
+func subscribeEvents[E any](api *XBeeAPI, s *eventSubscribers[E], handler func(E), opts []SubscribeOption) *Subscription {
+	c := api.subscribeConfig(opts)
+	sub := &Subscription{total: &api.droppedEvents}
+	q := newDispatchQueue(c.size, c.policy, sub.drop, handler)
+
+	s.mu.Lock()
+	id := s.next
+	s.next++
+	s.queues[id] = q
+	s.mu.Unlock()
+
+	sub.queued = q.len
+	sub.cancel = func() {
+		s.mu.Lock()
+		delete(s.queues, id)
+		s.mu.Unlock()
+		q.close(true)
+	}
+
+	return sub
+}
@@ -0,0 +1,163 @@
+package xbeeapi
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	port := newATResponderPort()
+	api := NewXBeeAPI(port, nil)
+
+	all, allSub := SubscribeChan[FrameData](api)
+	fromA, subA := SubscribeChan[*RxPacket](api, MatchSource64(0x0013a200000000aa))
+	explicit, explicitSub := SubscribeChan[*RxExplicitIndicator](api, SubscribeQueue(16, OverflowBlock), MatchEndpoint(0xe8), MatchCluster(0x0011))
+	defer subA.Unsubscribe()
+	defer explicitSub.Unsubscribe()
+
+	handled := make(chan *RxPacket, 16)
+	handlerSub := Subscribe(api, func(rx *RxPacket) { handled <- rx })
+
+	api.Start(context.Background())
+	defer api.Close()
+
+	port.respond(&RxPacket{Address64: 0x0013a200000000bb, Payload: []byte("b")})
+	port.respond(&RxPacket{Address64: 0x0013a200000000aa, Payload: []byte("a")})
+	port.respond(&RxExplicitIndicator{Address64: 0x0013a200000000aa, DstEndPoint: 0xe8, ClusterID: 0x0011, Payload: []byte("x")})
+
+	next := func(ch <-chan *RxPacket) *RxPacket {
+		select {
+		case rx := <-ch:
+			return rx
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for RxPacket")
+		}
+		return nil
+	}
+	if rx := next(fromA); string(rx.Payload) != "a" {
+		t.Error("Expected only frames from source a, got", rx)
+	}
+	if rx := next(handled); string(rx.Payload) != "b" {
+		t.Error("Expected handler to see first RxPacket, got", rx)
+	}
+	if rx := next(handled); string(rx.Payload) != "a" {
+		t.Error("Expected handler to see second RxPacket, got", rx)
+	}
+	select {
+	case rx := <-explicit:
+		if string(rx.Payload) != "x" {
+			t.Error("Unexpected explicit frame", rx)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for RxExplicitIndicator")
+	}
+
+	// The AP probe response and both receive packets reach the catch-all
+	// subscriber.
+	types := map[byte]int{}
+	for len(types) < 3 {
+		select {
+		case fd := <-all:
+			types[fd.FrameType()]++
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for frames, got", types)
+		}
+	}
+
+	allSub.Unsubscribe()
+	for range all {
+		// Drain frames buffered before unsubscribing; the loop ends once
+		// the channel is closed.
+	}
+	handlerSub.Unsubscribe()
+	port.respond(&RxPacket{Address64: 0x0013a200000000aa})
+	next(fromA)
+	select {
+	case rx := <-handled:
+		t.Error("Unexpected frame after unsubscribe", rx)
+	default:
+	}
+}
+
+func TestSubscribeOverflow(t *testing.T) {
+	port := newATResponderPort()
+	api := NewXBeeAPI(port, nil)
+
+	newest, newestSub := SubscribeChan[*RxPacket](api, SubscribeQueue(2, OverflowDropNewest))
+	oldest, oldestSub := SubscribeChan[*RxPacket](api, SubscribeQueue(2, OverflowDropOldest))
+	defer newestSub.Unsubscribe()
+	defer oldestSub.Unsubscribe()
+
+	// A handler stuck on its first frame must not hold up the reader or
+	// other subscribers.
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	stuckSub := Subscribe(api, func(rx *RxPacket) {
+		started <- struct{}{}
+		<-release
+	}, SubscribeQueue(1, OverflowDropNewest))
+	defer close(release)
+	defer stuckSub.Unsubscribe()
+
+	api.Start(context.Background())
+	defer api.Close()
+
+	port.respond(&RxPacket{Payload: []byte("1")})
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for handler")
+	}
+	for _, p := range []string{"2", "3", "4"} {
+		port.respond(&RxPacket{Payload: []byte(p)})
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for api.DroppedFrames() < 6 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	payloads := func(ch <-chan *RxPacket) (s string) {
+		for len(ch) > 0 {
+			s += string((<-ch).Payload)
+		}
+		return
+	}
+	if p := payloads(newest); p != "12" || newestSub.Dropped() != 2 {
+		t.Error("Expected drop newest to keep 12 and drop 2, got", p, newestSub.Dropped())
+	}
+	if p := payloads(oldest); p != "34" || oldestSub.Dropped() != 2 {
+		t.Error("Expected drop oldest to keep 34 and drop 2, got", p, oldestSub.Dropped())
+	}
+	if stuckSub.Queued() != 1 || stuckSub.Dropped() != 2 {
+		t.Error("Expected stuck handler to queue 1 and drop 2 frames, got", stuckSub.Queued(), stuckSub.Dropped())
+	}
+	if n := api.DroppedFrames(); n != 6 {
+		t.Error("Expected 6 dropped frames in total, got", n)
+	}
+}
+
+func TestDroppedEvents(t *testing.T) {
+	api := NewXBeeAPI(newATResponderPort(), nil)
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	sub := api.SubscribeConnState(func(ConnEvent) {
+		started <- struct{}{}
+		<-release
+	}, SubscribeQueue(1, OverflowDropNewest))
+	defer sub.Unsubscribe()
+	defer close(release)
+
+	api.publish(ConnEvent{State: ConnConnected})
+	<-started
+	for i := 0; i < 3; i++ {
+		api.publish(ConnEvent{State: ConnDisconnected})
+	}
+	if n := api.DroppedEvents(); n != 2 || sub.Dropped() != 2 {
+		t.Error("Expected 2 dropped events, got", n, sub.Dropped())
+	}
+	if n := api.DroppedFrames(); n != 0 {
+		t.Error("Expected dropped events not to count as frames, got", n)
+	}
+}
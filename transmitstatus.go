@@ -0,0 +1,215 @@
+package xbeeapi
+
+import "fmt"
+
+// DeliveryStatus reports whether a transmission reached its destination.
+type DeliveryStatus byte
+
+const (
+	DeliverySuccess                    DeliveryStatus = 0x00
+	DeliveryMACAckFailure              DeliveryStatus = 0x01
+	DeliveryCCAFailure                 DeliveryStatus = 0x02
+	DeliveryIndirectUnrequested        DeliveryStatus = 0x03
+	DeliveryTransceiverFailure         DeliveryStatus = 0x04
+	DeliveryInvalidEndpoint            DeliveryStatus = 0x15
+	DeliveryNetworkAckFailure          DeliveryStatus = 0x21
+	DeliveryNotJoined                  DeliveryStatus = 0x22
+	DeliverySelfAddressed              DeliveryStatus = 0x23
+	DeliveryAddressNotFound            DeliveryStatus = 0x24
+	DeliveryRouteNotFound              DeliveryStatus = 0x25
+	DeliveryBroadcastRelayNotHeard     DeliveryStatus = 0x26
+	DeliveryInvalidBindingIndex        DeliveryStatus = 0x2b
+	DeliveryResourceErrorBuffers       DeliveryStatus = 0x2c
+	DeliveryBroadcastWithAPS           DeliveryStatus = 0x2d
+	DeliveryUnicastWithAPSNoEncrypt    DeliveryStatus = 0x2e
+	DeliveryInternalResourceError      DeliveryStatus = 0x31
+	DeliveryResourceError              DeliveryStatus = 0x32
+	DeliveryNoSecureSession            DeliveryStatus = 0x34
+	DeliveryEncryptionFailure          DeliveryStatus = 0x35
+	DeliveryPayloadTooLarge            DeliveryStatus = 0x74
+	DeliveryIndirectMessageUnrequested DeliveryStatus = 0x75
+)
+
+// DiscoveryStatus reports the route and address discovery the radio had
+// to perform for a transmission.
+type DiscoveryStatus byte
+
+const (
+	DiscoveryNone            DiscoveryStatus = 0x00
+	DiscoveryAddress         DiscoveryStatus = 0x01
+	DiscoveryRoute           DiscoveryStatus = 0x02
+	DiscoveryAddressAndRoute DiscoveryStatus = 0x03
+	DiscoveryExtendedTimeout DiscoveryStatus = 0x40
+)
+
+// TransmitStatus is the Zigbee Transmit Status (0x8B) sent by the radio
+// when a TxRequest or TxExplicitAddressing with a non-zero frame ID
+// completes.
+//
+// 802.15.4 radios send the shorter TX Status (0x89) instead, which only
+// carries the frame ID and delivery status; it is parsed with Address16
+// set to Address16Unknown and serialized back as a 0x89 frame.
+type TransmitStatus struct {
+	FrameID         byte
+	Address16       Address16
+	RetryCount      byte
+	DeliveryStatus  DeliveryStatus
+	DiscoveryStatus DiscoveryStatus
+
+	legacy bool
+}
+
+func ParseTransmitStatus(rfd *RawFrameData) (*TransmitStatus, error) {
+	if rfd.Len() > 0 && rfd.FrameType() == FrameTypeTxStatus {
+		return parseLegacyTransmitStatus(rfd)
+	}
+	c, err := newFrameCursor(rfd, FrameTypeXBTxStatus, "TransmitStatus")
+	if err != nil {
+		return nil, err
+	}
+
+	ts := &TransmitStatus{
+		FrameID:         c.uint8("FrameID"),
+		Address16:       c.address16("Address16"),
+		RetryCount:      c.uint8("RetryCount"),
+		DeliveryStatus:  DeliveryStatus(c.uint8("DeliveryStatus")),
+		DiscoveryStatus: DiscoveryStatus(c.uint8("DiscoveryStatus")),
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	if !ts.IsValid() {
+		return nil, &FrameParseError{msg: "Invalid frame data for TransmitStatus"}
+	}
+
+	return ts, nil
+}
+
+func parseLegacyTransmitStatus(rfd *RawFrameData) (*TransmitStatus, error) {
+	c, err := newFrameCursor(rfd, FrameTypeTxStatus, "TransmitStatus")
+	if err != nil {
+		return nil, err
+	}
+
+	ts := &TransmitStatus{
+		FrameID:        c.uint8("FrameID"),
+		Address16:      Address16Unknown,
+		DeliveryStatus: DeliveryStatus(c.uint8("DeliveryStatus")),
+		legacy:         true,
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return ts, nil
+}
+
+// Err returns a *DeliveryError if the transmission was not delivered.
+func (ts *TransmitStatus) Err() error {
+	if ts.Delivered() {
+		return nil
+	}
+	return &DeliveryError{
+		FrameID:         ts.FrameID,
+		Address16:       ts.Address16,
+		RetryCount:      ts.RetryCount,
+		DeliveryStatus:  ts.DeliveryStatus,
+		DiscoveryStatus: ts.DiscoveryStatus,
+	}
+}
+
+func (ts *TransmitStatus) RawFrameData() *RawFrameData {
+	if ts.legacy {
+		return NewRawFrameData(FrameTypeTxStatus, ts.FrameID, byte(ts.DeliveryStatus))
+	}
+	b := []byte{FrameTypeXBTxStatus, ts.FrameID}
+	b = concat(b, ts.Address16.Bytes())
+	b = append(b, ts.RetryCount, byte(ts.DeliveryStatus), byte(ts.DiscoveryStatus))
+
+	return NewRawFrameData(b...)
+}
+
+func (ts *TransmitStatus) IsValid() bool {
+	return true
+}
+
+func (ts *TransmitStatus) FrameType() byte {
+	if ts.legacy {
+		return FrameTypeTxStatus
+	}
+	return FrameTypeXBTxStatus
+}
+
+// Delivered reports whether the transmission succeeded.
+func (ts *TransmitStatus) Delivered() bool {
+	return ts.DeliveryStatus == DeliverySuccess
+}
+
+func (s DeliveryStatus) Description() string {
+	switch s {
+	case DeliverySuccess:
+		return "Success"
+	case DeliveryMACAckFailure:
+		return "MAC ACK Failure"
+	case DeliveryCCAFailure:
+		return "CCA Failure"
+	case DeliveryIndirectUnrequested:
+		return "Indirect Message Unrequested"
+	case DeliveryTransceiverFailure:
+		return "Transceiver Unable To Complete Transmission"
+	case DeliveryInvalidEndpoint:
+		return "Invalid Destination Endpoint"
+	case DeliveryNetworkAckFailure:
+		return "Network ACK Failure"
+	case DeliveryNotJoined:
+		return "Not Joined to Network"
+	case DeliverySelfAddressed:
+		return "Self-addressed"
+	case DeliveryAddressNotFound:
+		return "Address Not Found"
+	case DeliveryRouteNotFound:
+		return "Route Not Found"
+	case DeliveryBroadcastRelayNotHeard:
+		return "Broadcast Source Failed to Hear a Neighbor Relay the Message"
+	case DeliveryInvalidBindingIndex:
+		return "Invalid Binding Table Index"
+	case DeliveryResourceErrorBuffers:
+		return "Resource Error: Lack of Free Buffers or Timers"
+	case DeliveryBroadcastWithAPS:
+		return "Attempted Broadcast with APS Transmission"
+	case DeliveryUnicastWithAPSNoEncrypt:
+		return "Attempted Unicast with APS Transmission, but EE=0"
+	case DeliveryInternalResourceError:
+		return "Internal Resource Error"
+	case DeliveryResourceError:
+		return "Resource Error: Lack of Free Buffers, Timers or Memory"
+	case DeliveryNoSecureSession:
+		return "No Secure Session Connection"
+	case DeliveryEncryptionFailure:
+		return "Encryption Failure"
+	case DeliveryPayloadTooLarge:
+		return "Data Payload Too Large"
+	case DeliveryIndirectMessageUnrequested:
+		return "Indirect Message Unrequested"
+	}
+
+	return fmt.Sprintf("Unknown Delivery Status: %x", byte(s))
+}
+
+func (s DiscoveryStatus) Description() string {
+	switch s {
+	case DiscoveryNone:
+		return "No Discovery Overhead"
+	case DiscoveryAddress:
+		return "Address Discovery"
+	case DiscoveryRoute:
+		return "Route Discovery"
+	case DiscoveryAddressAndRoute:
+		return "Address and Route Discovery"
+	case DiscoveryExtendedTimeout:
+		return "Extended Timeout Discovery"
+	}
+
+	return fmt.Sprintf("Unknown Discovery Status: %x", byte(s))
+}
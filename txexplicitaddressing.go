@@ -1,16 +1,13 @@
 package xbeeapi
 
-import (
-	"bytes"
-	"encoding/binary"
-)
+import "encoding/binary"
 
-const MinTxExplicitAddressingSize = 30
+const MinTxExplicitAddressingSize = 20
 
 type TxExplicitAddressing struct {
 	FrameID         byte
-	Address64       string
-	Address16       string
+	Address64       Address64
+	Address16       Address16
 	SrcEndPoint     byte
 	DstEndPoint     byte
 	ClusterID       uint16
@@ -21,25 +18,25 @@ type TxExplicitAddressing struct {
 }
 
 func ParseTxExplicitAddressing(rfd *RawFrameData) (*TxExplicitAddressing, error) {
-	if !rfd.IsValid() || rfd.FrameType() != FrameTypeExplicitAddressingCommandFrame {
-		return nil, &FrameParseError{msg: "Expecting frame type TxExplicitAddressing"}
+	c, err := newFrameCursor(rfd, FrameTypeExplicitAddressingCommandFrame, "TxExplicitAddressing")
+	if err != nil {
+		return nil, err
 	}
-	if rfd.Len() < MinTxExplicitAddressingSize {
-		return nil, &FrameParseError{msg: "Frame data too small for TxExplicitAddressing"}
-	}
-	buf := bytes.NewBuffer(rfd.Data())
 
 	tx := &TxExplicitAddressing{
-		FrameID:         buf.Next(1)[0],
-		Address64:       bytesToHex(buf.Next(16)),
-		Address16:       bytesToHex(buf.Next(4)),
-		SrcEndPoint:     buf.Next(1)[0],
-		DstEndPoint:     buf.Next(1)[0],
-		ClusterID:       binary.BigEndian.Uint16(buf.Next(2)),
-		ProfileID:       binary.BigEndian.Uint16(buf.Next(2)),
-		BroadcastRadius: buf.Next(1)[0],
-		Options:         buf.Next(1)[0],
-		Payload:         copySlice(buf.Bytes()),
+		FrameID:         c.uint8("FrameID"),
+		Address64:       c.address64("Address64"),
+		Address16:       c.address16("Address16"),
+		SrcEndPoint:     c.uint8("SrcEndPoint"),
+		DstEndPoint:     c.uint8("DstEndPoint"),
+		ClusterID:       c.uint16("ClusterID"),
+		ProfileID:       c.uint16("ProfileID"),
+		BroadcastRadius: c.uint8("BroadcastRadius"),
+		Options:         c.uint8("Options"),
+		Payload:         c.rest(),
+	}
+	if c.err != nil {
+		return nil, c.err
 	}
 
 	if !tx.IsValid() {
@@ -51,9 +48,7 @@ func ParseTxExplicitAddressing(rfd *RawFrameData) (*TxExplicitAddressing, error)
 
 func (tx *TxExplicitAddressing) RawFrameData() *RawFrameData {
 	b := []byte{FrameTypeExplicitAddressingCommandFrame, tx.FrameID}
-	address64, _ := hexToBytes(tx.Address64)
-	address16, _ := hexToBytes(tx.Address16)
-	b = concat(b, address64, address16)
+	b = concat(b, tx.Address64.Bytes(), tx.Address16.Bytes())
 	b = append(b, tx.SrcEndPoint, tx.DstEndPoint, 0x00, 0x00, 0x00, 0x00)
 	binary.BigEndian.PutUint16(b[(len(b)-4):], tx.ClusterID)
 	binary.BigEndian.PutUint16(b[(len(b)-2):], tx.ProfileID)
@@ -64,19 +59,17 @@ func (tx *TxExplicitAddressing) RawFrameData() *RawFrameData {
 }
 
 func (tx *TxExplicitAddressing) IsValid() bool {
-	address64, _ := hexToBytes(tx.Address64)
-	address16, _ := hexToBytes(tx.Address16)
-	if len(address64) == 16 && len(address16) == 4 {
-		return true
-	}
-
-	return false
+	return true
 }
 
 func (tx *TxExplicitAddressing) FrameType() byte {
 	return FrameTypeExplicitAddressingCommandFrame
 }
 
+func (tx *TxExplicitAddressing) SetFrameID(id byte) {
+	tx.FrameID = id
+}
+
 func (tx *TxExplicitAddressing) SetOptionsFlags(txOptionFlags ...TxOptionFlag) {
 	tx.Options = setTxOptionsFlags(tx.Options, txOptionFlags...)
 }
@@ -1,36 +1,34 @@
 package xbeeapi
 
-import "bytes"
-
-const MinTxRequestSize = 23
+const MinTxRequestSize = 14
 
 type TxRequest struct {
 	FrameID         byte
-	Address64       string
-	Address16       string
+	Address64       Address64
+	Address16       Address16
 	BroadcastRadius byte
 	Options         byte
 	Payload         []byte
 }
 
 func ParseTxRequest(rfd *RawFrameData) (*TxRequest, error) {
-	if !rfd.IsValid() || rfd.FrameType() != FrameTypeTxRequest {
-		return nil, &FrameParseError{msg: "Expecting frame type TxRequest"}
-	}
-	if rfd.Len() < MinTxRequestSize {
-		return nil, &FrameParseError{msg: "Frame data too small for TxRequest"}
+	c, err := newFrameCursor(rfd, FrameTypeTxRequest, "TxRequest")
+	if err != nil {
+		return nil, err
 	}
-	buf := bytes.NewBuffer(rfd.Data())
 	tx := &TxRequest{
-		FrameID:         buf.Next(1)[0],
-		Address64:       bytesToHex(buf.Next(16)),
-		Address16:       bytesToHex(buf.Next(4)),
-		BroadcastRadius: buf.Next(1)[0],
-		Options:         buf.Next(1)[0],
-		Payload:         copySlice(buf.Bytes()),
+		FrameID:         c.uint8("FrameID"),
+		Address64:       c.address64("Address64"),
+		Address16:       c.address16("Address16"),
+		BroadcastRadius: c.uint8("BroadcastRadius"),
+		Options:         c.uint8("Options"),
+		Payload:         c.rest(),
+	}
+	if c.err != nil {
+		return nil, c.err
 	}
 	if !tx.IsValid() {
-		return nil, &FrameParseError{msg: "Invalid frame data for AT command"}
+		return nil, &FrameParseError{msg: "Invalid frame data for TxRequest"}
 	}
 
 	return tx, nil
@@ -38,9 +36,7 @@ func ParseTxRequest(rfd *RawFrameData) (*TxRequest, error) {
 
 func (tx *TxRequest) RawFrameData() *RawFrameData {
 	b := []byte{FrameTypeTxRequest, tx.FrameID}
-	address64, _ := hexToBytes(tx.Address64)
-	address16, _ := hexToBytes(tx.Address16)
-	b = concat(b, address64, address16)
+	b = concat(b, tx.Address64.Bytes(), tx.Address16.Bytes())
 	b = append(b, tx.BroadcastRadius, tx.Options)
 	b = concat(b, tx.Payload)
 
@@ -48,19 +44,17 @@ func (tx *TxRequest) RawFrameData() *RawFrameData {
 }
 
 func (tx *TxRequest) IsValid() bool {
-	address64, _ := hexToBytes(tx.Address64)
-	address16, _ := hexToBytes(tx.Address16)
-	if len(address64) == 16 && len(address16) == 4 {
-		return true
-	}
-
-	return false
+	return true
 }
 
 func (tx *TxRequest) FrameType() byte {
 	return FrameTypeTxRequest
 }
 
+func (tx *TxRequest) SetFrameID(id byte) {
+	tx.FrameID = id
+}
+
 func (tx *TxRequest) SetOptionsFlags(txOptionFlags ...TxOptionFlag) {
 	tx.Options = setTxOptionsFlags(tx.Options, txOptionFlags...)
 }
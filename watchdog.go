@@ -0,0 +1,240 @@
+package xbeeapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrUnhealthy is the reason given for a reconnect triggered by the
+// watchdog.
+var ErrUnhealthy = errors.New("radio stopped answering liveness probes")
+
+const (
+	// DefaultWatchdogInterval is the time between liveness probes.
+	DefaultWatchdogInterval = 30 * time.Second
+	// DefaultWatchdogTimeout is how long a probe waits for its response.
+	DefaultWatchdogTimeout = 2 * time.Second
+	// DefaultWatchdogFailures is how many probes in a row must fail for
+	// the radio to count as unhealthy.
+	DefaultWatchdogFailures = 3
+	// DefaultWatchdogHistory is how many probe results are kept.
+	DefaultWatchdogHistory = 32
+)
+
+// WatchdogAction is what the watchdog does when the radio turns
+// unhealthy.
+type WatchdogAction byte
+
+const (
+	// WatchdogNotify only publishes the HealthEvent.
+	WatchdogNotify WatchdogAction = iota
+	// WatchdogReset also sends the radio a software reset (FR).
+	WatchdogReset
+	// WatchdogReconnect also reopens the port, if there is a PortFactory.
+	WatchdogReconnect
+)
+
+func (a WatchdogAction) String() string {
+	switch a {
+	case WatchdogNotify:
+		return "notify"
+	case WatchdogReset:
+		return "reset"
+	case WatchdogReconnect:
+		return "reconnect"
+	}
+	return "unknown"
+}
+
+// WatchdogConfig configures the watchdog enabled with WithWatchdog. Zero
+// fields take their defaults.
+type WatchdogConfig struct {
+	Interval time.Duration
+	Timeout  time.Duration
+	// Failures is how many probes in a row must fail before the radio
+	// counts as unhealthy and Action is taken. Action is taken again
+	// after every further Failures failed probes.
+	Failures int
+	// History is how many probe results ProbeResults returns.
+	History int
+	Action  WatchdogAction
+}
+
+// ProbeResult is the outcome of one liveness probe.
+type ProbeResult struct {
+	Time    time.Time
+	Latency time.Duration
+	Err     error
+}
+
+// HealthEvent reports the radio turning unhealthy, or healthy again.
+type HealthEvent struct {
+	Healthy bool
+	// Failures is the number of probes that failed in a row.
+	Failures int
+	// Result is the probe that caused the change.
+	Result ProbeResult
+	// Action is the action taken, for an unhealthy radio.
+	Action WatchdogAction
+}
+
+// WithWatchdog probes the radio with a local AT query while the reader
+// runs. After cfg.Failures failed probes in a row a HealthEvent is
+// published and cfg.Action is taken.
+func WithWatchdog(cfg WatchdogConfig) Option {
+	return func(o *options) {
+		if cfg.Interval <= 0 {
+			cfg.Interval = DefaultWatchdogInterval
+		}
+		if cfg.Timeout <= 0 {
+			cfg.Timeout = DefaultWatchdogTimeout
+		}
+		if cfg.Failures <= 0 {
+			cfg.Failures = DefaultWatchdogFailures
+		}
+		if cfg.History <= 0 {
+			cfg.History = DefaultWatchdogHistory
+		}
+		o.watchdog = &cfg
+	}
+}
+
+// healthTracker keeps the recent probe results and the health derived
+// from them.
+type healthTracker struct {
+	mu       *sync.Mutex
+	results  []ProbeResult
+	next     int
+	failures int
+	healthy  bool
+}
+
+func newHealthTracker(history int) *healthTracker {
+	return &healthTracker{
+		mu:      &sync.Mutex{},
+		results: make([]ProbeResult, 0, history),
+		healthy: true,
+	}
+}
+
+// record adds r and returns the HealthEvent it causes, if any, with
+// limit the number of failures in a row that makes the radio unhealthy.
+func (h *healthTracker) record(r ProbeResult, limit int) (HealthEvent, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.results) < cap(h.results) {
+		h.results = append(h.results, r)
+	} else if cap(h.results) > 0 {
+		h.results[h.next] = r
+		h.next = (h.next + 1) % cap(h.results)
+	}
+
+	if r.Err == nil {
+		h.failures = 0
+		if h.healthy {
+			return HealthEvent{}, false
+		}
+		h.healthy = true
+		return HealthEvent{Healthy: true, Result: r}, true
+	}
+
+	h.failures++
+	if h.failures%limit != 0 {
+		return HealthEvent{}, false
+	}
+	h.healthy = false
+	return HealthEvent{Failures: h.failures, Result: r}, true
+}
+
+func (h *healthTracker) snapshot() []ProbeResult {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	results := make([]ProbeResult, 0, len(h.results))
+	results = append(results, h.results[h.next:]...)
+	return append(results, h.results[:h.next]...)
+}
+
+func (h *healthTracker) isHealthy() (ok bool) {
+	h.mu.Lock()
+	ok = h.healthy
+	h.mu.Unlock()
+	return
+}
+
+// Ping sends the radio a local AT query and returns how long it took to
+// answer.
+func (api *XBeeAPI) Ping(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	_, err := api.SendATCommand(ctx, &ATCommand{Command: "AP"})
+	return time.Since(start), err
+}
+
+// ProbeResults returns the results of the most recent watchdog probes,
+// oldest first.
+func (api *XBeeAPI) ProbeResults() []ProbeResult {
+	return api.health.snapshot()
+}
+
+// Healthy reports whether the radio answered the last watchdog probes.
+// It is true until the watchdog finds otherwise.
+func (api *XBeeAPI) Healthy() bool {
+	return api.health.isHealthy()
+}
+
+// SubscribeHealth calls handler for every HealthEvent, on a goroutine of
+// its own. Only the queue options of opts are used.
+func (api *XBeeAPI) SubscribeHealth(handler func(HealthEvent), opts ...SubscribeOption) *Subscription {
+	return subscribeEvents(api, api.healthSubs, handler, opts)
+}
+
+// watch probes the radio every interval until ctx is done. No probes are
+// made while the port is being reopened.
+func (api *XBeeAPI) watch(ctx context.Context) {
+	cfg := api.watchdog
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		if api.ConnState() != ConnConnected {
+			continue
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+		latency, err := api.Ping(probeCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		r := ProbeResult{Time: time.Now().Add(-latency), Latency: latency, Err: err}
+		e, ok := api.health.record(r, cfg.Failures)
+		if !ok {
+			continue
+		}
+		if !e.Healthy {
+			e.Action = cfg.Action
+			api.recoverRadio(ctx, cfg)
+		}
+		api.healthSubs.publish(e)
+	}
+}
+
+func (api *XBeeAPI) recoverRadio(ctx context.Context, cfg *WatchdogConfig) {
+	switch cfg.Action {
+	case WatchdogReset:
+		resetCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+		api.SendATCommand(resetCtx, &ATCommand{Command: "FR"})
+		cancel()
+	case WatchdogReconnect:
+		api.connectionLost(ErrUnhealthy)
+	}
+}
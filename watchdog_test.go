@@ -0,0 +1,75 @@
+package xbeeapi
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	port := newFlakyPort()
+	port.commands = make(chan string, 4096)
+	api := NewXBeeAPI(port, nil, WithWatchdog(WatchdogConfig{
+		Interval: 5 * time.Millisecond,
+		Timeout:  20 * time.Millisecond,
+		Failures: 2,
+		History:  4,
+		Action:   WatchdogReset,
+	}))
+	events := make(chan HealthEvent, 16)
+	sub := api.SubscribeHealth(func(e HealthEvent) { events <- e })
+	defer sub.Unsubscribe()
+
+	if err := api.Start(context.Background()); err != nil {
+		t.Fatal("Could not start", err)
+	}
+	defer api.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(api.ProbeResults()) < 4 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected 4 probe results, got", len(api.ProbeResults()))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, r := range api.ProbeResults() {
+		if r.Err != nil || r.Latency <= 0 {
+			t.Error("Unexpected probe result", r)
+		}
+	}
+
+	port.wedged.Store(true)
+	select {
+	case e := <-events:
+		if e.Healthy || e.Failures != 2 || e.Action != WatchdogReset || e.Result.Err == nil {
+			t.Error("Unexpected health event", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("No unhealthy event")
+	}
+	if api.Healthy() {
+		t.Error("Expected radio to be unhealthy")
+	}
+	reset := false
+	for !reset {
+		select {
+		case c := <-port.commands:
+			reset = c == "FR"
+		case <-time.After(time.Second):
+			t.Fatal("No software reset sent")
+		}
+	}
+
+	port.wedged.Store(false)
+	select {
+	case e := <-events:
+		if !e.Healthy || e.Result.Err != nil {
+			t.Error("Unexpected health event", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("No healthy event")
+	}
+	if results := api.ProbeResults(); len(results) != 4 || results[3].Err != nil {
+		t.Error("Expected the latest probe to succeed", results)
+	}
+}
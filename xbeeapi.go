@@ -1,10 +1,13 @@
 package xbeeapi
 
 import (
+	"context"
 	"errors"
 	"io"
-	"log"
+	"net"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -24,84 +27,804 @@ type XBeeReadStatus struct {
 	Error      error
 }
 
+// ErrAlreadyStarted is returned by Start while the reader is running.
+var ErrAlreadyStarted = errors.New("XBeeAPI already started")
+
+// readErrorDelay is how long the reader waits before reading again after
+// a read error.
+const readErrorDelay = 200 * time.Millisecond
+
+// idleReadDelay is how long the reader waits before reading again after
+// a read that returned nothing, so a port always at EOF does not spin.
+const idleReadDelay = 10 * time.Millisecond
+
+// ErrPortClosed is returned by Start when Close had to close the port to
+// interrupt the reader, and there is no PortFactory to open a new one.
+var ErrPortClosed = errors.New("XBeeAPI port was closed by Close")
+
 type XBeeAPI struct {
-	fwr     *frameReadWriter
-	readCb  ReadCallback
+	port          io.ReadWriter
+	fwr           *frameReadWriter
+	tx            *txScheduler
+	pending       *pendingRequests
+	subs          *subscribers
+	protocol      Protocol
+	frameIDExpiry time.Duration
+	readCb        ReadCallback
+	readQueue     *dispatchQueue[readEvent]
+	queueSize     int
+	overflow      OverflowPolicy
+	readOverflow  OverflowPolicy
+	dropped       atomic.Uint64
+	droppedEvents atomic.Uint64
+
+	factory        PortFactory
+	reconnectMin   time.Duration
+	reconnectMax   time.Duration
+	reconnectDelay time.Duration
+	initCmds       []*ATCommand
+	lost           error
+	connState      ConnState
+	connSubs       *eventSubscribers[ConnEvent]
+	// conn counts the ports installed, so that failures of a port
+	// replaced since are ignored.
+	conn        int
+	writeErrors int
+	// portClosed is set when interruptRead closed the port.
+	portClosed bool
+
+	watchdog   *WatchdogConfig
+	health     *healthTracker
+	healthSubs *eventSubscribers[HealthEvent]
+
+	network     *networkTracker
+	networkSubs *eventSubscribers[NetworkEvent]
+	aiInterval  time.Duration
+	joins       *joinTracer
+
 	mu      *sync.Mutex
 	running bool
+	closing bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+	err     error
+}
+
+// readDeadliner is implemented by ports whose blocked reads can be
+// interrupted, such as net.Conn and *os.File.
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// Option configures optional XBeeAPI behaviour in NewXBeeAPI.
+type Option func(*options)
+
+type options struct {
+	apiMode       APIMode
+	protocol      Protocol
+	frameIDExpiry time.Duration
+	queueSize     int
+	overflow      OverflowPolicy
+	readOverflow  OverflowPolicy
+	maxInFlight   int
+
+	factory      PortFactory
+	reconnectMin time.Duration
+	reconnectMax time.Duration
+	initCmds     []*ATCommand
+	watchdog     *WatchdogConfig
+	aiInterval   time.Duration
+
+	maxFrameLength   int
+	interByteTimeout time.Duration
+	onDiscard        func(Discard)
+}
+
+// WithAPIMode selects the framing used with the radio. The default is
+// APIModeUnescaped (AP=1).
+func WithAPIMode(mode APIMode) Option {
+	return func(o *options) {
+		o.apiMode = mode
+	}
+}
+
+// WithProtocol sets the firmware family of the radio, which SetATParam
+// and the AT parameter getters validate and decode parameters for. The
+// default is ProtocolZigbee.
+func WithProtocol(p Protocol) Option {
+	return func(o *options) {
+		o.protocol = p
+	}
+}
+
+// WithFrameIDExpiry sets how long a frame ID assigned with AutoFrameID
+// stays reserved if no response for it arrives. The default is
+// DefaultFrameIDExpiry.
+func WithFrameIDExpiry(expiry time.Duration) Option {
+	return func(o *options) {
+		o.frameIDExpiry = expiry
+	}
+}
+
+// WithDispatchQueue sets how many frames are buffered for the ReadCallback
+// and, unless SubscribeQueue says otherwise, for each subscriber, and what
+// happens when a buffer is full. The default is DefaultQueueSize frames,
+// with OverflowBlock for the ReadCallback, so that it sees every frame,
+// and OverflowDropOldest for subscribers.
+func WithDispatchQueue(size int, policy OverflowPolicy) Option {
+	return func(o *options) {
+		o.queueSize = size
+		o.overflow = policy
+		o.readOverflow = policy
+	}
+}
+
+// WithPortFactory lets the XBeeAPI open the port itself: NewXBeeAPI may
+// then be given a nil port, which Start opens with factory. When the port
+// fails, it is closed and reopened with factory, and the radio is
+// initialized again. Requests in flight meanwhile fail with
+// ErrReconnecting. The XBeeAPI closes the port when the reader stops.
+func WithPortFactory(factory PortFactory) Option {
+	return func(o *options) {
+		o.factory = factory
+	}
+}
+
+// WithReconnectBackoff sets the delay before reopening a lost port,
+// doubled after each failed attempt up to max. The defaults are
+// DefaultReconnectMinDelay and DefaultReconnectMaxDelay.
+func WithReconnectBackoff(min, max time.Duration) Option {
+	return func(o *options) {
+		o.reconnectMin = min
+		o.reconnectMax = max
+	}
+}
+
+// WithInitCommands sets AT commands, such as settings to apply, run in
+// order each time the reader starts or the port is reopened.
+func WithInitCommands(cmds ...*ATCommand) Option {
+	return func(o *options) {
+		o.initCmds = cmds
+	}
+}
+
+// WithMaxInFlightTransmits sets how many transmissions may await their
+// TransmitStatus before further transmissions are held back. Zero means
+// no limit. The default is DefaultMaxInFlightTransmits.
+func WithMaxInFlightTransmits(n int) Option {
+	return func(o *options) {
+		o.maxInFlight = n
+	}
+}
+
+// WithMaxFrameLength sets the largest frame data length accepted from the
+// radio. Longer lengths are taken as a false start delimiter. The default
+// is DefaultMaxFrameLength.
+func WithMaxFrameLength(n int) Option {
+	return func(o *options) {
+		o.maxFrameLength = n
+	}
 }
 
-func NewXBeeAPI(port io.ReadWriter, readCb ReadCallback) *XBeeAPI {
-	return &XBeeAPI{
-		fwr:     newFrameReader(port),
-		readCb:  readCb,
+// WithInterByteTimeout sets how long the reader waits for the rest of a
+// partial frame before it gives up on it and looks for the next start
+// delimiter. The check is made when bytes arrive again. Zero waits
+// forever. The default is DefaultInterByteTimeout.
+func WithInterByteTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.interByteTimeout = d
+	}
+}
+
+// WithDiscardHook calls fn for every range of bytes the reader discards
+// while looking for frames. fn runs on the reader goroutine and should
+// return quickly.
+func WithDiscardHook(fn func(Discard)) Option {
+	return func(o *options) {
+		o.onDiscard = fn
+	}
+}
+
+// readEvent is a ReadCallback call waiting in the read queue.
+type readEvent struct {
+	frame  *Frame
+	status XBeeReadStatus
+}
+
+func NewXBeeAPI(port io.ReadWriter, readCb ReadCallback, opts ...Option) *XBeeAPI {
+	o := options{
+		apiMode:       APIModeUnescaped,
+		frameIDExpiry: DefaultFrameIDExpiry,
+		queueSize:     DefaultQueueSize,
+		overflow:      OverflowDropOldest,
+		readOverflow:  OverflowBlock,
+		maxInFlight:   DefaultMaxInFlightTransmits,
+		reconnectMin:  DefaultReconnectMinDelay,
+		reconnectMax:  DefaultReconnectMaxDelay,
+
+		maxFrameLength:   DefaultMaxFrameLength,
+		interByteTimeout: DefaultInterByteTimeout,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	fwr := newFrameReader(port, o.apiMode)
+	fwr.dec.SetMaxFrameLength(o.maxFrameLength)
+	fwr.dec.SetInterByteTimeout(o.interByteTimeout)
+	fwr.dec.SetDiscardHook(o.onDiscard)
+
+	api := &XBeeAPI{
+		port:          port,
+		fwr:           fwr,
+		tx:            newTxScheduler(fwr.enc, o.maxInFlight, o.frameIDExpiry),
+		pending:       newPendingRequests(),
+		subs:          newSubscribers(),
+		protocol:      o.protocol,
+		frameIDExpiry: o.frameIDExpiry,
+		readCb:        readCb,
+		queueSize:     o.queueSize,
+		overflow:      o.overflow,
+		readOverflow:  o.readOverflow,
+
+		factory:        o.factory,
+		reconnectMin:   o.reconnectMin,
+		reconnectMax:   o.reconnectMax,
+		reconnectDelay: o.reconnectMin,
+		initCmds:       o.initCmds,
+		connSubs:       newEventSubscribers[ConnEvent](),
+
+		watchdog:   o.watchdog,
+		health:     newHealthTracker(0),
+		healthSubs: newEventSubscribers[HealthEvent](),
+
+		network:     newNetworkTracker(),
+		networkSubs: newEventSubscribers[NetworkEvent](),
+		aiInterval:  o.aiInterval,
+		joins:       newJoinTracer(),
+
 		mu:      &sync.Mutex{},
 		running: false,
 	}
+	api.tx.onWrite = api.wrote
+	if o.watchdog != nil {
+		api.health = newHealthTracker(o.watchdog.History)
+	}
+
+	return api
 }
 
-func (api *XBeeAPI) Start() error {
-	var err error
+// APIMode returns the framing currently used with the radio. With
+// APIModeAuto this is the mode detected from the radio once known.
+func (api *XBeeAPI) APIMode() APIMode {
+	return api.fwr.apiMode()
+}
 
+// Start starts reading frames in the background until ctx is done or
+// Close is called, and initializes the radio: its API mode is checked and
+// the commands set with WithInitCommands are run, after which a
+// ConnConnected event is published. A stopped XBeeAPI can be started
+// again, unless Close had to close its port to stop the reader and there
+// is no PortFactory to open a new one: Start then returns ErrPortClosed.
+// The ReadCallback is called on a goroutine of its own, through a
+// queue configured with WithDispatchQueue.
+func (api *XBeeAPI) Start(ctx context.Context) error {
 	api.mu.Lock()
 	if api.running {
-		err = errors.New("XBeeAPI already started")
-	} else {
-		api.running = true
+		api.mu.Unlock()
+		return ErrAlreadyStarted
+	}
+	open := api.port == nil
+	if !open && api.portClosed {
+		api.mu.Unlock()
+		return ErrPortClosed
 	}
 	api.mu.Unlock()
 
-	if err != nil {
-		return err
+	// The factory may take a while, so it runs without holding the lock.
+	var port io.ReadWriter
+	if open {
+		if api.factory == nil {
+			return ErrNoPort
+		}
+		var err error
+		if port, err = api.factory(ctx); err != nil {
+			return err
+		}
+	}
+
+	api.mu.Lock()
+	if api.running {
+		api.mu.Unlock()
+		closePort(port)
+		return ErrAlreadyStarted
+	}
+	if port != nil {
+		if api.port != nil {
+			closePort(api.port)
+		}
+		api.port = port
+		api.portClosed = false
+		api.fwr.reset(port)
+	}
+	api.conn++
+	api.writeErrors = 0
+	conn := api.conn
+	api.tx.setDown(nil)
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	api.running = true
+	api.closing = false
+	api.cancel = cancel
+	api.done = done
+	api.err = nil
+	api.readQueue = nil
+	if api.readCb != nil {
+		api.readQueue = newDispatchQueue(api.queueSize, api.readOverflow, api.dropRead, api.handleRead)
+	}
+	api.mu.Unlock()
+
+	go api.run(ctx, cancel, done)
+	go api.initialize(ctx, 0, conn)
+	go api.trackNetwork(ctx, api.aiInterval)
+	if api.watchdog != nil {
+		go api.watch(ctx)
 	}
 
-	api.fwr.init()
+	return nil
+}
 
+func (api *XBeeAPI) run(ctx context.Context, cancel context.CancelFunc, done chan struct{}) {
+	defer close(done)
+	readQueue := api.readQueue
+
+	interrupted := make(chan struct{})
+	stopped := make(chan struct{})
 	go func() {
-		for {
-			if !api.Running() {
-				log.Println("Stopping...")
-				return
+		defer close(interrupted)
+		select {
+		case <-ctx.Done():
+			api.interruptRead()
+		case <-stopped:
+		}
+	}()
+
+	err := api.readLoop(ctx)
+	close(stopped)
+	cancel()
+	<-interrupted
+
+	if dl, ok := api.currentPort().(readDeadliner); ok {
+		dl.SetReadDeadline(time.Time{})
+	}
+	if api.factory != nil {
+		api.closePort()
+		api.mu.Lock()
+		api.port = nil
+		api.portClosed = false
+		api.lost = nil
+		api.mu.Unlock()
+	}
+	api.mu.Lock()
+	if api.closing && err == context.Canceled {
+		err = nil
+	}
+	api.running = false
+	api.err = err
+	api.mu.Unlock()
+	api.publish(ConnEvent{State: ConnDisconnected, Err: err})
+
+	if readQueue != nil {
+		readQueue.pushLast(readEvent{status: XBeeReadStatus{StatusCode: XBeeClose, Error: err}})
+	}
+}
+
+func (api *XBeeAPI) handleRead(e readEvent) {
+	api.readCb(e.frame, e.status)
+}
+
+func (api *XBeeAPI) dropRead() {
+	api.dropped.Add(1)
+}
+
+// DroppedFrames returns the number of frames discarded because the
+// ReadCallback queue or a subscriber queue was full.
+func (api *XBeeAPI) DroppedFrames() uint64 {
+	return api.dropped.Load()
+}
+
+// DroppedEvents returns the number of connection, health and network
+// events discarded because a subscriber queue was full.
+func (api *XBeeAPI) DroppedEvents() uint64 {
+	return api.droppedEvents.Load()
+}
+
+// readLoop reads frames until ctx is done or the port is closed, and
+// returns the reason it stopped. With a PortFactory, a port that is
+// closed or keeps failing is reopened instead. EOF and empty reads are
+// taken as an idle line, as serial ports with a read timeout report it.
+func (api *XBeeAPI) readLoop(ctx context.Context) error {
+	failures := 0
+	for {
+		err := api.readFrames()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if lost := api.takeLost(); lost != nil {
+			if err := api.reconnect(ctx, lost); err != nil {
+				return err
+			}
+			failures = 0
+			continue
+		}
+		if err == nil || isTimeout(err) {
+			failures = 0
+			continue
+		}
+		if isIdle(err) {
+			failures = 0
+			select {
+			case <-time.After(idleReadDelay):
+			case <-ctx.Done():
+				return ctx.Err()
 			}
-			err := api.readFrames()
-			if err != nil {
-				time.Sleep(200 * time.Millisecond)
-				log.Println("Reader error:", err)
+			continue
+		}
+		if isClosed(err) && api.factory == nil {
+			return err
+		}
+		if api.readQueue != nil {
+			api.readQueue.push(readEvent{status: XBeeReadStatus{StatusCode: XBeeReadError, Error: err}})
+		}
+		failures++
+		if api.factory != nil && (isClosed(err) || failures >= reconnectAfterErrors) {
+			if err := api.reconnect(ctx, err); err != nil {
+				return err
 			}
+			failures = 0
+			continue
 		}
-	}()
 
-	return nil
+		select {
+		case <-time.After(readErrorDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// interruptRead unblocks a pending read on the port: through a read
+// deadline if the port supports one, else by closing the port, else by
+// probing the radio so its response completes the read. A port with
+// neither a read deadline nor Close cannot be interrupted otherwise: if
+// the radio does not answer, the read, and Close with it, blocks until
+// the radio sends something.
+func (api *XBeeAPI) interruptRead() {
+	port := api.currentPort()
+	if dl, ok := port.(readDeadliner); ok {
+		if dl.SetReadDeadline(time.Now()) == nil {
+			return
+		}
+	}
+	if c, ok := port.(io.Closer); ok {
+		api.mu.Lock()
+		if api.port == port {
+			api.portClosed = true
+		}
+		api.mu.Unlock()
+		c.Close()
+		return
+	}
+	api.probe()
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// isClosed reports whether err means the port will not deliver any more
+// data.
+func isClosed(err error) bool {
+	return errors.Is(err, os.ErrClosed) || errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrClosedPipe)
+}
+
+// isIdle reports whether err only means that nothing was read, such as
+// the io.EOF a serial port returns when its read timeout expires.
+func isIdle(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.ErrNoProgress)
+}
+
+// Close stops the reader and returns once it has exited. It returns the
+// error that stopped the reader, if any, or nil when Close stopped it.
+// Ports without read deadlines that implement io.Closer are closed to
+// interrupt a blocked read, after which only a PortFactory can restart
+// the XBeeAPI. Ports with neither can only be interrupted by
+// data from the radio, so Close blocks until a wedged radio sends some.
+func (api *XBeeAPI) Close() error {
+	api.mu.Lock()
+	if !api.running {
+		err := api.err
+		api.mu.Unlock()
+		return err
+	}
+	api.closing = true
+	cancel, done := api.cancel, api.done
+	api.mu.Unlock()
+
+	cancel()
+	<-done
+
+	return api.Err()
+}
+
+// Wait blocks until the reader has exited and returns the error that
+// stopped it.
+func (api *XBeeAPI) Wait() error {
+	api.mu.Lock()
+	done := api.done
+	api.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+	return api.Err()
+}
+
+// Err returns the error that stopped the last run of the reader, nil if
+// it is still running or was stopped by Close.
+func (api *XBeeAPI) Err() (err error) {
+	api.mu.Lock()
+	err = api.err
+	api.mu.Unlock()
+	return
 }
 
+// SendRawFrames writes frames to the radio as is, each with the default
+// priority for its frame type: their Length and Checksum fields are sent
+// even if they do not match the frame data. Frames are written whole,
+// never interleaved with frames sent by other goroutines.
 func (api *XBeeAPI) SendRawFrames(frame ...*Frame) (int, error) {
-	n, err := api.fwr.write(frame...)
-	return n, err
+	reqs := make([]*txRequest, len(frame))
+	for i, f := range frame {
+		reqs[i] = newTxRequest(f, defaultPriority(f.FrameData.FrameType()))
+	}
+	return api.tx.send(context.Background(), reqs...)
 }
 
+// SendFrames sends frameData to the radio. Frame data wrapped with
+// AutoFrameID is given a free frame ID first; if none is available
+// ErrNoFrameID is returned and nothing is sent. Frame data wrapped with
+// WithPriority is queued with that priority.
 func (api *XBeeAPI) SendFrames(frameData ...FrameData) (int, error) {
-	frames := []*Frame(nil)
+	return api.sendFrames(context.Background(), frameData...)
+}
+
+func (api *XBeeAPI) sendFrames(ctx context.Context, frameData ...FrameData) (int, error) {
+	reqs := []*txRequest(nil)
+	// reserved holds the frame ID assigned to each of reqs, or 0.
+	reserved := []byte(nil)
+	release := func(ids []byte) {
+		for _, id := range ids {
+			api.pending.ids.release(id)
+		}
+	}
 
 	for _, fd := range frameData {
-		frames = append(frames, NewFrame(fd))
+		priority := defaultPriority(fd.FrameType())
+		if p, ok := fd.(*prioritized); ok {
+			priority = p.priority
+			fd = p.FrameData
+		}
+		id := byte(0)
+		if auto, ok := fd.(*autoFrameID); ok {
+			var err error
+			if id, err = api.pending.reserve(api.frameIDExpiry, nil); err != nil {
+				release(reserved)
+				return 0, err
+			}
+			auto.SetFrameID(id)
+			fd = auto.FrameIDSetter
+		}
+		reserved = append(reserved, id)
+		reqs = append(reqs, newTxRequest(NewFrame(fd), priority))
+	}
+
+	n, err := api.tx.send(ctx, reqs...)
+	if err != nil {
+		// Frames that were never written get no response.
+		release(reserved[n:])
 	}
+	return n, err
+}
 
-	return api.SendRawFrames(frames...)
+// PauseTransmit holds back every frame until ResumeTransmit is called, for
+// example while the radio deasserts CTS. Senders block meanwhile.
+func (api *XBeeAPI) PauseTransmit() {
+	api.tx.setPaused(true)
 }
 
-func (api *XBeeAPI) readFrames() error {
-	frames, err := api.fwr.read()
+// ResumeTransmit lets frames held back by PauseTransmit go.
+func (api *XBeeAPI) ResumeTransmit() {
+	api.tx.setPaused(false)
+}
 
+// TransmitPaused reports whether PauseTransmit is in effect.
+func (api *XBeeAPI) TransmitPaused() bool {
+	return api.tx.isPaused()
+}
+
+// QueueDepth returns the number of frames waiting to be written to the
+// radio.
+func (api *XBeeAPI) QueueDepth() int {
+	n := 0
+	for _, d := range api.tx.depth() {
+		n += d
+	}
+	return n
+}
+
+// QueueDepthByPriority returns the number of frames of priority p waiting
+// to be written to the radio.
+func (api *XBeeAPI) QueueDepthByPriority(p Priority) int {
+	if p >= numPriorities {
+		return 0
+	}
+	return api.tx.depth()[p]
+}
+
+// TransmitsInFlight returns the number of transmissions written to the
+// radio that still await their TransmitStatus.
+func (api *XBeeAPI) TransmitsInFlight() int {
+	return api.tx.inFlightCount()
+}
+
+// FrameIDsInFlight returns the number of frame IDs currently waiting for
+// a response.
+func (api *XBeeAPI) FrameIDsInFlight() int {
+	return api.pending.ids.outstanding()
+}
+
+// probeFrameID reports whether id may be used for an AP query. Until the
+// radio's API mode is known the query is written, and its response read,
+// unescaped, so neither the frame ID nor the checksum of the query or of
+// its response may be a byte an AP=2 radio escapes.
+func probeFrameID(id byte) bool {
+	if needsEscape(id) || needsEscape((&ATCommand{FrameID: id, Command: "AP"}).RawFrameData().Checksum()) {
+		return false
+	}
+	for _, mode := range []APIMode{APIModeUnescaped, APIModeEscaped} {
+		resp := &ATCommandResponse{FrameID: id, Command: "AP", Status: ATCommandOK, Params: []byte{byte(mode)}}
+		if needsEscape(resp.RawFrameData().Checksum()) {
+			return false
+		}
+	}
+	return true
+}
+
+// probe sends an AP query with a reserved frame ID, which also lets
+// APIModeAuto detect the framing in use. It gives up after the frame ID
+// expiry if transmission is paused.
+func (api *XBeeAPI) probe() {
+	if id, err := api.pending.reserve(api.frameIDExpiry, probeFrameID); err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), api.frameIDExpiry)
+		defer cancel()
+		api.tx.send(ctx, newTxRequest(NewFrame(&ATCommand{FrameID: id, Command: "AP"}), PriorityControl))
+	}
+}
+
+// SendAndWait assigns a free frame ID to frameData, sends it and waits for
+// the response frame carrying the same ID (AT command response, transmit
+// status, ...). If all frame IDs are in flight it blocks until one is
+// freed. The frame ID is released when the response arrives or ctx is
+// done. Responses are still passed to the ReadCallback as well.
+func (api *XBeeAPI) SendAndWait(ctx context.Context, frameData FrameIDSetter) (*Frame, error) {
+	return api.sendAndWait(ctx, frameData, nil)
+}
+
+// sendAndWait is SendAndWait with the frame ID picked among those accept
+// allows, if it is not nil.
+func (api *XBeeAPI) sendAndWait(ctx context.Context, frameData FrameIDSetter, accept func(id byte) bool) (*Frame, error) {
+	w, err := api.pending.add(ctx, accept)
 	if err != nil {
-		api.readCb(nil, XBeeReadStatus{StatusCode: XBeeReadError, Error: err})
-		return err
+		return nil, err
+	}
+	defer api.pending.remove(w)
+
+	frameData.SetFrameID(w.id)
+	if _, err := api.sendFrames(ctx, frameData); err != nil {
+		return nil, err
 	}
 
+	select {
+	case f := <-w.ch:
+		return f, nil
+	case <-w.failed:
+		return nil, w.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// SendATCommand runs cmd on the local radio and returns its response. If
+// the radio rejects the command, the response is returned along with an
+// *ATCommandStatusError.
+func (api *XBeeAPI) SendATCommand(ctx context.Context, cmd *ATCommand) (*ATCommandResponse, error) {
+	return api.sendATCommand(ctx, cmd, nil)
+}
+
+func (api *XBeeAPI) sendATCommand(ctx context.Context, cmd *ATCommand, accept func(id byte) bool) (*ATCommandResponse, error) {
+	f, err := api.sendAndWait(ctx, cmd, accept)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ParseATCommandResponse(f.FrameData)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != ATCommandOK {
+		return resp, &ATCommandStatusError{Command: resp.Command, Status: resp.Status}
+	}
+
+	return resp, nil
+}
+
+// Transmit sends a TxRequest or TxExplicitAddressing and waits for its
+// TransmitStatus. If the data was not delivered, the status is returned
+// along with a *DeliveryError.
+func (api *XBeeAPI) Transmit(ctx context.Context, tx FrameIDSetter) (*TransmitStatus, error) {
+	f, err := api.SendAndWait(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := ParseTransmitStatus(f.FrameData)
+	if err != nil {
+		return nil, err
+	}
+
+	return ts, ts.Err()
+}
+
+// SendRemoteATCommand runs cmd on a remote radio and returns its response.
+// If the remote radio rejects the command, or it cannot be reached, the
+// response is returned along with an *ATCommandStatusError.
+func (api *XBeeAPI) SendRemoteATCommand(ctx context.Context, cmd *RemoteATCommand) (*RemoteATCommandResponse, error) {
+	f, err := api.SendAndWait(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ParseRemoteATCommandResponse(f.FrameData)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != ATCommandOK {
+		return resp, &ATCommandStatusError{Command: resp.Command, Status: resp.Status, Remote: true, Address64: cmd.Address64}
+	}
+
+	return resp, nil
+}
+
+func (api *XBeeAPI) readFrames() error {
+	frames, err := api.fwr.read()
+
 	for _, frame := range frames {
-		api.readCb(frame, XBeeReadStatus{StatusCode: XBeeOK, Error: nil})
+		api.tx.observe(frame)
+		api.observeNetwork(frame)
+		api.observeJoin(frame)
+		subs := api.subs.snapshot()
+		if _, response := responseFrameID(frame.FrameData); response || subs != nil || api.readQueue != nil {
+			// The reader reuses frame for its next read, so whoever may
+			// keep it gets a copy.
+			frame = frame.clone()
+		}
+		api.pending.deliver(frame)
+		dispatch(subs, frame)
+		if api.readQueue != nil {
+			api.readQueue.push(readEvent{frame: frame, status: XBeeReadStatus{StatusCode: XBeeOK, Error: nil}})
+		}
 	}
 
-	return nil
+	return err
 }
 
 func (api *XBeeAPI) Running() (r bool) {
@@ -111,9 +834,9 @@ func (api *XBeeAPI) Running() (r bool) {
 	return
 }
 
+// Finish stops the reader.
+//
+// Deprecated: Use Close, which also waits for the reader to exit.
 func (api *XBeeAPI) Finish() {
-	api.mu.Lock()
-	api.running = false
-	api.fwr.init()
-	api.mu.Unlock()
+	api.Close()
 }
@@ -2,10 +2,15 @@ package xbeeapi
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 )
 
 type TestPort struct {
+	mu   sync.Mutex
 	data *bytes.Buffer
 }
 
@@ -24,10 +29,14 @@ func NewTestPort(b []byte) *TestPort {
 }
 
 func (p *TestPort) Read(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	return p.data.Read(data)
 }
 
 func (p *TestPort) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	return p.data.Write(data)
 }
 
@@ -38,12 +47,12 @@ func TestStart(t *testing.T) {
 	td := &TestData{frame: make(chan *Frame, 1)}
 
 	api := NewXBeeAPI(port, td.readCb)
-	err := api.Start()
+	err := api.Start(context.Background())
 	if err != nil {
 		t.Error("Could not start", err)
 		return
 	}
-	err = api.Start()
+	err = api.Start(context.Background())
 	if err == nil {
 		t.Error("Expected error from double start")
 		return
@@ -55,7 +64,7 @@ func TestStart(t *testing.T) {
 		return
 	}
 
-	api.Finish()
+	api.Close()
 }
 
 func TestWrite(t *testing.T) {
@@ -67,7 +76,7 @@ func TestWrite(t *testing.T) {
 	if n == 0 || err != nil {
 		t.Error("SendRawFrame error", n, err)
 	}
-	api.Start()
+	api.Start(context.Background())
 
 	frameRecv := <-td.frame
 	f1, err1 := frameSend.Serialize()
@@ -77,5 +86,489 @@ func TestWrite(t *testing.T) {
 		t.Error("Error in sending frames.", "Sent:", f1, "Received:", f2)
 	}
 
-	api.Finish()
+	api.Close()
+}
+
+type byteAtATimePort struct {
+	data *bytes.Buffer
+}
+
+func (p *byteAtATimePort) Read(data []byte) (int, error) {
+	return p.data.Read(data[:1])
+}
+
+func (p *byteAtATimePort) Write(data []byte) (int, error) {
+	return p.data.Write(data)
+}
+
+func TestReadEscapedSplit(t *testing.T) {
+	payload := []byte{FrameTypeATCommandResponse, 0x7e, 'N', 'I', 0x00, 0x7d, 0x11, 0x13}
+	frameBytes, _ := NewFrame(NewRawFrameData(payload...)).SerializeAPIMode(APIModeEscaped)
+	fr := newFrameReader(&byteAtATimePort{data: bytes.NewBuffer(frameBytes)}, APIModeEscaped)
+
+	var frames []*Frame
+	for len(frames) == 0 {
+		f, err := fr.read()
+		if err != nil {
+			t.Error("Unexpected read error", err)
+			return
+		}
+		frames = append(frames, f...)
+	}
+	if !bytes.Equal(frames[0].FrameData.buf, payload) {
+		t.Error("Expected:", payload, "Got:", frames[0].FrameData.buf)
+	}
+}
+
+func TestDetectAPIMode(t *testing.T) {
+	response := &ATCommandResponse{FrameID: 1, Command: "AP", Status: ATCommandOK, Params: []byte{0x02}}
+	frameBytes, _ := NewFrame(response).Serialize()
+	fr := newFrameReader(NewTestPort(frameBytes), APIModeAuto)
+
+	if _, err := fr.read(); err != nil {
+		t.Error("Unexpected read error", err)
+		return
+	}
+	if fr.apiMode() != APIModeEscaped {
+		t.Error("Expected escaped API mode after AP response, got", fr.apiMode())
+	}
+}
+
+func TestProbeFrameID(t *testing.T) {
+	for id := 1; id < 256; id++ {
+		b, _ := NewFrame(&ATCommand{FrameID: byte(id), Command: "AP"}).Serialize()
+		unescaped := bytes.Equal(escapeFrameBytes(b[1:]), b[1:])
+		for _, mode := range []APIMode{APIModeUnescaped, APIModeEscaped} {
+			resp := &ATCommandResponse{FrameID: byte(id), Command: "AP", Status: ATCommandOK, Params: []byte{byte(mode)}}
+			b, _ := NewFrame(resp).Serialize()
+			unescaped = unescaped && bytes.Equal(escapeFrameBytes(b[1:]), b[1:])
+		}
+		if probeFrameID(byte(id)) != unescaped {
+			t.Errorf("Frame ID %#02x: probe allowed %v, frame unescaped %v", id, probeFrameID(byte(id)), unescaped)
+		}
+	}
+	// The AP response to these has a checksum an AP=2 radio escapes.
+	for _, id := range []byte{0x66, 0x67, 0xd1, 0xd3} {
+		if probeFrameID(id) {
+			t.Errorf("Frame ID %#02x: probe allowed", id)
+		}
+	}
+
+	api := NewXBeeAPI(newATResponderPort(), nil, WithAPIMode(APIModeAuto))
+	api.pending.ids.next = frameEscape
+	responses, sub := SubscribeChan[*ATCommandResponse](api)
+	defer sub.Unsubscribe()
+	api.Start(context.Background())
+	defer api.Close()
+
+	select {
+	case resp := <-responses:
+		if resp.Command != "AP" || !probeFrameID(resp.FrameID) {
+			t.Errorf("AP probe sent with frame ID %#02x", resp.FrameID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Expected AP probe on start")
+	}
+}
+
+// atResponderPort answers every AT command frame written to it with an
+// OK response carrying the request's frame ID as its parameter.
+type atResponderPort struct {
+	mu   sync.Mutex
+	data bytes.Buffer
+	more chan struct{}
+}
+
+func newATResponderPort() *atResponderPort {
+	return &atResponderPort{more: make(chan struct{}, 1)}
+}
+
+func (p *atResponderPort) Read(data []byte) (int, error) {
+	for {
+		p.mu.Lock()
+		if p.data.Len() > 0 {
+			n, err := p.data.Read(data)
+			p.mu.Unlock()
+			return n, err
+		}
+		p.mu.Unlock()
+		<-p.more
+	}
+}
+
+func (p *atResponderPort) Write(data []byte) (int, error) {
+	f, err := Deserialize(data)
+	if err != nil {
+		return len(data), nil
+	}
+
+	var resp FrameData
+	switch f.FrameData.FrameType() {
+	case FrameTypeATCommand:
+		at, _ := ParseATCommand(f.FrameData)
+		switch at.Command {
+		case "AP":
+			resp = &ATCommandResponse{FrameID: at.FrameID, Command: at.Command, Status: ATCommandOK, Params: []byte{byte(APIModeUnescaped)}}
+		case "NT":
+			resp = &ATCommandResponse{FrameID: at.FrameID, Command: at.Command, Status: ATCommandOK, Params: []byte{0x01}}
+		case "ND":
+			for _, ni := range testDiscoveredNodes {
+				p.respond(&ATCommandResponse{FrameID: at.FrameID, Command: at.Command, Status: ATCommandOK, Params: ni.NodeDiscoveryBytes()})
+			}
+			return len(data), nil
+		default:
+			resp = &ATCommandResponse{FrameID: at.FrameID, Command: at.Command, Status: ATCommandOK, Params: []byte{at.FrameID}}
+		}
+	case FrameTypeRemoteATCommand:
+		at, _ := ParseRemoteATCommand(f.FrameData)
+		status := byte(ATCommandOK)
+		if at.Command == "XX" {
+			status = ATCommandInvalidCommand
+		}
+		resp = &RemoteATCommandResponse{FrameID: at.FrameID, Address64: at.Address64, Address16: 0x1234, Command: at.Command, Status: status, Params: []byte{at.FrameID}}
+	default:
+		return len(data), nil
+	}
+
+	p.respond(resp)
+	return len(data), nil
+}
+
+func (p *atResponderPort) respond(resp FrameData) {
+	b, _ := NewFrame(resp).Serialize()
+	p.mu.Lock()
+	p.data.Write(b)
+	p.mu.Unlock()
+	select {
+	case p.more <- struct{}{}:
+	default:
+	}
+}
+
+func TestSendAndWait(t *testing.T) {
+	api := NewXBeeAPI(newATResponderPort(), nil)
+	api.Start(context.Background())
+	defer api.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cmd := &ATCommand{Command: "NI"}
+			f, err := api.SendAndWait(ctx, cmd)
+			if err != nil {
+				t.Error("SendAndWait error", err)
+				return
+			}
+			resp, err := ParseATCommandResponse(f.FrameData)
+			if err != nil || resp.FrameID != cmd.FrameID || !bytes.Equal(resp.Params, []byte{cmd.FrameID}) {
+				t.Error("Mismatched response for frame ID", cmd.FrameID, resp, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSendAndWaitTimeout(t *testing.T) {
+	api := NewXBeeAPI(NewTestPort([]byte{}), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := api.SendAndWait(ctx, &ATCommand{Command: "NI"})
+	if err != context.DeadlineExceeded {
+		t.Error("Expected deadline exceeded, got", err)
+	}
+	if len(api.pending.waiters) != 0 {
+		t.Error("Expected frame ID to be released after timeout")
+	}
+}
+
+func TestAutoFrameIDExhaustion(t *testing.T) {
+	api := NewXBeeAPI(NewTestPort([]byte{}), nil)
+
+	seen := make(map[byte]bool)
+	for i := 0; i < 255; i++ {
+		cmd := &ATCommand{Command: "NI"}
+		if _, err := api.SendFrames(AutoFrameID(cmd)); err != nil {
+			t.Error("Unexpected error allocating frame ID", i, err)
+			return
+		}
+		if cmd.FrameID == 0 || seen[cmd.FrameID] {
+			t.Error("Invalid or duplicate frame ID", cmd.FrameID)
+		}
+		seen[cmd.FrameID] = true
+	}
+
+	if _, err := api.SendFrames(AutoFrameID(&ATCommand{Command: "NI"})); err != ErrNoFrameID {
+		t.Error("Expected ErrNoFrameID, got", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := api.SendAndWait(ctx, &ATCommand{Command: "NI"}); err != context.DeadlineExceeded {
+		t.Error("Expected SendAndWait to block until deadline, got", err)
+	}
+
+	resp, _ := NewFrame(&ATCommandResponse{FrameID: 42, Command: "NI"}).Serialize()
+	f, _ := Deserialize(resp)
+	api.pending.deliver(f)
+	if api.FrameIDsInFlight() != 254 {
+		t.Error("Expected response to release its frame ID, in flight:", api.FrameIDsInFlight())
+	}
+}
+
+func TestFrameIDExpiry(t *testing.T) {
+	a := newFrameIDAllocator()
+	for i := 0; i < 255; i++ {
+		a.tryAcquire(time.Millisecond, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := a.acquire(ctx, 0, nil); err != nil {
+		t.Error("Expected expired frame ID to be reclaimed:", err)
+	}
+}
+
+func TestSendRemoteATCommand(t *testing.T) {
+	api := NewXBeeAPI(newATResponderPort(), nil)
+	api.Start(context.Background())
+	defer api.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	dest := Address64(0x0013a20040401122)
+	resp, err := api.SendRemoteATCommand(ctx, &RemoteATCommand{Address64: dest, Address16: Address16Unknown, Command: "NI"})
+	if err != nil || resp.Address64 != dest || resp.Address16 != 0x1234 || resp.Command != "NI" {
+		t.Error("Unexpected remote AT response", resp, err)
+	}
+
+	_, err = api.SendRemoteATCommand(ctx, &RemoteATCommand{Address64: dest, Address16: Address16Unknown, Command: "XX"})
+	statusErr, ok := err.(*ATCommandStatusError)
+	if !ok || statusErr.Status != ATCommandInvalidCommand || statusErr.Address64 != dest {
+		t.Error("Expected ATCommandStatusError, got", err)
+	}
+	if !errors.Is(err, ErrATCommandStatus) {
+		t.Error("Expected error to match ErrATCommandStatus", err)
+	}
+}
+
+// legacyTxPort answers transmit requests with the 802.15.4 TX Status
+// (0x89).
+type legacyTxPort struct {
+	*atResponderPort
+}
+
+func (p *legacyTxPort) Write(data []byte) (int, error) {
+	if f, err := Deserialize(data); err == nil {
+		if tx, err := ParseTxRequest(f.FrameData); err == nil {
+			p.respond(NewRawFrameData(FrameTypeTxStatus, tx.FrameID, byte(DeliveryMACAckFailure)))
+			return len(data), nil
+		}
+	}
+	return p.atResponderPort.Write(data)
+}
+
+func TestTransmitLegacyStatus(t *testing.T) {
+	api := NewXBeeAPI(&legacyTxPort{newATResponderPort()}, nil)
+	api.Start(context.Background())
+	defer api.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ts, err := api.Transmit(ctx, &TxRequest{Address64: 0x0013a20040a1b2c3, Address16: Address16Unknown})
+	var de *DeliveryError
+	if !errors.As(err, &de) || de.DeliveryStatus != DeliveryMACAckFailure {
+		t.Error("Expected delivery error, got", err)
+	}
+	if ts == nil || ts.DeliveryStatus != DeliveryMACAckFailure {
+		t.Error("Expected the legacy transmit status, got", ts)
+	}
+}
+
+var testDiscoveredNodes = []*NodeInfo{
+	{Address64: 0x0013a20040522baa, Address16: 0x7d84, ParentAddress16: Address16Unknown, NodeIdentifier: "router", DeviceType: DeviceTypeRouter, ProfileID: 0xc105, ManufacturerID: 0x101e},
+	{Address64: 0x0013a20040522bbb, Address16: 0x1234, ParentAddress16: 0x7d84, NodeIdentifier: "sensor", DeviceType: DeviceTypeEndDevice, ProfileID: 0xc105, ManufacturerID: 0x101e, RSSI: 0x28, HasRSSI: true},
+}
+
+func TestDiscoverNodes(t *testing.T) {
+	api := NewXBeeAPI(newATResponderPort(), nil)
+	api.Start(context.Background())
+	defer api.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	nodes, err := api.DiscoverNodes(ctx)
+	if err != nil || len(nodes) != len(testDiscoveredNodes) {
+		t.Error("Unexpected discovery result", nodes, err)
+		return
+	}
+	for i, ni := range nodes {
+		if *ni != *testDiscoveredNodes[i] {
+			t.Error("Expected:", testDiscoveredNodes[i], "Got:", ni)
+		}
+	}
+}
+
+// nd802154Port answers ND with the 802.15.4 layout: MY, SH, SL, DB and NI.
+type nd802154Port struct {
+	*atResponderPort
+}
+
+var testDiscovered802154 = concat(Address16(0x0001).Bytes(), Address64(0x0013a20040522bcc).Bytes(), []byte{0x28}, []byte("end"), []byte{0x00})
+
+func (p *nd802154Port) Write(data []byte) (int, error) {
+	if f, err := Deserialize(data); err == nil {
+		if at, err := ParseATCommand(f.FrameData); err == nil && at.Command == "ND" {
+			p.respond(&ATCommandResponse{FrameID: at.FrameID, Command: at.Command, Status: ATCommandOK, Params: testDiscovered802154})
+			return len(data), nil
+		}
+	}
+	return p.atResponderPort.Write(data)
+}
+
+func TestDiscoverNodes802154(t *testing.T) {
+	expected := NodeInfo{Address64: 0x0013a20040522bcc, Address16: 0x0001, ParentAddress16: Address16Unknown, NodeIdentifier: "end", RSSI: 0x28, HasRSSI: true}
+	if _, err := ParseNodeDiscoveryResponse(testDiscovered802154); err == nil {
+		t.Error("Expected an 802.15.4 ND response not to parse as Zigbee")
+	}
+	if ni, err := ParseProtocolNodeDiscoveryResponse(Protocol802154, testDiscovered802154[:len(testDiscovered802154)-1]); err != nil || *ni != expected {
+		t.Error("Unexpected ND response without null byte", ni, err)
+	}
+
+	api := NewXBeeAPI(&nd802154Port{newATResponderPort()}, nil, WithProtocol(Protocol802154))
+	api.Start(context.Background())
+	defer api.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	nodes, err := api.DiscoverNodes(ctx)
+	if err != nil || len(nodes) != 1 || *nodes[0] != expected {
+		t.Error("Unexpected 802.15.4 discovery result", nodes, err)
+	}
+}
+
+func TestStreamWaiterDoesNotBlock(t *testing.T) {
+	p := newPendingRequests()
+	w, err := p.addStream(context.Background())
+	if err != nil {
+		t.Fatal("addStream", err)
+	}
+	defer p.remove(w)
+
+	const n = 100
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < n; i++ {
+			p.deliver(NewFrame(&ATCommandResponse{FrameID: w.id, Command: "ND", Status: ATCommandOK}))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Delivery blocked on an unread stream waiter")
+	}
+	<-w.ready
+	if got := len(w.take()); got != n {
+		t.Error("Expected", n, "queued frames, got", got)
+	}
+}
+
+func TestReadCallbackBlocksByDefault(t *testing.T) {
+	port := newATResponderPort()
+	release := make(chan struct{})
+	received := make(chan *Frame, 2*DefaultQueueSize)
+	api := NewXBeeAPI(port, func(f *Frame, s XBeeReadStatus) {
+		if s.StatusCode != XBeeOK || f.FrameData.FrameType() != FrameTypeXBRxResponse {
+			return
+		}
+		<-release
+		received <- f
+	})
+	api.Start(context.Background())
+	defer api.Close()
+
+	for i := 0; i < cap(received); i++ {
+		port.respond(&RxPacket{Payload: []byte{byte(i)}})
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < cap(received); i++ {
+		select {
+		case f := <-received:
+			rx, _ := ParseRxPacket(f.FrameData)
+			if rx.Payload[0] != byte(i) {
+				t.Fatal("Expected frame", i, "got", rx.Payload[0])
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for frame", i)
+		}
+	}
+	if n := api.DroppedFrames(); n != 0 {
+		t.Error("Expected no dropped frames, got", n)
+	}
+}
+
+func TestStartContextCancel(t *testing.T) {
+	api := NewXBeeAPI(newATResponderPort(), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := api.Start(ctx); err != nil {
+		t.Fatal("Could not start", err)
+	}
+
+	cancel()
+	if err := api.Wait(); err != context.Canceled {
+		t.Error("Expected context.Canceled, got", err)
+	}
+	if api.Running() {
+		t.Error("Expected reader to have stopped")
+	}
+	if err := api.Start(context.Background()); err != nil {
+		t.Error("Expected restart after cancel to succeed", err)
+	}
+	if err := api.Close(); err != nil {
+		t.Error("Expected clean close, got", err)
+	}
+}
+
+func TestReadEOF(t *testing.T) {
+	port := eofPort{newATResponderPort()}
+	frames := make(chan *Frame, 4)
+	api := NewXBeeAPI(port, func(f *Frame, s XBeeReadStatus) {
+		if s.StatusCode == XBeeReadError {
+			t.Error("Unexpected read error", s.Error)
+		}
+		if f != nil {
+			frames <- f
+		}
+	})
+	if err := api.Start(context.Background()); err != nil {
+		t.Fatal("Could not start", err)
+	}
+	defer api.Close()
+
+	time.Sleep(5 * idleReadDelay)
+	if !api.Running() {
+		t.Fatal("Reader stopped at EOF:", api.Err())
+	}
+	port.respond(&ModemStatus{Status: ModemJoined})
+	for {
+		select {
+		case f := <-frames:
+			if f.FrameData.FrameType() == FrameTypeModemStatus {
+				return
+			}
+		case <-time.After(time.Second):
+			t.Fatal("No frame read after EOF")
+		}
+	}
 }
@@ -0,0 +1,659 @@
+package zdo
+
+import "github.com/zenbulabs/xbeeapi"
+
+// Cluster IDs of the supported ZDP requests.
+const (
+	ClusterNWKAddr           uint16 = 0x0000
+	ClusterIEEEAddr          uint16 = 0x0001
+	ClusterNodeDesc          uint16 = 0x0002
+	ClusterSimpleDesc        uint16 = 0x0004
+	ClusterActiveEP          uint16 = 0x0005
+	ClusterMgmtLqi           uint16 = 0x0031
+	ClusterMgmtRtg           uint16 = 0x0032
+	ClusterMgmtLeave         uint16 = 0x0034
+	ClusterMgmtPermitJoining uint16 = 0x0036
+)
+
+// RequestType selects the answer to an address request.
+type RequestType byte
+
+const (
+	// RequestSingle asks for the device's addresses only.
+	RequestSingle RequestType = 0x00
+	// RequestExtended also asks for the devices associated with it.
+	RequestExtended RequestType = 0x01
+)
+
+// NWKAddrRequest is NWK_addr_req.
+type NWKAddrRequest struct {
+	IEEEAddr    xbeeapi.Address64
+	RequestType RequestType
+	StartIndex  byte
+}
+
+func (*NWKAddrRequest) ClusterID() uint16 { return ClusterNWKAddr }
+
+func (req *NWKAddrRequest) appendTo(b []byte) []byte {
+	b = appendUint64(b, uint64(req.IEEEAddr))
+	return append(b, byte(req.RequestType), req.StartIndex)
+}
+
+// IEEEAddrRequest is IEEE_addr_req.
+type IEEEAddrRequest struct {
+	NWKAddrOfInterest xbeeapi.Address16
+	RequestType       RequestType
+	StartIndex        byte
+}
+
+func (*IEEEAddrRequest) ClusterID() uint16 { return ClusterIEEEAddr }
+
+func (req *IEEEAddrRequest) appendTo(b []byte) []byte {
+	b = appendUint16(b, uint16(req.NWKAddrOfInterest))
+	return append(b, byte(req.RequestType), req.StartIndex)
+}
+
+// AddrResponse is the body of NWK_addr_rsp and IEEE_addr_rsp. Associated
+// is only filled in for extended requests, starting at StartIndex of
+// TotalAssociated devices.
+type AddrResponse struct {
+	Status          Status
+	IEEEAddr        xbeeapi.Address64
+	NWKAddr         xbeeapi.Address16
+	TotalAssociated byte
+	StartIndex      byte
+	Associated      []xbeeapi.Address16
+}
+
+func (resp *AddrResponse) status() Status { return resp.Status }
+
+func (resp *AddrResponse) decode(r *reader) {
+	resp.Status = Status(r.uint8("Status"))
+	if resp.Status != StatusSuccess {
+		return
+	}
+	resp.IEEEAddr = r.address64("IEEEAddr")
+	resp.NWKAddr = r.address16("NWKAddr")
+	if r.remaining() == 0 {
+		return
+	}
+	resp.TotalAssociated = r.uint8("NumAssocDev")
+	if resp.TotalAssociated == 0 {
+		return
+	}
+	resp.StartIndex = r.uint8("StartIndex")
+	for r.remaining() >= 2 {
+		resp.Associated = append(resp.Associated, r.address16("NWKAddrAssocDevList"))
+	}
+}
+
+func (resp *AddrResponse) appendTo(b []byte) []byte {
+	b = append(b, byte(resp.Status))
+	if resp.Status != StatusSuccess {
+		return b
+	}
+	b = appendUint64(b, uint64(resp.IEEEAddr))
+	b = appendUint16(b, uint16(resp.NWKAddr))
+	if resp.TotalAssociated == 0 {
+		return b
+	}
+	b = append(b, resp.TotalAssociated, resp.StartIndex)
+	for _, a := range resp.Associated {
+		b = appendUint16(b, uint16(a))
+	}
+	return b
+}
+
+// NWKAddrResponse is NWK_addr_rsp.
+type NWKAddrResponse struct {
+	AddrResponse
+}
+
+func (*NWKAddrResponse) ClusterID() uint16 { return ClusterNWKAddr | responseCluster }
+
+// IEEEAddrResponse is IEEE_addr_rsp.
+type IEEEAddrResponse struct {
+	AddrResponse
+}
+
+func (*IEEEAddrResponse) ClusterID() uint16 { return ClusterIEEEAddr | responseCluster }
+
+// LogicalType is the Zigbee role of a device.
+type LogicalType byte
+
+const (
+	LogicalTypeCoordinator LogicalType = 0x00
+	LogicalTypeRouter      LogicalType = 0x01
+	LogicalTypeEndDevice   LogicalType = 0x02
+)
+
+func (t LogicalType) String() string {
+	switch t {
+	case LogicalTypeCoordinator:
+		return "coordinator"
+	case LogicalTypeRouter:
+		return "router"
+	case LogicalTypeEndDevice:
+		return "end device"
+	}
+	return "unknown"
+}
+
+// NodeDescriptor describes a device's capabilities. APSFlags and
+// FrequencyBand hold the 3 and 5 bits of their fields.
+type NodeDescriptor struct {
+	LogicalType                LogicalType
+	ComplexDescriptorAvailable bool
+	UserDescriptorAvailable    bool
+	APSFlags                   byte
+	FrequencyBand              byte
+	MACCapability              byte
+	ManufacturerCode           uint16
+	MaxBufferSize              byte
+	MaxIncomingTransferSize    uint16
+	ServerMask                 uint16
+	MaxOutgoingTransferSize    uint16
+	DescriptorCapability       byte
+}
+
+func (d *NodeDescriptor) decode(r *reader) {
+	b := r.uint8("NodeDescriptor")
+	d.LogicalType = LogicalType(b & 0x07)
+	d.ComplexDescriptorAvailable = b&0x08 != 0
+	d.UserDescriptorAvailable = b&0x10 != 0
+	b = r.uint8("NodeDescriptor")
+	d.APSFlags = b & 0x07
+	d.FrequencyBand = b >> 3
+	d.MACCapability = r.uint8("MACCapability")
+	d.ManufacturerCode = r.uint16("ManufacturerCode")
+	d.MaxBufferSize = r.uint8("MaxBufferSize")
+	d.MaxIncomingTransferSize = r.uint16("MaxIncomingTransferSize")
+	d.ServerMask = r.uint16("ServerMask")
+	d.MaxOutgoingTransferSize = r.uint16("MaxOutgoingTransferSize")
+	d.DescriptorCapability = r.uint8("DescriptorCapability")
+}
+
+func (d *NodeDescriptor) appendTo(b []byte) []byte {
+	b0 := byte(d.LogicalType) & 0x07
+	if d.ComplexDescriptorAvailable {
+		b0 |= 0x08
+	}
+	if d.UserDescriptorAvailable {
+		b0 |= 0x10
+	}
+	b = append(b, b0, d.APSFlags&0x07|d.FrequencyBand<<3, d.MACCapability)
+	b = appendUint16(b, d.ManufacturerCode)
+	b = append(b, d.MaxBufferSize)
+	b = appendUint16(b, d.MaxIncomingTransferSize)
+	b = appendUint16(b, d.ServerMask)
+	b = appendUint16(b, d.MaxOutgoingTransferSize)
+	return append(b, d.DescriptorCapability)
+}
+
+// NodeDescRequest is Node_Desc_req.
+type NodeDescRequest struct {
+	NWKAddrOfInterest xbeeapi.Address16
+}
+
+func (*NodeDescRequest) ClusterID() uint16 { return ClusterNodeDesc }
+
+func (req *NodeDescRequest) appendTo(b []byte) []byte {
+	return appendUint16(b, uint16(req.NWKAddrOfInterest))
+}
+
+// decodeAddrOfInterest reads the NWKAddrOfInterest following the status
+// of a descriptor response, and reports whether the rest of the response
+// follows. A device may answer a failed request with the status alone.
+func decodeAddrOfInterest(r *reader, s Status, addr *xbeeapi.Address16) bool {
+	if s != StatusSuccess {
+		if r.remaining() >= 2 {
+			*addr = r.address16("NWKAddrOfInterest")
+		}
+		return false
+	}
+	*addr = r.address16("NWKAddrOfInterest")
+	return true
+}
+
+// NodeDescResponse is Node_Desc_rsp.
+type NodeDescResponse struct {
+	Status            Status
+	NWKAddrOfInterest xbeeapi.Address16
+	Descriptor        NodeDescriptor
+}
+
+func (*NodeDescResponse) ClusterID() uint16 { return ClusterNodeDesc | responseCluster }
+
+func (resp *NodeDescResponse) status() Status { return resp.Status }
+
+func (resp *NodeDescResponse) decode(r *reader) {
+	resp.Status = Status(r.uint8("Status"))
+	if !decodeAddrOfInterest(r, resp.Status, &resp.NWKAddrOfInterest) {
+		return
+	}
+	resp.Descriptor.decode(r)
+}
+
+func (resp *NodeDescResponse) appendTo(b []byte) []byte {
+	b = append(b, byte(resp.Status))
+	b = appendUint16(b, uint16(resp.NWKAddrOfInterest))
+	if resp.Status == StatusSuccess {
+		b = resp.Descriptor.appendTo(b)
+	}
+	return b
+}
+
+// SimpleDescriptor describes an endpoint and the clusters it serves.
+type SimpleDescriptor struct {
+	Endpoint      byte
+	ProfileID     uint16
+	DeviceID      uint16
+	DeviceVersion byte
+	InClusters    []uint16
+	OutClusters   []uint16
+}
+
+func (d *SimpleDescriptor) decode(r *reader) {
+	d.Endpoint = r.uint8("Endpoint")
+	d.ProfileID = r.uint16("ProfileID")
+	d.DeviceID = r.uint16("DeviceID")
+	d.DeviceVersion = r.uint8("DeviceVersion") & 0x0f
+	d.InClusters = r.clusters("InClusterList")
+	d.OutClusters = r.clusters("OutClusterList")
+}
+
+func (d *SimpleDescriptor) appendTo(b []byte) []byte {
+	b = append(b, d.Endpoint)
+	b = appendUint16(b, d.ProfileID)
+	b = appendUint16(b, d.DeviceID)
+	b = append(b, d.DeviceVersion&0x0f)
+	b = appendClusters(b, d.InClusters)
+	return appendClusters(b, d.OutClusters)
+}
+
+// SimpleDescRequest is Simple_Desc_req.
+type SimpleDescRequest struct {
+	NWKAddrOfInterest xbeeapi.Address16
+	Endpoint          byte
+}
+
+func (*SimpleDescRequest) ClusterID() uint16 { return ClusterSimpleDesc }
+
+func (req *SimpleDescRequest) appendTo(b []byte) []byte {
+	b = appendUint16(b, uint16(req.NWKAddrOfInterest))
+	return append(b, req.Endpoint)
+}
+
+// SimpleDescResponse is Simple_Desc_rsp.
+type SimpleDescResponse struct {
+	Status            Status
+	NWKAddrOfInterest xbeeapi.Address16
+	Descriptor        SimpleDescriptor
+}
+
+func (*SimpleDescResponse) ClusterID() uint16 { return ClusterSimpleDesc | responseCluster }
+
+func (resp *SimpleDescResponse) status() Status { return resp.Status }
+
+func (resp *SimpleDescResponse) decode(r *reader) {
+	resp.Status = Status(r.uint8("Status"))
+	if !decodeAddrOfInterest(r, resp.Status, &resp.NWKAddrOfInterest) {
+		return
+	}
+	if n := r.uint8("Length"); n > 0 {
+		resp.Descriptor.decode(r)
+	}
+}
+
+func (resp *SimpleDescResponse) appendTo(b []byte) []byte {
+	b = append(b, byte(resp.Status))
+	b = appendUint16(b, uint16(resp.NWKAddrOfInterest))
+	if resp.Status != StatusSuccess {
+		return append(b, 0)
+	}
+	d := resp.Descriptor.appendTo(nil)
+	b = append(b, byte(len(d)))
+	return append(b, d...)
+}
+
+// ActiveEPRequest is Active_EP_req.
+type ActiveEPRequest struct {
+	NWKAddrOfInterest xbeeapi.Address16
+}
+
+func (*ActiveEPRequest) ClusterID() uint16 { return ClusterActiveEP }
+
+func (req *ActiveEPRequest) appendTo(b []byte) []byte {
+	return appendUint16(b, uint16(req.NWKAddrOfInterest))
+}
+
+// ActiveEPResponse is Active_EP_rsp.
+type ActiveEPResponse struct {
+	Status            Status
+	NWKAddrOfInterest xbeeapi.Address16
+	Endpoints         []byte
+}
+
+func (*ActiveEPResponse) ClusterID() uint16 { return ClusterActiveEP | responseCluster }
+
+func (resp *ActiveEPResponse) status() Status { return resp.Status }
+
+func (resp *ActiveEPResponse) decode(r *reader) {
+	resp.Status = Status(r.uint8("Status"))
+	if !decodeAddrOfInterest(r, resp.Status, &resp.NWKAddrOfInterest) {
+		return
+	}
+	if n := r.uint8("ActiveEPCount"); n > 0 {
+		resp.Endpoints = append([]byte(nil), r.next("ActiveEPList", int(n))...)
+	}
+}
+
+func (resp *ActiveEPResponse) appendTo(b []byte) []byte {
+	b = append(b, byte(resp.Status))
+	b = appendUint16(b, uint16(resp.NWKAddrOfInterest))
+	b = append(b, byte(len(resp.Endpoints)))
+	return append(b, resp.Endpoints...)
+}
+
+// Relationship is how a neighbor relates to the device that reported it.
+type Relationship byte
+
+const (
+	RelationshipParent        Relationship = 0x00
+	RelationshipChild         Relationship = 0x01
+	RelationshipSibling       Relationship = 0x02
+	RelationshipNone          Relationship = 0x03
+	RelationshipPreviousChild Relationship = 0x04
+)
+
+func (r Relationship) String() string {
+	switch r {
+	case RelationshipParent:
+		return "parent"
+	case RelationshipChild:
+		return "child"
+	case RelationshipSibling:
+		return "sibling"
+	case RelationshipNone:
+		return "none"
+	case RelationshipPreviousChild:
+		return "previous child"
+	}
+	return "unknown"
+}
+
+// Neighbor is an entry of a neighbor table. RxOnWhenIdle and
+// PermitJoining are 0 (no), 1 (yes) or 2 (unknown).
+type Neighbor struct {
+	ExtendedPANID uint64
+	Address64     xbeeapi.Address64
+	Address16     xbeeapi.Address16
+	DeviceType    LogicalType
+	RxOnWhenIdle  byte
+	Relationship  Relationship
+	PermitJoining byte
+	Depth         byte
+	LQI           byte
+}
+
+func (n *Neighbor) decode(r *reader) {
+	n.ExtendedPANID = r.uint64("ExtendedPANID")
+	n.Address64 = r.address64("ExtendedAddress")
+	n.Address16 = r.address16("NetworkAddress")
+	b := r.uint8("DeviceType")
+	n.DeviceType = LogicalType(b & 0x03)
+	n.RxOnWhenIdle = b >> 2 & 0x03
+	n.Relationship = Relationship(b >> 4 & 0x07)
+	n.PermitJoining = r.uint8("PermitJoining") & 0x03
+	n.Depth = r.uint8("Depth")
+	n.LQI = r.uint8("LQI")
+}
+
+func (n *Neighbor) appendTo(b []byte) []byte {
+	b = appendUint64(b, n.ExtendedPANID)
+	b = appendUint64(b, uint64(n.Address64))
+	b = appendUint16(b, uint16(n.Address16))
+	flags := byte(n.DeviceType)&0x03 | n.RxOnWhenIdle&0x03<<2 | byte(n.Relationship)&0x07<<4
+	return append(b, flags, n.PermitJoining&0x03, n.Depth, n.LQI)
+}
+
+// MgmtLqiRequest is Mgmt_Lqi_req.
+type MgmtLqiRequest struct {
+	StartIndex byte
+}
+
+func (*MgmtLqiRequest) ClusterID() uint16 { return ClusterMgmtLqi }
+
+func (req *MgmtLqiRequest) appendTo(b []byte) []byte {
+	return append(b, req.StartIndex)
+}
+
+// MgmtLqiResponse is Mgmt_Lqi_rsp. Neighbors holds the entries from
+// StartIndex of the TotalEntries in the table; read the rest with further
+// requests.
+type MgmtLqiResponse struct {
+	Status       Status
+	TotalEntries byte
+	StartIndex   byte
+	Neighbors    []Neighbor
+}
+
+func (*MgmtLqiResponse) ClusterID() uint16 { return ClusterMgmtLqi | responseCluster }
+
+func (resp *MgmtLqiResponse) status() Status { return resp.Status }
+
+func (resp *MgmtLqiResponse) decode(r *reader) {
+	resp.Status = Status(r.uint8("Status"))
+	if resp.Status != StatusSuccess {
+		return
+	}
+	resp.TotalEntries = r.uint8("NeighborTableEntries")
+	resp.StartIndex = r.uint8("StartIndex")
+	n := int(r.uint8("NeighborTableListCount"))
+	for i := 0; i < n && r.err == nil; i++ {
+		var nb Neighbor
+		nb.decode(r)
+		resp.Neighbors = append(resp.Neighbors, nb)
+	}
+}
+
+func (resp *MgmtLqiResponse) appendTo(b []byte) []byte {
+	b = append(b, byte(resp.Status))
+	if resp.Status != StatusSuccess {
+		return b
+	}
+	b = append(b, resp.TotalEntries, resp.StartIndex, byte(len(resp.Neighbors)))
+	for i := range resp.Neighbors {
+		b = resp.Neighbors[i].appendTo(b)
+	}
+	return b
+}
+
+// RouteStatus is the state of a routing table entry.
+type RouteStatus byte
+
+const (
+	RouteActive             RouteStatus = 0x00
+	RouteDiscoveryUnderway  RouteStatus = 0x01
+	RouteDiscoveryFailed    RouteStatus = 0x02
+	RouteInactive           RouteStatus = 0x03
+	RouteValidationUnderway RouteStatus = 0x04
+)
+
+func (s RouteStatus) String() string {
+	switch s {
+	case RouteActive:
+		return "active"
+	case RouteDiscoveryUnderway:
+		return "discovery underway"
+	case RouteDiscoveryFailed:
+		return "discovery failed"
+	case RouteInactive:
+		return "inactive"
+	case RouteValidationUnderway:
+		return "validation underway"
+	}
+	return "unknown"
+}
+
+// Route is an entry of a routing table.
+type Route struct {
+	Destination         xbeeapi.Address16
+	Status              RouteStatus
+	MemoryConstrained   bool
+	ManyToOne           bool
+	RouteRecordRequired bool
+	NextHop             xbeeapi.Address16
+}
+
+func (rt *Route) decode(r *reader) {
+	rt.Destination = r.address16("DestinationAddress")
+	b := r.uint8("RouteStatus")
+	rt.Status = RouteStatus(b & 0x07)
+	rt.MemoryConstrained = b&0x08 != 0
+	rt.ManyToOne = b&0x10 != 0
+	rt.RouteRecordRequired = b&0x20 != 0
+	rt.NextHop = r.address16("NextHopAddress")
+}
+
+func (rt *Route) appendTo(b []byte) []byte {
+	b = appendUint16(b, uint16(rt.Destination))
+	flags := byte(rt.Status) & 0x07
+	if rt.MemoryConstrained {
+		flags |= 0x08
+	}
+	if rt.ManyToOne {
+		flags |= 0x10
+	}
+	if rt.RouteRecordRequired {
+		flags |= 0x20
+	}
+	b = append(b, flags)
+	return appendUint16(b, uint16(rt.NextHop))
+}
+
+// MgmtRtgRequest is Mgmt_Rtg_req.
+type MgmtRtgRequest struct {
+	StartIndex byte
+}
+
+func (*MgmtRtgRequest) ClusterID() uint16 { return ClusterMgmtRtg }
+
+func (req *MgmtRtgRequest) appendTo(b []byte) []byte {
+	return append(b, req.StartIndex)
+}
+
+// MgmtRtgResponse is Mgmt_Rtg_rsp. Routes holds the entries from
+// StartIndex of the TotalEntries in the table.
+type MgmtRtgResponse struct {
+	Status       Status
+	TotalEntries byte
+	StartIndex   byte
+	Routes       []Route
+}
+
+func (*MgmtRtgResponse) ClusterID() uint16 { return ClusterMgmtRtg | responseCluster }
+
+func (resp *MgmtRtgResponse) status() Status { return resp.Status }
+
+func (resp *MgmtRtgResponse) decode(r *reader) {
+	resp.Status = Status(r.uint8("Status"))
+	if resp.Status != StatusSuccess {
+		return
+	}
+	resp.TotalEntries = r.uint8("RoutingTableEntries")
+	resp.StartIndex = r.uint8("StartIndex")
+	n := int(r.uint8("RoutingTableListCount"))
+	for i := 0; i < n && r.err == nil; i++ {
+		var rt Route
+		rt.decode(r)
+		resp.Routes = append(resp.Routes, rt)
+	}
+}
+
+func (resp *MgmtRtgResponse) appendTo(b []byte) []byte {
+	b = append(b, byte(resp.Status))
+	if resp.Status != StatusSuccess {
+		return b
+	}
+	b = append(b, resp.TotalEntries, resp.StartIndex, byte(len(resp.Routes)))
+	for i := range resp.Routes {
+		b = resp.Routes[i].appendTo(b)
+	}
+	return b
+}
+
+// MgmtLeaveRequest is Mgmt_Leave_req. DeviceAddress is the device to
+// remove, or 0 for the device receiving the request.
+type MgmtLeaveRequest struct {
+	DeviceAddress  xbeeapi.Address64
+	RemoveChildren bool
+	Rejoin         bool
+}
+
+func (*MgmtLeaveRequest) ClusterID() uint16 { return ClusterMgmtLeave }
+
+func (req *MgmtLeaveRequest) appendTo(b []byte) []byte {
+	b = appendUint64(b, uint64(req.DeviceAddress))
+	flags := byte(0)
+	if req.RemoveChildren {
+		flags |= 0x40
+	}
+	if req.Rejoin {
+		flags |= 0x80
+	}
+	return append(b, flags)
+}
+
+// MgmtLeaveResponse is Mgmt_Leave_rsp.
+type MgmtLeaveResponse struct {
+	Status Status
+}
+
+func (*MgmtLeaveResponse) ClusterID() uint16 { return ClusterMgmtLeave | responseCluster }
+
+func (resp *MgmtLeaveResponse) status() Status { return resp.Status }
+
+func (resp *MgmtLeaveResponse) decode(r *reader) {
+	resp.Status = Status(r.uint8("Status"))
+}
+
+func (resp *MgmtLeaveResponse) appendTo(b []byte) []byte {
+	return append(b, byte(resp.Status))
+}
+
+// MgmtPermitJoiningRequest is Mgmt_Permit_Joining_req. Duration is in
+// seconds; 0 closes the network and 0xff opens it until further notice.
+type MgmtPermitJoiningRequest struct {
+	Duration       byte
+	TCSignificance bool
+}
+
+func (*MgmtPermitJoiningRequest) ClusterID() uint16 { return ClusterMgmtPermitJoining }
+
+func (req *MgmtPermitJoiningRequest) appendTo(b []byte) []byte {
+	tc := byte(0)
+	if req.TCSignificance {
+		tc = 1
+	}
+	return append(b, req.Duration, tc)
+}
+
+// MgmtPermitJoiningResponse is Mgmt_Permit_Joining_rsp.
+type MgmtPermitJoiningResponse struct {
+	Status Status
+}
+
+func (*MgmtPermitJoiningResponse) ClusterID() uint16 {
+	return ClusterMgmtPermitJoining | responseCluster
+}
+
+func (resp *MgmtPermitJoiningResponse) status() Status { return resp.Status }
+
+func (resp *MgmtPermitJoiningResponse) decode(r *reader) {
+	resp.Status = Status(r.uint8("Status"))
+}
+
+func (resp *MgmtPermitJoiningResponse) appendTo(b []byte) []byte {
+	return append(b, byte(resp.Status))
+}
@@ -0,0 +1,88 @@
+package zdo
+
+import (
+	"encoding/binary"
+
+	"github.com/zenbulabs/xbeeapi"
+)
+
+// reader reads the little-endian fields of a ZDP payload. The first
+// field that does not fit sets err, after which every read returns zero
+// values.
+type reader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (r *reader) remaining() int {
+	return len(r.data) - r.off
+}
+
+func (r *reader) next(field string, n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if r.remaining() < n {
+		r.err = &xbeeapi.TruncatedError{Field: field, Offset: r.off, Size: n, Data: r.data}
+		return nil
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *reader) uint8(field string) byte {
+	if b := r.next(field, 1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) uint16(field string) uint16 {
+	if b := r.next(field, 2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) uint64(field string) uint64 {
+	if b := r.next(field, 8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *reader) address64(field string) xbeeapi.Address64 {
+	return xbeeapi.Address64(r.uint64(field))
+}
+
+func (r *reader) address16(field string) xbeeapi.Address16 {
+	return xbeeapi.Address16(r.uint16(field))
+}
+
+// clusters reads a count followed by that many cluster IDs.
+func (r *reader) clusters(field string) []uint16 {
+	n := int(r.uint8(field))
+	ids := []uint16(nil)
+	for i := 0; i < n && r.err == nil; i++ {
+		ids = append(ids, r.uint16(field))
+	}
+	return ids
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return binary.LittleEndian.AppendUint16(b, v)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return binary.LittleEndian.AppendUint64(b, v)
+}
+
+func appendClusters(b []byte, ids []uint16) []byte {
+	b = append(b, byte(len(ids)))
+	for _, id := range ids {
+		b = appendUint16(b, id)
+	}
+	return b
+}
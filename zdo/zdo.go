@@ -0,0 +1,367 @@
+// Package zdo is a client for the Zigbee Device Objects (ZDO) of remote
+// radios. Zigbee Device Profile requests are sent to endpoint 0, profile
+// 0, through explicit addressing, and responses are matched to them by
+// cluster ID, transaction sequence number and source address.
+//
+// Responses are only passed to the host when the local radio delivers
+// received data in explicit format (AO=1 or AO=3).
+package zdo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zenbulabs/xbeeapi"
+)
+
+const (
+	// Endpoint is the ZDO endpoint of every Zigbee device.
+	Endpoint = 0x00
+	// ProfileID is the Zigbee Device Profile.
+	ProfileID = 0x0000
+
+	// responseCluster is set in the cluster ID of every response.
+	responseCluster = 0x8000
+)
+
+// DefaultTimeout bounds a request and its response unless set with
+// WithTimeout.
+const DefaultTimeout = 5 * time.Second
+
+// Status is the status of a ZDP response.
+type Status byte
+
+const (
+	StatusSuccess           Status = 0x00
+	StatusInvalidRequest    Status = 0x80
+	StatusDeviceNotFound    Status = 0x81
+	StatusInvalidEndpoint   Status = 0x82
+	StatusNotActive         Status = 0x83
+	StatusNotSupported      Status = 0x84
+	StatusTimeout           Status = 0x85
+	StatusNoMatch           Status = 0x86
+	StatusNoEntry           Status = 0x88
+	StatusNoDescriptor      Status = 0x89
+	StatusInsufficientSpace Status = 0x8a
+	StatusNotPermitted      Status = 0x8b
+	StatusTableFull         Status = 0x8c
+	StatusNotAuthorized     Status = 0x8d
+)
+
+func (s Status) Description() string {
+	switch s {
+	case StatusSuccess:
+		return "Success"
+	case StatusInvalidRequest:
+		return "Invalid Request Type"
+	case StatusDeviceNotFound:
+		return "Device Not Found"
+	case StatusInvalidEndpoint:
+		return "Invalid Endpoint"
+	case StatusNotActive:
+		return "Endpoint Not Active"
+	case StatusNotSupported:
+		return "Not Supported"
+	case StatusTimeout:
+		return "Timeout"
+	case StatusNoMatch:
+		return "No Match"
+	case StatusNoEntry:
+		return "No Entry"
+	case StatusNoDescriptor:
+		return "No Descriptor"
+	case StatusInsufficientSpace:
+		return "Insufficient Space"
+	case StatusNotPermitted:
+		return "Not Permitted"
+	case StatusTableFull:
+		return "Table Full"
+	case StatusNotAuthorized:
+		return "Not Authorized"
+	}
+
+	return fmt.Sprintf("Unknown ZDP Status: %x", byte(s))
+}
+
+// ErrStatus is matched by every *StatusError.
+var ErrStatus = errors.New("ZDP request failed")
+
+// StatusError is returned, along with the response, when a remote device
+// answers a request with a status other than StatusSuccess.
+type StatusError struct {
+	ClusterID uint16
+	Status    Status
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("ZDP request %04x failed: %s", e.ClusterID, e.Status.Description())
+}
+
+func (e *StatusError) Is(target error) bool {
+	return target == ErrStatus
+}
+
+// Address is the device a request is sent to. Responses are only accepted
+// from the device addressed: from Address64, unless it is unknown or the
+// coordinator alias, else from Address16. Requests to broadcast addresses
+// get no response, except NWK_addr_req and IEEE_addr_req, which the
+// device looked up answers by unicast: the first of its responses is
+// accepted.
+type Address struct {
+	Address64 xbeeapi.Address64
+	Address16 xbeeapi.Address16
+}
+
+func (a Address) isBroadcast() bool {
+	return a.Address64 == xbeeapi.Address64Broadcast ||
+		(a.Address64 == xbeeapi.Address64Unknown && a.Address16.IsBroadcast())
+}
+
+// Request is a ZDP request. The cluster ID of its response is ClusterID
+// with bit 15 set.
+type Request interface {
+	ClusterID() uint16
+	appendTo(b []byte) []byte
+}
+
+// Response is a ZDP response.
+type Response interface {
+	ClusterID() uint16
+	status() Status
+	decode(r *reader)
+	appendTo(b []byte) []byte
+}
+
+// Option configures a Client in NewClient.
+type Option func(*Client)
+
+// WithTimeout sets how long a request may take, from sending it to its
+// response. Zero leaves it to the caller's context. The default is
+// DefaultTimeout.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// match identifies the response to a request by its cluster ID,
+// transaction sequence number and the device it comes from: from any
+// device if anySrc is set, else by its 64-bit address if by64 is set, by
+// its network address otherwise.
+type match struct {
+	clusterID uint16
+	seq       byte
+	anySrc    bool
+	by64      bool
+	src64     xbeeapi.Address64
+	src16     xbeeapi.Address16
+}
+
+// responseMatch returns the match for the response to a request sent to
+// dst.
+func responseMatch(clusterID uint16, seq byte, dst Address) match {
+	key := match{clusterID: clusterID, seq: seq}
+	switch {
+	case dst.isBroadcast():
+		key.anySrc = true
+	case dst.Address64 == xbeeapi.Address64Unknown:
+		key.src16 = dst.Address16
+	case dst.Address64 == xbeeapi.Address64Coordinator:
+		key.src16 = xbeeapi.Address16Coordinator
+	default:
+		key.by64, key.src64 = true, dst.Address64
+	}
+	return key
+}
+
+// Client sends ZDP requests through an XBeeAPI and waits for their
+// responses. It is safe for concurrent use.
+type Client struct {
+	api     *xbeeapi.XBeeAPI
+	timeout time.Duration
+	seq     atomic.Uint32
+	mu      *sync.Mutex
+	pending map[match]chan []byte
+	sub     *xbeeapi.Subscription
+}
+
+// NewClient returns a Client using api, which must be started for
+// responses to arrive. Close releases its subscription.
+func NewClient(api *xbeeapi.XBeeAPI, opts ...Option) *Client {
+	c := &Client{
+		api:     api,
+		timeout: DefaultTimeout,
+		mu:      &sync.Mutex{},
+		pending: make(map[match]chan []byte),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.sub = xbeeapi.Subscribe(api, c.receive, xbeeapi.MatchEndpoint(Endpoint))
+
+	return c
+}
+
+// Close stops matching responses. Requests in progress time out.
+func (c *Client) Close() {
+	c.sub.Unsubscribe()
+}
+
+func (c *Client) receive(rx *xbeeapi.RxExplicitIndicator) {
+	if rx.ProfileID != ProfileID || rx.SrcEndPoint != Endpoint || rx.ClusterID&responseCluster == 0 || len(rx.Payload) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	ch, ok := c.pending[match{clusterID: rx.ClusterID, seq: rx.Payload[0], by64: true, src64: rx.Address64}]
+	if !ok {
+		ch, ok = c.pending[match{clusterID: rx.ClusterID, seq: rx.Payload[0], src16: rx.Address16}]
+	}
+	if !ok {
+		ch, ok = c.pending[match{clusterID: rx.ClusterID, seq: rx.Payload[0], anySrc: true}]
+	}
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case ch <- rx.Payload[1:]:
+	default:
+	}
+}
+
+// Do sends req to dst and decodes the response into resp. It returns once
+// the response arrives from dst, or once the transmit status is in for
+// broadcast requests that get no response, which leave resp untouched.
+func (c *Client) Do(ctx context.Context, dst Address, req Request, resp Response) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	seq := byte(c.seq.Add(1))
+	noResponse := dst.isBroadcast() && !answersBroadcast(req)
+	ch := make(chan []byte, 1)
+	if !noResponse {
+		key := responseMatch(req.ClusterID()|responseCluster, seq, dst)
+		c.mu.Lock()
+		c.pending[key] = ch
+		c.mu.Unlock()
+		defer func() {
+			c.mu.Lock()
+			delete(c.pending, key)
+			c.mu.Unlock()
+		}()
+	}
+
+	tx := &xbeeapi.TxExplicitAddressing{
+		Address64:   dst.Address64,
+		Address16:   dst.Address16,
+		SrcEndPoint: Endpoint,
+		DstEndPoint: Endpoint,
+		ClusterID:   req.ClusterID(),
+		ProfileID:   ProfileID,
+		Payload:     req.appendTo([]byte{seq}),
+	}
+	if _, err := c.api.Transmit(ctx, tx); err != nil {
+		return err
+	}
+	if noResponse {
+		return nil
+	}
+
+	select {
+	case payload := <-ch:
+		r := &reader{data: payload}
+		resp.decode(r)
+		if r.err != nil {
+			return r.err
+		}
+		if s := resp.status(); s != StatusSuccess {
+			return &StatusError{ClusterID: req.ClusterID(), Status: s}
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// answersBroadcast reports whether req is answered when broadcast: by the
+// device it looks up.
+func answersBroadcast(req Request) bool {
+	switch req.(type) {
+	case *NWKAddrRequest, *IEEEAddrRequest:
+		return true
+	}
+	return false
+}
+
+// do runs Do and returns resp, also when the device answered with a
+// failure status. Broadcast requests that get no response return a nil
+// response.
+func do[R Response](ctx context.Context, c *Client, dst Address, req Request, resp R) (R, error) {
+	var none R
+	err := c.Do(ctx, dst, req, resp)
+	if err != nil && !errors.Is(err, ErrStatus) {
+		return none, err
+	}
+	if dst.isBroadcast() && !answersBroadcast(req) {
+		return none, nil
+	}
+	return resp, err
+}
+
+// NWKAddr looks up the network address of the device with IEEE address
+// req.IEEEAddr. It is usually sent as a broadcast, answered by that
+// device.
+func (c *Client) NWKAddr(ctx context.Context, dst Address, req *NWKAddrRequest) (*NWKAddrResponse, error) {
+	return do(ctx, c, dst, req, &NWKAddrResponse{})
+}
+
+// IEEEAddr looks up the IEEE address of the device with network address
+// req.NWKAddrOfInterest. Sent as a broadcast, it is answered by that
+// device.
+func (c *Client) IEEEAddr(ctx context.Context, dst Address, req *IEEEAddrRequest) (*IEEEAddrResponse, error) {
+	return do(ctx, c, dst, req, &IEEEAddrResponse{})
+}
+
+// NodeDesc reads a device's node descriptor.
+func (c *Client) NodeDesc(ctx context.Context, dst Address, req *NodeDescRequest) (*NodeDescResponse, error) {
+	return do(ctx, c, dst, req, &NodeDescResponse{})
+}
+
+// SimpleDesc reads the simple descriptor of one of a device's endpoints.
+func (c *Client) SimpleDesc(ctx context.Context, dst Address, req *SimpleDescRequest) (*SimpleDescResponse, error) {
+	return do(ctx, c, dst, req, &SimpleDescResponse{})
+}
+
+// ActiveEP lists a device's active endpoints.
+func (c *Client) ActiveEP(ctx context.Context, dst Address, req *ActiveEPRequest) (*ActiveEPResponse, error) {
+	return do(ctx, c, dst, req, &ActiveEPResponse{})
+}
+
+// MgmtLqi reads part of a device's neighbor table.
+func (c *Client) MgmtLqi(ctx context.Context, dst Address, req *MgmtLqiRequest) (*MgmtLqiResponse, error) {
+	return do(ctx, c, dst, req, &MgmtLqiResponse{})
+}
+
+// MgmtRtg reads part of a device's routing table.
+func (c *Client) MgmtRtg(ctx context.Context, dst Address, req *MgmtRtgRequest) (*MgmtRtgResponse, error) {
+	return do(ctx, c, dst, req, &MgmtRtgResponse{})
+}
+
+// MgmtLeave asks a device to leave the network.
+func (c *Client) MgmtLeave(ctx context.Context, dst Address, req *MgmtLeaveRequest) (*MgmtLeaveResponse, error) {
+	return do(ctx, c, dst, req, &MgmtLeaveResponse{})
+}
+
+// MgmtPermitJoining opens or closes a device for joining. Sent as a
+// broadcast it applies to the whole network and returns a nil response.
+func (c *Client) MgmtPermitJoining(ctx context.Context, dst Address, req *MgmtPermitJoiningRequest) (*MgmtPermitJoiningResponse, error) {
+	return do(ctx, c, dst, req, &MgmtPermitJoiningResponse{})
+}
@@ -0,0 +1,281 @@
+package zdo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zenbulabs/xbeeapi"
+	"github.com/zenbulabs/xbeeapi/simulator"
+)
+
+const (
+	coordinator64 xbeeapi.Address64 = 0x0013a20040000001
+	router64      xbeeapi.Address64 = 0x0013a20040000002
+	router16      xbeeapi.Address16 = 0x1111
+)
+
+var (
+	testNodeDesc = NodeDescriptor{
+		LogicalType:             LogicalTypeRouter,
+		FrequencyBand:           0x08,
+		MACCapability:           0x8e,
+		ManufacturerCode:        0x101e,
+		MaxBufferSize:           0x52,
+		MaxIncomingTransferSize: 0x0080,
+		ServerMask:              0x2c00,
+		MaxOutgoingTransferSize: 0x0080,
+	}
+	testSimpleDesc = SimpleDescriptor{
+		Endpoint:    0xe8,
+		ProfileID:   0xc105,
+		DeviceID:    0x0001,
+		InClusters:  []uint16{0x0000, 0x0006},
+		OutClusters: []uint16{0x0019},
+	}
+	testNeighbors = []Neighbor{
+		{ExtendedPANID: 0x1234, Address64: coordinator64, Address16: 0x0000, DeviceType: LogicalTypeCoordinator, RxOnWhenIdle: 1, Relationship: RelationshipParent, PermitJoining: 2, Depth: 0, LQI: 0xff},
+		{ExtendedPANID: 0x1234, Address64: 0x0013a20040000003, Address16: 0x2222, DeviceType: LogicalTypeEndDevice, Relationship: RelationshipChild, Depth: 2, LQI: 0x80},
+	}
+	testRoutes = []Route{
+		{Destination: 0x0000, Status: RouteActive, ManyToOne: true, NextHop: 0x0000},
+		{Destination: 0x3333, Status: RouteDiscoveryFailed, NextHop: 0xfffe},
+	}
+)
+
+// respond answers the ZDP requests reaching api. Mgmt_Leave_req is left
+// unanswered, and IEEE_addr_req first gets a response with a stale
+// sequence number.
+func respond(api *xbeeapi.XBeeAPI) *xbeeapi.Subscription {
+	return xbeeapi.Subscribe(api, func(rx *xbeeapi.RxExplicitIndicator) {
+		seq, body := rx.Payload[0], rx.Payload[1:]
+		var resp Response
+		switch rx.ClusterID {
+		case ClusterNWKAddr:
+			r := &reader{data: body}
+			if r.address64("IEEEAddr") != router64 {
+				return
+			}
+			resp = &NWKAddrResponse{AddrResponse{Status: StatusSuccess, IEEEAddr: router64, NWKAddr: router16}}
+		case ClusterIEEEAddr:
+			stale := &IEEEAddrResponse{AddrResponse{Status: StatusDeviceNotFound}}
+			reply(api, rx, seq-1, stale)
+			resp = &IEEEAddrResponse{AddrResponse{Status: StatusSuccess, IEEEAddr: router64, NWKAddr: router16}}
+		case ClusterNodeDesc:
+			resp = &NodeDescResponse{NWKAddrOfInterest: router16, Descriptor: testNodeDesc}
+		case ClusterActiveEP:
+			resp = &ActiveEPResponse{NWKAddrOfInterest: router16, Endpoints: []byte{0xe8}}
+		case ClusterSimpleDesc:
+			if body[2] != testSimpleDesc.Endpoint {
+				resp = &SimpleDescResponse{Status: StatusInvalidEndpoint, NWKAddrOfInterest: router16}
+				break
+			}
+			resp = &SimpleDescResponse{NWKAddrOfInterest: router16, Descriptor: testSimpleDesc}
+		case ClusterMgmtLqi:
+			resp = &MgmtLqiResponse{TotalEntries: 3, StartIndex: body[0], Neighbors: testNeighbors}
+		case ClusterMgmtRtg:
+			resp = &MgmtRtgResponse{TotalEntries: 2, Routes: testRoutes}
+		default:
+			return
+		}
+		reply(api, rx, seq, resp)
+	}, xbeeapi.MatchEndpoint(Endpoint))
+}
+
+func reply(api *xbeeapi.XBeeAPI, rx *xbeeapi.RxExplicitIndicator, seq byte, resp Response) {
+	api.SendFrames(&xbeeapi.TxExplicitAddressing{
+		Address64:   rx.Address64,
+		Address16:   rx.Address16,
+		SrcEndPoint: Endpoint,
+		DstEndPoint: Endpoint,
+		ClusterID:   resp.ClusterID(),
+		ProfileID:   ProfileID,
+		Payload:     resp.appendTo([]byte{seq}),
+	})
+}
+
+func startAPI(t *testing.T, radio *simulator.Radio) *xbeeapi.XBeeAPI {
+	api := xbeeapi.NewXBeeAPI(radio, nil)
+	if err := api.Start(context.Background()); err != nil {
+		t.Fatal("Could not start", err)
+	}
+	t.Cleanup(func() { api.Close() })
+	return api
+}
+
+func TestClient(t *testing.T) {
+	n := simulator.NewNetwork(1)
+	c := n.AddRadio(xbeeapi.DeviceTypeCoordinator, coordinator64, xbeeapi.Address16Coordinator)
+	r := n.AddRadio(xbeeapi.DeviceTypeRouter, router64, router16)
+	n.Connect(coordinator64, router64, simulator.Link{Latency: time.Millisecond})
+
+	rapi := startAPI(t, r)
+	defer respond(rapi).Unsubscribe()
+	client := NewClient(startAPI(t, c), WithTimeout(time.Second))
+	defer client.Close()
+
+	ctx := context.Background()
+	dst := Address{Address64: router64, Address16: xbeeapi.Address16Unknown}
+
+	ieee, err := client.IEEEAddr(ctx, dst, &IEEEAddrRequest{NWKAddrOfInterest: router16})
+	if err != nil || ieee.IEEEAddr != router64 || ieee.NWKAddr != router16 {
+		t.Error("Unexpected IEEE_addr_rsp", ieee, err)
+	}
+
+	node, err := client.NodeDesc(ctx, dst, &NodeDescRequest{NWKAddrOfInterest: router16})
+	if err != nil || node.NWKAddrOfInterest != router16 || node.Descriptor != testNodeDesc {
+		t.Error("Unexpected Node_Desc_rsp", node, err)
+	}
+
+	ep, err := client.ActiveEP(ctx, dst, &ActiveEPRequest{NWKAddrOfInterest: router16})
+	if err != nil || !reflect.DeepEqual(ep.Endpoints, []byte{0xe8}) {
+		t.Error("Unexpected Active_EP_rsp", ep, err)
+	}
+
+	simple, err := client.SimpleDesc(ctx, dst, &SimpleDescRequest{NWKAddrOfInterest: router16, Endpoint: 0xe8})
+	if err != nil || !reflect.DeepEqual(simple.Descriptor, testSimpleDesc) {
+		t.Error("Unexpected Simple_Desc_rsp", simple, err)
+	}
+
+	simple, err = client.SimpleDesc(ctx, dst, &SimpleDescRequest{NWKAddrOfInterest: router16, Endpoint: 0x01})
+	var se *StatusError
+	if !errors.Is(err, ErrStatus) || !errors.As(err, &se) || se.Status != StatusInvalidEndpoint || se.ClusterID != ClusterSimpleDesc {
+		t.Error("Expected invalid endpoint", err)
+	}
+	if simple == nil || simple.Status != StatusInvalidEndpoint {
+		t.Error("Expected response along with status error", simple)
+	}
+
+	lqi, err := client.MgmtLqi(ctx, dst, &MgmtLqiRequest{StartIndex: 1})
+	if err != nil || lqi.TotalEntries != 3 || lqi.StartIndex != 1 || !reflect.DeepEqual(lqi.Neighbors, testNeighbors) {
+		t.Error("Unexpected Mgmt_Lqi_rsp", lqi, err)
+	}
+
+	rtg, err := client.MgmtRtg(ctx, dst, &MgmtRtgRequest{})
+	if err != nil || rtg.TotalEntries != 2 || !reflect.DeepEqual(rtg.Routes, testRoutes) {
+		t.Error("Unexpected Mgmt_Rtg_rsp", rtg, err)
+	}
+
+	broadcast := Address{Address64: xbeeapi.Address64Unknown, Address16: xbeeapi.Address16BroadcastRouters}
+	if resp, err := client.MgmtPermitJoining(ctx, broadcast, &MgmtPermitJoiningRequest{Duration: 60, TCSignificance: true}); resp != nil || err != nil {
+		t.Error("Expected broadcast to return no response after transmit status", resp, err)
+	}
+	all := Address{Address64: xbeeapi.Address64Broadcast, Address16: xbeeapi.Address16Unknown}
+	nwk, err := client.NWKAddr(ctx, all, &NWKAddrRequest{IEEEAddr: router64})
+	if err != nil || nwk == nil || nwk.IEEEAddr != router64 || nwk.NWKAddr != router16 {
+		t.Error("Expected the broadcast NWK_addr_req to be answered", nwk, err)
+	}
+
+	client = NewClient(client.api, WithTimeout(100*time.Millisecond))
+	defer client.Close()
+	if _, err := client.MgmtLeave(ctx, dst, &MgmtLeaveRequest{Rejoin: true}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected timeout", err)
+	}
+
+	unknown := Address{Address64: 0x0013a200400000ff, Address16: xbeeapi.Address16Unknown}
+	if _, err := client.NodeDesc(ctx, unknown, &NodeDescRequest{}); !errors.Is(err, xbeeapi.ErrDeliveryFailed) {
+		t.Error("Expected delivery error", err)
+	}
+}
+
+func TestResponseMatch(t *testing.T) {
+	c := &Client{mu: &sync.Mutex{}, pending: make(map[match]chan []byte)}
+	cluster := ClusterNodeDesc | responseCluster
+	rx := func(seq byte, a64 xbeeapi.Address64, a16 xbeeapi.Address16) *xbeeapi.RxExplicitIndicator {
+		return &xbeeapi.RxExplicitIndicator{Address64: a64, Address16: a16, SrcEndPoint: Endpoint, ProfileID: ProfileID, ClusterID: cluster, Payload: []byte{seq, 0x00}}
+	}
+
+	by64 := make(chan []byte, 2)
+	c.pending[responseMatch(cluster, 1, Address{Address64: router64, Address16: xbeeapi.Address16Unknown})] = by64
+	c.receive(rx(1, coordinator64, router16))
+	if len(by64) != 0 {
+		t.Error("Accepted a response from another device")
+	}
+	c.receive(rx(1, router64, router16))
+	if len(by64) != 1 {
+		t.Error("Expected the response from the addressed device")
+	}
+
+	by16 := make(chan []byte, 2)
+	c.pending[responseMatch(cluster, 2, Address{Address64: xbeeapi.Address64Unknown, Address16: router16})] = by16
+	c.receive(rx(2, router64, 0x2222))
+	if len(by16) != 0 {
+		t.Error("Accepted a response from another network address")
+	}
+	c.receive(rx(2, router64, router16))
+	if len(by16) != 1 {
+		t.Error("Expected the response from the addressed network address")
+	}
+
+	anySrc := make(chan []byte, 2)
+	c.pending[responseMatch(cluster, 3, Address{Address64: xbeeapi.Address64Broadcast, Address16: xbeeapi.Address16Unknown})] = anySrc
+	c.receive(rx(2, router64, router16))
+	if len(anySrc) != 0 {
+		t.Error("Accepted a response with another sequence number")
+	}
+	c.receive(rx(3, router64, router16))
+	if len(anySrc) != 1 {
+		t.Error("Expected the response to a broadcast from any device")
+	}
+}
+
+func TestDecodeFailureStatus(t *testing.T) {
+	for _, resp := range []Response{&NodeDescResponse{}, &ActiveEPResponse{}, &SimpleDescResponse{}} {
+		r := &reader{data: []byte{byte(StatusDeviceNotFound)}}
+		resp.decode(r)
+		if r.err != nil || resp.status() != StatusDeviceNotFound {
+			t.Errorf("%T: unexpected status-only decode %v %v", resp, resp.status(), r.err)
+		}
+
+		r = &reader{data: []byte{byte(StatusNotActive), 0x11, 0x11}}
+		resp.decode(r)
+		if r.err != nil || resp.status() != StatusNotActive {
+			t.Errorf("%T: unexpected failure decode %v %v", resp, resp.status(), r.err)
+		}
+	}
+	r := &reader{data: []byte{byte(StatusNotActive), 0x11, 0x11}}
+	resp := &NodeDescResponse{}
+	resp.decode(r)
+	if resp.NWKAddrOfInterest != router16 {
+		t.Error("Expected NWKAddrOfInterest of a failed request, got", resp.NWKAddrOfInterest)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	full := (&MgmtLqiResponse{TotalEntries: 2, Neighbors: testNeighbors}).appendTo(nil)
+	for _, n := range []int{0, 3, 4, 20, len(full) - 1} {
+		r := &reader{data: full[:n]}
+		(&MgmtLqiResponse{}).decode(r)
+		if !errors.Is(r.err, xbeeapi.ErrTruncated) {
+			t.Error("Expected truncated error for", n, "bytes", r.err)
+		}
+	}
+
+	full = (&SimpleDescResponse{Descriptor: testSimpleDesc}).appendTo(nil)
+	r := &reader{data: full[:len(full)-1]}
+	(&SimpleDescResponse{}).decode(r)
+	if !errors.Is(r.err, xbeeapi.ErrTruncated) {
+		t.Error("Expected truncated simple descriptor", r.err)
+	}
+}
+
+func TestRequestPayloads(t *testing.T) {
+	cases := []struct {
+		req  Request
+		want []byte
+	}{
+		{&NWKAddrRequest{IEEEAddr: router64, RequestType: RequestExtended}, []byte{0x02, 0x00, 0x00, 0x40, 0x00, 0xa2, 0x13, 0x00, 0x01, 0x00}},
+		{&IEEEAddrRequest{NWKAddrOfInterest: router16}, []byte{0x11, 0x11, 0x00, 0x00}},
+		{&SimpleDescRequest{NWKAddrOfInterest: 0x1234, Endpoint: 0xe8}, []byte{0x34, 0x12, 0xe8}},
+		{&MgmtLeaveRequest{DeviceAddress: router64, RemoveChildren: true}, []byte{0x02, 0x00, 0x00, 0x40, 0x00, 0xa2, 0x13, 0x00, 0x40}},
+		{&MgmtPermitJoiningRequest{Duration: 0xff, TCSignificance: true}, []byte{0xff, 0x01}},
+	}
+	for _, c := range cases {
+		if got := c.req.appendTo(nil); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%T: got % x, want % x", c.req, got, c.want)
+		}
+	}
+}